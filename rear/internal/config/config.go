@@ -7,6 +7,9 @@ import (
 	"rear/internal/consts"
 	"rear/internal/utils"
 	"rear/pkg/logger"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -32,6 +35,69 @@ type PathConfig struct {
 	TempPath string
 	// png 临时文件
 	PngTempPath string
+	// RAW 预览临时文件
+	RawTempPath string
+}
+
+// WatcherConfig 存储库文件监听配置
+type WatcherConfig struct {
+	// 是否开启监听
+	Enable bool
+	// 同一文件事件的合并等待时间
+	Debounce time.Duration
+	// 轮询模式的扫描间隔【网络挂载目录使用】
+	PollInterval time.Duration
+	// 强制所有存储库使用轮询
+	ForcePolling bool
+}
+
+// ConcurrencyConfig 照片任务并发配置，按系统 CPU 和内存负载在上下限之间调整
+type ConcurrencyConfig struct {
+	// 初始并发数
+	Initial int
+	// 并发下限
+	Min int
+	// 并发上限
+	Max int
+	// 负载采样间隔
+	Interval time.Duration
+	// CPU 使用率高于该值时减少并发
+	CPUHigh float64
+	// CPU 使用率低于该值时才允许增加并发
+	CPULow float64
+	// 内存使用率高于该值时减少并发
+	MemHigh float64
+	// 内存使用率低于该值时才允许增加并发
+	MemLow float64
+	// 连续多少次采样满足条件才调整，避免来回抖动
+	Stable int
+	// 浏览时同步生成缺失缩略图的并发上限，不受负载调整
+	OnDemandThumbnails int
+}
+
+// ToolConfig 外部工具路径，为空时在程序目录和 PATH 中查找
+type ToolConfig struct {
+	ImageMagickPath string
+	ExifToolPath    string
+	VipsPath        string
+}
+
+// RawConfig RAW 文件处理配置
+type RawConfig struct {
+	// 内嵌预览的最长边小于该值时视为不可用，使用解码命令完整解码
+	MinPreviewSize int
+	// 完整解码命令【dcraw 或 rawtherapee-cli 的路径】，为空时只使用内嵌预览
+	Converter string
+}
+
+// RetryConfig 照片任务临时错误的重试配置
+type RetryConfig struct {
+	// 最多执行次数【含第一次】
+	MaxAttempts int
+	// 第一次重试的等待时间，之后每次翻倍
+	BaseDelay time.Duration
+	// 最长等待时间
+	MaxDelay time.Duration
 }
 
 // Config 配置结构
@@ -43,16 +109,29 @@ type Config struct {
 	IdleTimeout  time.Duration
 	// 基础支持的格式【ImageMagick, libvips】
 	BaseSupportedFileTypes []string
-	// 特殊支持的格式
+	// 特殊支持的格式【先解码为临时 PNG 再生成缩略图】
 	SpecialSupportedFileTypes []string
+	// RAW 格式【exiftool 提取内嵌预览，可选 dcraw, rawtherapee-cli 完整解码】
+	RawSupportedFileTypes []string
 	// 支持的缩略图格式
 	SupportedThumbnailFormat []string
 	ImageCompressionOption   ImageCompressionOptions
-	// Raw 单独支持【rawtherapee-cli, dcraw】
-	// heic 单独支持【heif-convert, magick】
+
+	ToolConfig ToolConfig
+
+	RawConfig RawConfig
 
 	PathConfig PathConfig
 
+	WatcherConfig WatcherConfig
+
+	ConcurrencyConfig ConcurrencyConfig
+
+	RetryConfig RetryConfig
+
+	// SQLite 未编译 FTS5 时允许全文检索回退到 LIKE 查询，默认启动失败
+	SearchLikeFallback bool
+
 	// 软件运行目录
 	AppPath string
 	AppDir  string
@@ -73,6 +152,47 @@ func InitConfig() *Config {
 		LogPath:       "app-logs",
 		TempPath:      "app-tmp",
 		PngTempPath:   "png-tmp",
+		RawTempPath:   "raw-tmp",
+	}
+
+	watcherConfig := WatcherConfig{
+		Enable:       utils.GetEnv("WATCHER_ENABLE", "true") == "true",
+		Debounce:     2 * time.Second,
+		PollInterval: 60 * time.Second,
+		ForcePolling: utils.GetEnv("WATCHER_FORCE_POLLING", "false") == "true",
+	}
+
+	maxWorkers := getEnvInt("TASK_MAX_WORKERS", max(runtime.NumCPU(), 2))
+	minWorkers := min(getEnvInt("TASK_MIN_WORKERS", 1), maxWorkers)
+	concurrencyConfig := ConcurrencyConfig{
+		Initial:  min(max(runtime.NumCPU()/2, minWorkers), maxWorkers),
+		Min:      minWorkers,
+		Max:      maxWorkers,
+		Interval: 5 * time.Second,
+		CPUHigh:  85,
+		CPULow:   60,
+		MemHigh:  90,
+		MemLow:   80,
+		Stable:   2,
+
+		OnDemandThumbnails: getEnvInt("THUMB_ON_DEMAND_WORKERS", max(runtime.NumCPU()/2, 1)),
+	}
+
+	retryConfig := RetryConfig{
+		MaxAttempts: getEnvInt("TASK_MAX_ATTEMPTS", 4),
+		BaseDelay:   5 * time.Second,
+		MaxDelay:    5 * time.Minute,
+	}
+
+	toolConfig := ToolConfig{
+		ImageMagickPath: utils.GetEnv("IMAGEMAGICK_PATH", ""),
+		ExifToolPath:    utils.GetEnv("EXIFTOOL_PATH", ""),
+		VipsPath:        utils.GetEnv("VIPS_PATH", ""),
+	}
+
+	rawConfig := RawConfig{
+		MinPreviewSize: 720,
+		Converter:      utils.GetEnv("RAW_CONVERTER", ""),
 	}
 
 	execPath, err := os.Executable()
@@ -88,11 +208,55 @@ func InitConfig() *Config {
 		IdleTimeout:               60 * time.Second,
 		BaseSupportedFileTypes:    []string{".jpg", ".jpeg", ".png", ".tif", ".tiff", ".bmp"},
 		SpecialSupportedFileTypes: []string{".gif", ".heic", ".heif", ".webp", ".avif", ".jxl"},
+		RawSupportedFileTypes:     []string{".3fr", ".arw", ".cr2", ".cr3", ".crw", ".dcr", ".dng", ".erf", ".iiq", ".k25", ".kdc", ".mef", ".mrw", ".nef", ".nrw", ".orf", ".pef", ".raf", ".raw", ".rw2", ".sr2", ".srf", ".x3f"},
 		SupportedThumbnailFormat:  []string{".jpg", ".webp"},
 		ImageCompressionOption:    i,
+		ToolConfig:                toolConfig,
+		RawConfig:                 rawConfig,
 		PathConfig:                pathConfig,
+		WatcherConfig:             watcherConfig,
+		ConcurrencyConfig:         concurrencyConfig,
+		RetryConfig:               retryConfig,
+		SearchLikeFallback:        utils.GetEnv("SEARCH_LIKE_FALLBACK", "false") == "true",
 		AppPath:                   execPath,
 		AppDir:                    filepath.Dir(execPath),
 	}
 	return &CONFIG
 }
+
+// ScanFileTypes 扫描存储库和监听文件变化时处理的格式
+func (c *Config) ScanFileTypes() []string {
+	types := make([]string, 0, len(c.BaseSupportedFileTypes)+len(c.SpecialSupportedFileTypes)+len(c.RawSupportedFileTypes))
+	types = append(types, c.BaseSupportedFileTypes...)
+	types = append(types, c.SpecialSupportedFileTypes...)
+	return append(types, c.RawSupportedFileTypes...)
+}
+
+// IsRawFile 是否为 RAW 文件【按扩展名判断】
+func (c *Config) IsRawFile(path string) bool {
+	return hasFileType(path, c.RawSupportedFileTypes)
+}
+
+// IsSpecialFile 是否为需要先解码为 PNG 的格式【按扩展名判断】
+func (c *Config) IsSpecialFile(path string) bool {
+	return hasFileType(path, c.SpecialSupportedFileTypes)
+}
+
+func hasFileType(path string, fileTypes []string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, fileType := range fileTypes {
+		if ext == fileType {
+			return true
+		}
+	}
+	return false
+}
+
+// getEnvInt 读取正整数环境变量，无效时使用默认值
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(utils.GetEnv(key, ""))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -5,12 +5,23 @@ import "rear/internal/repositories"
 type DbContainer struct {
 	LibraryRepo *repositories.LibraryRepository
 	UserRepo    *repositories.UserService
+	PhotoRepo   *repositories.PhotoRepository
+	ThumbRepo   *repositories.ThumbnailRepository
+	JobRepo     *repositories.JobRepository
+	TaskRepo    *repositories.TaskRepository
+	// 外部工具处理结果缓存
+	ToolCacheRepo *repositories.ToolCacheRepository
 	// 其他服务...
 }
 
 func NewContainer() *DbContainer {
 	return &DbContainer{
-		LibraryRepo: repositories.NewLibraryRepository(),
-		UserRepo:    repositories.NewUserService(),
+		LibraryRepo:   repositories.NewLibraryRepository(),
+		UserRepo:      repositories.NewUserService(),
+		PhotoRepo:     repositories.NewPhotoRepository(),
+		ThumbRepo:     repositories.NewThumbnailRepository(),
+		JobRepo:       repositories.NewJobRepository(),
+		TaskRepo:      repositories.NewTaskRepository(),
+		ToolCacheRepo: repositories.NewToolCacheRepository(),
 	}
 }
@@ -1,12 +1,30 @@
 package container
 
 import (
+	"rear/internal/config"
+	toolutils "rear/internal/utils"
+	"rear/internal/utils/tools"
+	"rear/internal/watcher"
 	"rear/internal/workflow"
 )
 
 type TaskContainer struct {
 	// 照片任务处理管理
 	ImgTaskManager *workflow.ImgTaskManager
+	// 索引任务管理
+	JobManager *workflow.JobManager
+	// 存储库索引
+	Indexer *workflow.Indexer
+	// 存储库文件监听
+	Watcher *watcher.Manager
+	// 进度事件推送
+	Events *workflow.EventBus
+	// 外部工具【执行器、图像处理后端和元数据读取器】
+	Tools *tools.Toolkit
+	// 外部工具执行器，关闭时结束常驻的 exiftool 进程
+	ToolRunner *tools.ExecRunner
+	// 浏览时按需生成缩略图
+	Thumbnails *workflow.OnDemandThumbnails
 	// 其他服务...
 
 	// 数据库服务
@@ -14,8 +32,52 @@ type TaskContainer struct {
 }
 
 func NewTaskContainer(con *DbContainer) *TaskContainer {
+	events := workflow.NewEventBus()
+	toolutils.OnCommandFailed(events.ToolFailed)
+	runner := tools.NewExecRunner(tools.ToolPaths{
+		ImageMagick:  config.CONFIG.ToolConfig.ImageMagickPath,
+		ExifTool:     config.CONFIG.ToolConfig.ExifToolPath,
+		Vips:         config.CONFIG.ToolConfig.VipsPath,
+		RawConverter: config.CONFIG.RawConfig.Converter,
+	})
+	kit := tools.NewToolkit(runner)
+	// 检测可用的图像处理后端及支持的格式
+	kit.Processors.Detect()
+	imgTaskManager := workflow.NewImgTaskManager(config.CONFIG.ConcurrencyConfig, config.CONFIG.RetryConfig, con.PhotoRepo, con.ThumbRepo, con.TaskRepo, con.ToolCacheRepo, events, kit)
+	jobManager := workflow.NewJobManager(con.JobRepo, imgTaskManager, events)
+	imgTaskManager.OnTaskFinished(jobManager.TaskFinished)
+	indexer := workflow.NewIndexer(imgTaskManager, jobManager, con.PhotoRepo)
 	return &TaskContainer{
 		DbContainer:    con,
-		ImgTaskManager: workflow.NewImgTaskManager(5),
+		ImgTaskManager: imgTaskManager,
+		JobManager:     jobManager,
+		Indexer:        indexer,
+		Events:         events,
+		Tools:          kit,
+		ToolRunner:     runner,
+		Thumbnails:     workflow.NewOnDemandThumbnails(config.CONFIG.ConcurrencyConfig.OnDemandThumbnails, con.ThumbRepo, kit),
+		Watcher:        watcher.NewManager(indexer, config.CONFIG.WatcherConfig, config.CONFIG.ScanFileTypes()),
 	}
 }
+
+// Recover 恢复上次未完成的文件任务，并将无法继续的索引任务标记为中断
+func (c *TaskContainer) Recover() error {
+	if err := c.JobManager.RestorePaused(); err != nil {
+		return err
+	}
+	resumed, err := c.ImgTaskManager.Recover()
+	if err != nil {
+		return err
+	}
+	return c.JobManager.RecoverInterrupted(resumed)
+}
+
+// SyncWatcher 按数据库中的存储库状态调整文件监听
+func (c *TaskContainer) SyncWatcher() error {
+	libraries, err := c.DbContainer.LibraryRepo.GetAllLibrary()
+	if err != nil {
+		return err
+	}
+	c.Watcher.Sync(libraries)
+	return nil
+}
@@ -2,12 +2,44 @@ package db
 
 import (
 	"rear/internal/model"
+
+	"gorm.io/gorm"
 )
 
 func AutoMigrate() error {
-	return DB.AutoMigrate(
+	err := DB.AutoMigrate(
 		&model.User{},
 		&model.LibraryTable{},
+		&model.Photo{},
+		&model.Thumbnail{},
+		&model.IndexJob{},
+		&model.IndexJobError{},
+		&model.PictureTaskRecord{},
+		&model.ToolCache{},
+		&model.Migration{},
 		// 在这里添加其他模型
 	)
+	if err != nil {
+		return err
+	}
+	// 时间线和全文检索索引不由 AutoMigrate 管理
+	if err := setupTimeline(); err != nil {
+		return err
+	}
+	return setupSearchIndex()
+}
+
+// runOnce 执行一次性数据迁移，完成后记录名称，之后启动时跳过
+func runOnce(db *gorm.DB, name string, migrate func(*gorm.DB) error) error {
+	var done int64
+	if err := db.Model(&model.Migration{}).Where("name = ?", name).Count(&done).Error; err != nil {
+		return err
+	}
+	if done > 0 {
+		return nil
+	}
+	if err := migrate(db); err != nil {
+		return err
+	}
+	return db.Create(&model.Migration{Name: name}).Error
 }
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"rear/internal/config"
+	"rear/internal/model"
+	"rear/pkg/logger"
+	"strings"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// 照片全文检索方式
+const (
+	// SQLite FTS5 虚拟表【trigram 分词，支持中文子串】，需要以 sqlite_fts5 构建标签编译
+	SearchFTS5 = "fts5"
+	// MySQL FULLTEXT 索引【ngram 分词】
+	SearchFullText = "fulltext"
+	// 不支持全文索引时逐行 LIKE 匹配【SQLite 需配置 SearchLikeFallback】
+	SearchLike = "like"
+)
+
+// PhotoSearchColumns 参与全文检索的 photos 列
+var PhotoSearchColumns = []string{
+	"file_name", "dir", "exif_title", "exif_description", "exif_keywords", "exif_make", "exif_model", "exif_lens_id",
+}
+
+// FTS5 虚拟表及其列【相机合并为一列】
+const (
+	PhotoFTSTable   = "photos_fts"
+	photoFTSColumns = "file_name, dir, title, description, keywords, camera, lens"
+	// 短词索引：以 photos.search_grams 为外部内容，每个 1、2 个字符的片段为一个词【ascii 分词只按 ASCII 标点和空白拆分】
+	PhotoShortFTSTable = "photos_fts_short"
+	// MySQL FULLTEXT 索引名
+	photoFullTextIndex = "idx_photos_fulltext"
+)
+
+// ftsTriggers 同步两个 FTS5 表的触发器
+var ftsTriggers = []string{
+	"photos_fts_ai", "photos_fts_ad", "photos_fts_au", "photos_fts_short_ai", "photos_fts_short_ad", "photos_fts_short_au",
+}
+
+var searchMode atomic.Value
+
+// SearchMode 当前使用的全文检索方式
+func SearchMode() string {
+	if mode, ok := searchMode.Load().(string); ok {
+		return mode
+	}
+	return SearchLike
+}
+
+// setupSearchIndex 建立照片全文检索索引，数据库不支持时回退到 LIKE 查询
+func setupSearchIndex() error {
+	mode := SearchLike
+	var err error
+	if IsSQLite() {
+		mode, err = setupFTS5(DB)
+	} else {
+		mode, err = setupFullText(DB)
+	}
+	if err != nil {
+		return err
+	}
+	searchMode.Store(mode)
+	logger.Info("照片全文检索方式", zap.String("mode", mode))
+	return nil
+}
+
+// ftsValues FTS5 表各列对应的 photos 表达式，row 为 new、old 或表名
+func ftsValues(row string) string {
+	return fmt.Sprintf("%[1]s.file_name, %[1]s.dir, %[1]s.exif_title, %[1]s.exif_description, %[1]s.exif_keywords, "+
+		"trim(coalesce(%[1]s.exif_make, '') || ' ' || coalesce(%[1]s.exif_model, '')), %[1]s.exif_lens_id", row)
+}
+
+// setupFTS5 创建 FTS5 虚拟表和同步触发器
+// 当前程序未编译 FTS5 时返回错误【错误中给出构建标签和回退配置，见 README】，除非配置允许回退到 LIKE 查询；
+// 回退时删除触发器【否则写入 photos 会失败】，之后重新启用时重建索引内容
+func setupFTS5(db *gorm.DB) (string, error) {
+	if err := db.Exec("CREATE VIRTUAL TABLE temp.fts5_probe USING fts5(x, tokenize = 'trigram')").Error; err != nil {
+		if !config.CONFIG.SearchLikeFallback {
+			return "", fmt.Errorf("sqlite fts5 unavailable (%w): build or run with -tags sqlite_fts5, e.g. go run -tags sqlite_fts5 ., or set SEARCH_LIKE_FALLBACK=true to fall back to LIKE search", err)
+		}
+		logger.Warn("SQLite 不支持 FTS5，全文检索使用 LIKE 查询【以 sqlite_fts5 构建标签编译以启用】", zap.Error(err))
+		for _, name := range ftsTriggers {
+			if err := db.Exec("DROP TRIGGER IF EXISTS " + name).Error; err != nil {
+				return "", err
+			}
+		}
+		return SearchLike, nil
+	}
+	if err := db.Exec("DROP TABLE temp.fts5_probe").Error; err != nil {
+		return "", err
+	}
+
+	var triggers int64
+	err := db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'trigger' AND name IN ?", ftsTriggers).
+		Scan(&triggers).Error
+	if err != nil {
+		return "", err
+	}
+	if triggers == int64(len(ftsTriggers)) {
+		return SearchFTS5, nil
+	}
+
+	// 首次创建或触发器缺失【期间的写入未同步】，重建索引内容
+	if err := backfillSearchGrams(db); err != nil {
+		return "", err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(%s, tokenize = 'trigram')", PhotoFTSTable, photoFTSColumns),
+			"DELETE FROM " + PhotoFTSTable,
+			fmt.Sprintf("INSERT INTO %s(rowid, %s) SELECT id, %s FROM photos", PhotoFTSTable, photoFTSColumns, ftsValues("photos")),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS photos_fts_ai AFTER INSERT ON photos BEGIN
+	INSERT INTO %s(rowid, %s) VALUES (new.id, %s);
+END`, PhotoFTSTable, photoFTSColumns, ftsValues("new")),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS photos_fts_ad AFTER DELETE ON photos BEGIN
+	DELETE FROM %s WHERE rowid = old.id;
+END`, PhotoFTSTable),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS photos_fts_au AFTER UPDATE OF %s ON photos BEGIN
+	DELETE FROM %[2]s WHERE rowid = old.id;
+	INSERT INTO %[2]s(rowid, %[3]s) VALUES (new.id, %[4]s);
+END`, strings.Join(PhotoSearchColumns, ", "), PhotoFTSTable, photoFTSColumns, ftsValues("new")),
+			fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(search_grams, content = 'photos', content_rowid = 'id', tokenize = 'ascii', detail = 'none')", PhotoShortFTSTable),
+			fmt.Sprintf("INSERT INTO %[1]s(%[1]s) VALUES ('rebuild')", PhotoShortFTSTable),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS photos_fts_short_ai AFTER INSERT ON photos BEGIN
+	INSERT INTO %s(rowid, search_grams) VALUES (new.id, new.search_grams);
+END`, PhotoShortFTSTable),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS photos_fts_short_ad AFTER DELETE ON photos BEGIN
+	INSERT INTO %[1]s(%[1]s, rowid, search_grams) VALUES ('delete', old.id, old.search_grams);
+END`, PhotoShortFTSTable),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS photos_fts_short_au AFTER UPDATE OF search_grams ON photos BEGIN
+	INSERT INTO %[1]s(%[1]s, rowid, search_grams) VALUES ('delete', old.id, old.search_grams);
+	INSERT INTO %[1]s(rowid, search_grams) VALUES (new.id, new.search_grams);
+END`, PhotoShortFTSTable),
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("create fts5 index failed: %w", err)
+	}
+	return SearchFTS5, nil
+}
+
+// backfillSearchGrams 重新生成所有照片的检索片段【添加该列之前索引的照片，或停用 FTS5 期间的记录】
+func backfillSearchGrams(db *gorm.DB) error {
+	const batchSize = 1000
+	var lastID uint
+	for {
+		var photos []model.Photo
+		err := db.Unscoped().Select("id", "file_name", "dir", "exif_title", "exif_description", "exif_keywords",
+			"exif_make", "exif_model", "exif_lens_id").
+			Where("id > ?", lastID).Order("id").Limit(batchSize).Find(&photos).Error
+		if err != nil {
+			return err
+		}
+		if len(photos) == 0 {
+			return nil
+		}
+		lastID = photos[len(photos)-1].ID
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for i := range photos {
+				photos[i].UpdateSearchGrams()
+				err := tx.Model(&model.Photo{}).Unscoped().Where("id = ?", photos[i].ID).
+					UpdateColumn("search_grams", photos[i].SearchGrams).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("backfill search grams failed: %w", err)
+		}
+	}
+}
+
+// setupFullText 创建 MySQL FULLTEXT 索引，创建失败时回退到 LIKE 查询
+func setupFullText(db *gorm.DB) (string, error) {
+	if db.Migrator().HasIndex("photos", photoFullTextIndex) {
+		return SearchFullText, nil
+	}
+	statement := fmt.Sprintf("ALTER TABLE photos ADD FULLTEXT INDEX %s (%s) WITH PARSER ngram",
+		photoFullTextIndex, strings.Join(PhotoSearchColumns, ", "))
+	if err := db.Exec(statement).Error; err != nil {
+		logger.Warn("创建 FULLTEXT 索引失败，全文检索使用 LIKE 查询", zap.Error(err))
+		return SearchLike, nil
+	}
+	return SearchFullText, nil
+}
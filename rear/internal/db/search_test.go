@@ -0,0 +1,44 @@
+package db
+
+import (
+	"path/filepath"
+	"rear/internal/config"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// 未编译 FTS5 时默认返回错误，配置允许后才回退到 LIKE 查询
+func TestSetupFTS5Unavailable(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.Exec("CREATE VIRTUAL TABLE temp.probe USING fts5(x)").Error == nil {
+		t.Skip("built with sqlite_fts5")
+	}
+	previous := config.CONFIG.SearchLikeFallback
+	t.Cleanup(func() { config.CONFIG.SearchLikeFallback = previous })
+
+	config.CONFIG.SearchLikeFallback = false
+	mode, err := setupFTS5(conn)
+	if err == nil {
+		t.Fatalf("mode = %s, want error without fts5", mode)
+	}
+	// 错误中给出修复方法
+	for _, hint := range []string{"-tags sqlite_fts5", "SEARCH_LIKE_FALLBACK=true"} {
+		if !strings.Contains(err.Error(), hint) {
+			t.Errorf("error %q does not mention %s", err, hint)
+		}
+	}
+
+	config.CONFIG.SearchLikeFallback = true
+	if mode, err := setupFTS5(conn); err != nil || mode != SearchLike {
+		t.Fatalf("fallback mode = %s, err = %v, want like", mode, err)
+	}
+}
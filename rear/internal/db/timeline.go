@@ -0,0 +1,86 @@
+package db
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"rear/internal/model"
+	"rear/pkg/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 时间线统计使用的索引：按拍摄日期分组并排除已删除和缺失的照片时只需扫描索引
+// deleted_at 来自共用的 BaseModel，无法通过结构体标签组成联合索引，因此手动创建
+var timelineIndexes = map[string]string{
+	"idx_photos_library_day": "library_id, capture_day, missing, deleted_at",
+	"idx_photos_day":         "capture_day, missing, deleted_at",
+}
+
+// setupTimeline 创建时间线索引并补全旧记录的拍摄日期
+// 新记录保存时已设置拍摄日期，补全只需执行一次
+func setupTimeline() error {
+	if err := createTimelineIndexes(DB); err != nil {
+		return err
+	}
+	return runOnce(DB, "backfill_capture_day", backfillCaptureDay)
+}
+
+func createTimelineIndexes(db *gorm.DB) error {
+	for name, columns := range timelineIndexes {
+		if db.Migrator().HasIndex("photos", name) {
+			continue
+		}
+		if err := db.Exec(fmt.Sprintf("CREATE INDEX %s ON photos (%s)", name, columns)).Error; err != nil {
+			return fmt.Errorf("create index %s failed: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// backfillCaptureDay 为添加拍摄日期列之前索引的照片计算拍摄日期
+// 按读出时间的时区计算【SQLite 保留写入时的时区，MySQL 按连接参数 loc 转换】
+func backfillCaptureDay(db *gorm.DB) error {
+	const batchSize = 1000
+	// 早于该时间视为没有拍摄时间，保持为 0
+	minTime := time.Date(1800, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var lastID uint
+	var total int
+	for {
+		var photos []model.Photo
+		err := db.Unscoped().Select("id", "capture_time").
+			Where("capture_day = 0 AND capture_time > ? AND id > ?", minTime, lastID).
+			Order("id").Limit(batchSize).Find(&photos).Error
+		if err != nil {
+			return err
+		}
+		if len(photos) == 0 {
+			break
+		}
+		lastID = photos[len(photos)-1].ID
+
+		// 同一天的照片一次更新
+		days := make(map[int][]uint)
+		for _, photo := range photos {
+			day := model.CaptureDayOf(photo.CaptureTime)
+			days[day] = append(days[day], photo.ID)
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for day, ids := range days {
+				if err := tx.Model(&model.Photo{}).Unscoped().Where("id IN ?", ids).UpdateColumn("capture_day", day).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("backfill capture day failed: %w", err)
+		}
+		total += len(photos)
+	}
+	if total > 0 {
+		logger.Info("已补全照片拍摄日期", zap.Int("count", total))
+	}
+	return nil
+}
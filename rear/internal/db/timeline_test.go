@@ -0,0 +1,75 @@
+package db
+
+import (
+	"path/filepath"
+	"rear/internal/config"
+	"rear/internal/model"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// 拍摄日期只在首次迁移时补全，之后启动不再扫描
+func TestBackfillCaptureDayOnce(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousDB, previousFallback := DB, config.CONFIG.SearchLikeFallback
+	DB, config.CONFIG.SearchLikeFallback = conn, true
+	t.Cleanup(func() { DB, config.CONFIG.SearchLikeFallback = previousDB, previousFallback })
+	if err := AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟添加拍摄日期列之前索引的照片
+	if err := DB.Create(&model.LibraryTable{ImgPath: t.TempDir()}).Error; err != nil {
+		t.Fatal(err)
+	}
+	photo := &model.Photo{LibraryID: 1, Path: "/a.jpg"}
+	photo.SetCaptureTime(time.Date(2023, 5, 1, 23, 0, 0, 0, time.FixedZone("", 8*3600)), model.CaptureTimeFromExif)
+	if err := DB.Create(photo).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Model(photo).UpdateColumn("capture_day", 0).Error; err != nil {
+		t.Fatal(err)
+	}
+	captureDay := func() int {
+		t.Helper()
+		var day int
+		if err := DB.Model(&model.Photo{}).Where("id = ?", photo.ID).Pluck("capture_day", &day).Error; err != nil {
+			t.Fatal(err)
+		}
+		return day
+	}
+
+	// 已记录完成，不再扫描
+	if err := setupTimeline(); err != nil {
+		t.Fatal(err)
+	}
+	if day := captureDay(); day != 0 {
+		t.Fatalf("capture_day = %d after completed migration, want 0", day)
+	}
+
+	if err := DB.Where("name = ?", "backfill_capture_day").Delete(&model.Migration{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := setupTimeline(); err != nil {
+		t.Fatal(err)
+	}
+	if day := captureDay(); day != 20230501 {
+		t.Fatalf("capture_day = %d, want 20230501", day)
+	}
+	var done int64
+	if err := DB.Model(&model.Migration{}).Where("name = ?", "backfill_capture_day").Count(&done).Error; err != nil {
+		t.Fatal(err)
+	}
+	if done != 1 {
+		t.Fatalf("migration marker count = %d, want 1", done)
+	}
+}
@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"rear/internal/container"
 	"rear/internal/utils/tools"
 	"rear/pkg/logger"
@@ -10,11 +12,12 @@ import (
 )
 
 type DevImageHandler struct {
-	container *container.DbContainer
+	container  *container.DbContainer
+	imgContain *container.TaskContainer
 }
 
-func NewDevImageHandler(container *container.DbContainer) *DevImageHandler {
-	return &DevImageHandler{container: container}
+func NewDevImageHandler(container *container.DbContainer, imgContain *container.TaskContainer) *DevImageHandler {
+	return &DevImageHandler{container: container, imgContain: imgContain}
 }
 
 // GetExif 获取图片的EXIF信息
@@ -29,8 +32,21 @@ func (h *DevImageHandler) GetExif(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "image_path query parameter is required"})
 		return
 	}
+	// 只允许读取已启用存储库内的文件
+	imagePath, err := resolveLibraryFile(h.container.LibraryRepo, imagePath)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			c.JSON(404, gin.H{"error": "file not found"})
+		case errors.Is(err, errOutsideLibrary):
+			c.JSON(403, gin.H{"error": err.Error()})
+		default:
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
 	// 调用工具函数获取 EXIF 数据
-	exifData, err := tools.GetExifData(c, imagePath)
+	exifData, err := tools.GetExifData(c, h.imgContain.Tools.Runner, imagePath)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to get EXIF data"})
 		return
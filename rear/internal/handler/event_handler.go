@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"rear/internal/container"
+	"rear/internal/model"
+	"rear/internal/workflow"
+	"rear/pkg/logger"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 心跳间隔，用于及时发现断开的连接并防止代理超时断开
+	sseHeartbeat = 15 * time.Second
+	// 单次写入超时
+	sseWriteTimeout = 2 * sseHeartbeat
+)
+
+type EventHandler struct {
+	imgContain *container.TaskContainer
+}
+
+func NewEventHandler(imgContain *container.TaskContainer) *EventHandler {
+	return &EventHandler{imgContain: imgContain}
+}
+
+// Stream 通过 Server-Sent Events 推送索引进度
+// 可选参数 job_id：只推送该索引任务的任务和计数事件，全局计数和工具错误始终推送
+func (h *EventHandler) Stream(c *gin.Context) {
+	var jobID uint
+	if value := c.Query("job_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.Response{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid job id",
+			})
+			return
+		}
+		jobID = uint(id)
+	}
+
+	// 长连接不受 Server.WriteTimeout 限制，每次写入前重新设置写超时
+	rc := http.NewResponseController(c.Writer)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logger.Warn("事件推送无法取消写超时", zap.Error(err))
+	}
+
+	events := h.imgContain.Events.Subscribe()
+	defer h.imgContain.Events.Unsubscribe(events)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// 关闭 nginx 等反向代理的缓冲
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// 首个事件带上请求 ID，便于和日志对应
+	_ = rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+	c.SSEvent("ready", gin.H{"request_id": c.GetString("request_id")})
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			_ = rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case event, ok := <-events:
+			if !ok {
+				// 服务关闭
+				return false
+			}
+			if !matchJob(event, jobID) {
+				return true
+			}
+			_ = rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatUint(event.ID, 10),
+				Event: event.Type,
+				Data:  event.Data,
+			})
+			return true
+		}
+	})
+}
+
+// matchJob 事件是否属于指定的索引任务
+func matchJob(event workflow.Event, jobID uint) bool {
+	if jobID == 0 {
+		return true
+	}
+	switch data := event.Data.(type) {
+	case workflow.TaskInfo:
+		return data.JobID == jobID
+	case *workflow.JobProgress:
+		return data.ID == jobID
+	}
+	return true
+}
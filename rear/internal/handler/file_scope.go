@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"rear/internal/repositories"
+	"rear/pkg/utils"
+)
+
+// errOutsideLibrary 路径不在任何已启用的存储库内
+var errOutsideLibrary = errors.New("path is outside of enabled libraries")
+
+// resolveLibraryFile 解析符号链接后的真实路径，只允许访问位于已启用存储库内的普通文件
+// 返回解析后的路径，调用方应使用该路径打开文件
+func resolveLibraryFile(libraryRepo *repositories.LibraryRepository, path string) (string, error) {
+	libraries, err := libraryRepo.GetEnabledLibraries()
+	if err != nil {
+		return "", err
+	}
+	roots := make([]string, 0, len(libraries))
+	for _, library := range libraries {
+		roots = append(roots, library.ImgPath)
+	}
+	return resolveFileWithin(path, roots)
+}
+
+// resolveFileWithin 解析 path 的符号链接，结果必须是位于某个 roots 目录内的普通文件
+func resolveFileWithin(path string, roots []string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", errOutsideLibrary
+	}
+
+	for _, root := range roots {
+		root, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		// 存储库路径本身也可能是符号链接
+		if target, err := filepath.EvalSymlinks(root); err == nil {
+			root = target
+		}
+		if utils.FileUtils.IsWithin(resolved, root) {
+			return resolved, nil
+		}
+	}
+	return "", errOutsideLibrary
+}
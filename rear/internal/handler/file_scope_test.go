@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveFileWithin(t *testing.T) {
+	dir := t.TempDir()
+	library := filepath.Join(dir, "library")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{library, outside} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inside := filepath.Join(library, "a.jpg")
+	secret := filepath.Join(outside, "secret.jpg")
+	for _, f := range []string{inside, secret} {
+		if err := os.WriteFile(f, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 存储库内指向外部文件的链接，以及指向存储库的链接
+	escape := filepath.Join(library, "escape.jpg")
+	if err := os.Symlink(secret, escape); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	linkedLibrary := filepath.Join(dir, "linked")
+	if err := os.Symlink(library, linkedLibrary); err != nil {
+		t.Fatal(err)
+	}
+	roots := []string{linkedLibrary}
+
+	want, _ := filepath.EvalSymlinks(inside)
+	if got, err := resolveFileWithin(inside, roots); err != nil || got != want {
+		t.Errorf("inside = %q, %v", got, err)
+	}
+	if _, err := resolveFileWithin(filepath.Join(library, "..", "outside", "secret.jpg"), roots); !errors.Is(err, errOutsideLibrary) {
+		t.Errorf("dot-dot: err = %v", err)
+	}
+	if _, err := resolveFileWithin(escape, roots); !errors.Is(err, errOutsideLibrary) {
+		t.Errorf("symlink escape: err = %v", err)
+	}
+	if _, err := resolveFileWithin(library, roots); !errors.Is(err, errOutsideLibrary) {
+		t.Errorf("directory: err = %v", err)
+	}
+	if _, err := resolveFileWithin(filepath.Join(library, "missing.jpg"), roots); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing: err = %v", err)
+	}
+	if _, err := resolveFileWithin(inside, nil); !errors.Is(err, errOutsideLibrary) {
+		t.Errorf("no library: err = %v", err)
+	}
+}
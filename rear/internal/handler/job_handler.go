@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"rear/internal/container"
+	"rear/internal/model"
+	"rear/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	imgContain *container.TaskContainer
+}
+
+func NewJobHandler(imgContain *container.TaskContainer) *JobHandler {
+	return &JobHandler{imgContain: imgContain}
+}
+
+// ListJobs 索引任务历史
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	page, pageSize := getPagination(c)
+
+	jobs, total, err := h.imgContain.JobManager.ListJobs(page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    http.StatusOK,
+		Message: "Success",
+		Data: map[string]interface{}{
+			"items":     jobs,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// GetJob 索引任务进度，文件错误按 page, page_size 分页
+func (h *JobHandler) GetJob(c *gin.Context) {
+	id, ok := getUintParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid job id",
+		})
+		return
+	}
+	page, pageSize := getPagination(c)
+
+	progress, err := h.imgContain.JobManager.GetProgress(id, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+	if progress == nil {
+		c.JSON(http.StatusNotFound, model.Response{
+			Code:    http.StatusNotFound,
+			Message: "Job not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    http.StatusOK,
+		Message: "Success",
+		Data:    progress,
+	})
+}
+
+// GetTask 单个文件任务的状态【成功、跳过和取消的任务结束后不再保留】
+func (h *JobHandler) GetTask(c *gin.Context) {
+	info, ok := h.imgContain.ImgTaskManager.GetTask(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, model.Response{
+			Code:    http.StatusNotFound,
+			Message: "Task not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    http.StatusOK,
+		Message: "Success",
+		Data:    info,
+	})
+}
+
+// PauseJob 暂停索引任务
+func (h *JobHandler) PauseJob(c *gin.Context) {
+	h.controlJob(c, h.imgContain.JobManager.PauseJob, "索引任务已暂停")
+}
+
+// ResumeJob 恢复索引任务
+func (h *JobHandler) ResumeJob(c *gin.Context) {
+	h.controlJob(c, h.imgContain.JobManager.ResumeJob, "索引任务已恢复")
+}
+
+// CancelJob 取消索引任务
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	h.controlJob(c, h.imgContain.JobManager.CancelJob, "索引任务已取消")
+}
+
+// PauseTask 暂停单个文件任务
+func (h *JobHandler) PauseTask(c *gin.Context) {
+	h.controlTask(c, h.imgContain.ImgTaskManager.PauseTask, "任务已暂停")
+}
+
+// ResumeTask 恢复单个文件任务
+func (h *JobHandler) ResumeTask(c *gin.Context) {
+	h.controlTask(c, h.imgContain.ImgTaskManager.ResumeTask, "任务已恢复")
+}
+
+// CancelTask 取消单个文件任务
+func (h *JobHandler) CancelTask(c *gin.Context) {
+	h.controlTask(c, h.imgContain.ImgTaskManager.CancelTask, "任务已取消")
+}
+
+func (h *JobHandler) controlJob(c *gin.Context, action func(uint) error, message string) {
+	id, ok := getUintParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid job id",
+		})
+		return
+	}
+	if err := action(id); err != nil {
+		controlError(c, err)
+		return
+	}
+
+	progress, err := h.imgContain.JobManager.GetProgress(id, 1, defaultPageSize)
+	if err != nil {
+		controlError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Response{
+		Code:    http.StatusOK,
+		Message: message,
+		Data:    progress,
+	})
+}
+
+func (h *JobHandler) controlTask(c *gin.Context, action func(string) error, message string) {
+	id := c.Param("id")
+	if err := action(id); err != nil {
+		controlError(c, err)
+		return
+	}
+
+	info, _ := h.imgContain.ImgTaskManager.GetTask(id)
+	c.JSON(http.StatusOK, model.Response{
+		Code:    http.StatusOK,
+		Message: message,
+		Data:    info,
+	})
+}
+
+// controlError 暂停、恢复、取消失败时的响应
+func controlError(c *gin.Context, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, workflow.ErrJobNotFound), errors.Is(err, workflow.ErrTaskNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, workflow.ErrInvalidTransition):
+		code = http.StatusConflict
+	}
+	c.JSON(code, model.Response{
+		Code:    code,
+		Message: err.Error(),
+	})
+}
+
+// ListDeadTasks 死信列表，可按 error_class 过滤
+func (h *JobHandler) ListDeadTasks(c *gin.Context) {
+	page, pageSize := getPagination(c)
+
+	dead, err := h.imgContain.ImgTaskManager.ListDeadTasks(c.Query("error_class"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    http.StatusOK,
+		Message: "Success",
+		Data:    dead,
+	})
+}
+
+// RetryDeadTask 重新执行死信列表中的任务
+func (h *JobHandler) RetryDeadTask(c *gin.Context) {
+	if err := h.imgContain.ImgTaskManager.RetryDeadTask(c.Param("id")); err != nil {
+		controlError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    http.StatusOK,
+		Message: "任务已重新加入队列",
+	})
+}
+
+// RetryDeadTasks 重新执行死信列表中的所有任务，可按 error_class 过滤
+func (h *JobHandler) RetryDeadTasks(c *gin.Context) {
+	count, err := h.imgContain.ImgTaskManager.RetryDeadTasks(c.Query("error_class"))
+	if err != nil {
+		controlError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    http.StatusOK,
+		Message: "任务已重新加入队列",
+		Data: map[string]interface{}{
+			"count": count,
+		},
+	})
+}
@@ -5,9 +5,9 @@ import (
 	"go.uber.org/zap"
 	"log"
 	"net/http"
-	"rear/internal/config"
 	"rear/internal/container"
 	"rear/internal/model"
+	"rear/internal/workflow"
 	"rear/pkg/logger"
 	"rear/pkg/utils"
 	"strings"
@@ -84,6 +84,9 @@ func (h *LibraryHandler) AddLibrary(c *gin.Context) {
 		return
 	}
 
+	// 存储库变化后同步文件监听
+	h.syncWatcher()
+
 	c.JSON(http.StatusOK, model.Response{
 		Code:    http.StatusOK,
 		Message: "Success",
@@ -116,6 +119,9 @@ func (h *LibraryHandler) UpdateLibrary(c *gin.Context) {
 		return
 	}
 
+	// 存储库变化后同步文件监听
+	h.syncWatcher()
+
 	c.JSON(http.StatusOK, model.Response{
 		Code:    http.StatusOK,
 		Message: "Library updated successfully",
@@ -140,6 +146,9 @@ func (h *LibraryHandler) DeleteLibrary(c *gin.Context) {
 		return
 	}
 
+	// 存储库变化后同步文件监听
+	h.syncWatcher()
+
 	c.JSON(http.StatusOK, model.Response{
 		Code:    http.StatusOK,
 		Message: "Library deleted successfully",
@@ -166,12 +175,12 @@ func (h *LibraryHandler) LibraryIndex(c *gin.Context) {
 	}
 
 	// 存在可用路径，检索开始
-	var dirs []string // 创建一个空的 string 切片
+	var dirs []model.LibraryTable
 	for i := range library {
 		path := library[i]
 		isDir := utils.FileUtils.IsDir(path.ImgPath)
 		if isDir {
-			dirs = append(dirs, path.ImgPath)
+			dirs = append(dirs, path)
 		}
 	}
 	// 无用文件夹是否要进行提示【TODO】
@@ -183,36 +192,32 @@ func (h *LibraryHandler) LibraryIndex(c *gin.Context) {
 		return
 	}
 
-	// 获取可用路径下所有的照片【指定类型】
-	logger.Info(fmt.Sprintf("检索的列表: %v", dirs))
-	var fileList []string // 创建一个空的 string 切片
-	for i := range dirs {
-		dir := dirs[i]
-
-		files, err := utils.FileUtils.GetFilteredFiles(dir, true, config.CONFIG.BaseSupportedFileTypes)
-		if err != nil {
-			logger.Error("文件获取失败！", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, model.Response{
-				Code:    http.StatusInternalServerError,
-				Message: fmt.Sprintf("指定路径 %v 文件获取失败!", dir),
-			})
-			return
-		}
-		for i2 := range files.SupportedFiles {
-			info := files.SupportedFiles[i2]
-			fileList = append(fileList, info.Path)
-		}
-	}
-	logger.Info(fmt.Sprintf("得到的照片: %v", fileList))
+	// 默认增量索引，mode=full 时全部重新处理
+	full := c.DefaultQuery("mode", workflow.IndexModeIncremental) == workflow.IndexModeFull
 
-	// 启动后台任务，开始处理
-	for i := range fileList {
-		file := fileList[i]
-		h.imgContain.ImgTaskManager.AddTask(file)
+	// 获取可用路径下所有的照片【指定类型】，扫描在后台进行
+	logger.Info(fmt.Sprintf("检索的列表: %v", dirs))
+	job, err := h.imgContain.Indexer.StartIndex(dirs, full)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, model.Response{
 		Code:    http.StatusOK,
 		Message: "索引任务已启动",
+		Data: map[string]interface{}{
+			"job_id": job.ID,
+		},
 	})
 }
+
+// syncWatcher 同步文件监听，失败不影响存储库本身的修改
+func (h *LibraryHandler) syncWatcher() {
+	if err := h.imgContain.SyncWatcher(); err != nil {
+		logger.Error("文件监听同步失败!", zap.Error(err))
+	}
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 200
+)
+
+// getPagination 读取分页参数 page, page_size
+func getPagination(c *gin.Context) (page, pageSize int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err = strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	return page, min(pageSize, maxPageSize)
+}
+
+// getUintParam 读取路径中的数字 ID
+func getUintParam(c *gin.Context, name string) (uint, bool) {
+	value, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || value == 0 {
+		return 0, false
+	}
+	return uint(value), true
+}
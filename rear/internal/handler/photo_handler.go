@@ -0,0 +1,547 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"rear/internal/config"
+	"rear/internal/container"
+	"rear/internal/db"
+	"rear/internal/model"
+	"rear/internal/workflow"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 缩略图按内容 Hash 寻址，内容变化时 URL 和 ETag 都会变化，可以长期缓存
+const thumbnailCacheControl = "public, max-age=31536000, immutable"
+
+type PhotoHandler struct {
+	container  *container.DbContainer
+	imgContain *container.TaskContainer
+}
+
+func NewPhotoHandler(container *container.DbContainer, imgContain *container.TaskContainer) *PhotoHandler {
+	return &PhotoHandler{container: container, imgContain: imgContain}
+}
+
+// ListPhotos 照片列表，按游标分页
+// 参数：sort【capture_time、import_time、file_name、file_size】、order【asc、desc】、cursor、limit 和 parsePhotoFilter 中的筛选条件
+func (h *PhotoHandler) ListPhotos(c *gin.Context) {
+	filter, err := parsePhotoFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	query := model.PhotoQuery{
+		Filter: filter,
+		Sort:   c.DefaultQuery("sort", model.PhotoSortCaptureTime),
+		Desc:   !strings.EqualFold(c.Query("order"), "asc"),
+		Limit:  defaultPageSize,
+	}
+	switch query.Sort {
+	case model.PhotoSortCaptureTime, model.PhotoSortImportTime, model.PhotoSortFileName, model.PhotoSortFileSize:
+	default:
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid sort: %s", query.Sort),
+		})
+		return
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		query.Limit = min(limit, maxPageSize)
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		query.After, err = model.DecodePhotoCursor(cursor, query.Sort)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.Response{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	photos, hasMore, err := h.container.PhotoRepo.ListPhotos(&query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	items := make([]*model.PhotoListItem, 0, len(photos))
+	for i := range photos {
+		items = append(items, newPhotoItem(&photos[i]))
+	}
+	var nextCursor string
+	if hasMore {
+		nextCursor = model.NewPhotoCursor(query.Sort, &photos[len(photos)-1]).Encode()
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    http.StatusOK,
+		Message: "Success",
+		Data: map[string]interface{}{
+			"items":       items,
+			"next_cursor": nextCursor,
+			"has_more":    hasMore,
+		},
+	})
+}
+
+// SearchPhotos 全文检索照片，按 page, page_size 分页
+// 参数：q【空白分隔的多个词需同时命中】和 parsePhotoFilter 中的筛选条件
+func (h *PhotoHandler) SearchPhotos(c *gin.Context) {
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    http.StatusBadRequest,
+			Message: "q query parameter is required",
+		})
+		return
+	}
+	filter, err := parsePhotoFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+	page, pageSize := getPagination(c)
+
+	hits, hasMore, err := h.container.PhotoRepo.SearchPhotos(&model.PhotoSearch{
+		Text:   text,
+		Filter: filter,
+		Offset: (page - 1) * pageSize,
+		Limit:  pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	items := make([]*model.PhotoSearchItem, 0, len(hits))
+	for i := range hits {
+		items = append(items, &model.PhotoSearchItem{
+			PhotoListItem: newPhotoItem(&hits[i].Photo),
+			Score:         hits[i].Score,
+			Snippet:       hits[i].Snippet,
+		})
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    http.StatusOK,
+		Message: "Success",
+		Data: map[string]interface{}{
+			"items":     items,
+			"page":      page,
+			"page_size": pageSize,
+			"has_more":  hasMore,
+			"mode":      db.SearchMode(),
+		},
+	})
+}
+
+// Timeline 时间线，按拍摄日期统计照片数量并返回每个分组的封面
+// 参数：group【year、month、day，缺省为 month】、year、month【只统计该年或该月】、order【asc、desc】和 parsePhotoFilter 中的筛选条件
+func (h *PhotoHandler) Timeline(c *gin.Context) {
+	filter, err := parsePhotoFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+	query, err := parseTimelineQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+	query.Filter = filter
+
+	buckets, err := h.container.PhotoRepo.Timeline(&query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+	ids := make([]uint, 0, len(buckets))
+	for _, bucket := range buckets {
+		ids = append(ids, bucket.CoverID)
+	}
+	photos, err := h.container.PhotoRepo.GetListPhotosByIDs(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+	covers := make(map[uint]*model.PhotoListItem, len(photos))
+	for i := range photos {
+		covers[photos[i].ID] = newPhotoItem(&photos[i])
+	}
+
+	items := make([]model.TimelineItem, 0, len(buckets))
+	var total int64
+	for i := range buckets {
+		bucket := &buckets[i]
+		items = append(items, model.TimelineItem{
+			Key:   bucket.Key(),
+			Year:  bucket.Year,
+			Month: bucket.Month,
+			Day:   bucket.Day,
+			Count: bucket.Count,
+			Cover: covers[bucket.CoverID],
+		})
+		total += bucket.Count
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    http.StatusOK,
+		Message: "Success",
+		Data: map[string]interface{}{
+			"group": query.Group,
+			"items": items,
+			"total": total,
+		},
+	})
+}
+
+// parseTimelineQuery 解析时间线的分组粒度、年月范围和排序
+func parseTimelineQuery(c *gin.Context) (model.TimelineQuery, error) {
+	query := model.TimelineQuery{
+		Group: c.DefaultQuery("group", model.TimelineMonth),
+		Desc:  !strings.EqualFold(c.Query("order"), "asc"),
+	}
+	switch query.Group {
+	case model.TimelineYear, model.TimelineMonth, model.TimelineDay:
+	default:
+		return query, fmt.Errorf("invalid group: %s", query.Group)
+	}
+	var err error
+	if value := c.Query("year"); value != "" {
+		if query.Year, err = strconv.Atoi(value); err != nil || query.Year < 1 || query.Year > 9999 {
+			return query, fmt.Errorf("invalid year: %s", value)
+		}
+	}
+	if value := c.Query("month"); value != "" {
+		if query.Month, err = strconv.Atoi(value); err != nil || query.Month < 1 || query.Month > 12 {
+			return query, fmt.Errorf("invalid month: %s", value)
+		}
+		if query.Year == 0 {
+			return query, errors.New("month requires year")
+		}
+	}
+	return query, nil
+}
+
+// GetThumbnail 照片缩略图，size 为配置的缩略图尺寸之一，缺省为最小的尺寸
+// 缩略图尚未生成时同步生成，浏览不必等待索引完成
+func (h *PhotoHandler) GetThumbnail(c *gin.Context) {
+	id, ok := getUintParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid photo id",
+		})
+		return
+	}
+	photo, err := h.container.PhotoRepo.GetPhotoByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+	if photo == nil || photo.Hash == "" {
+		c.JSON(http.StatusNotFound, model.Response{
+			Code:    http.StatusNotFound,
+			Message: "Photo not found",
+		})
+		return
+	}
+	size, err := thumbnailSize(photo, c.Query("size"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// 内容、尺寸和格式确定后缩略图不会再变化，客户端已缓存时不必读取文件
+	etag := fmt.Sprintf(`"%s-%d-%s"`, photo.Hash, size, h.imgContain.Thumbnails.Format())
+	if etagMatch(c.GetHeader("If-None-Match"), etag) {
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", thumbnailCacheControl)
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	path, err := h.imgContain.Thumbnails.Get(c.Request.Context(), photo, size)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, workflow.ErrSourceMissing) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, model.Response{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", thumbnailCacheControl)
+	c.File(path)
+}
+
+// GetOriginal 原图，支持 Range 请求；download=1 时作为附件下载
+// 只返回解析符号链接后位于已启用存储库内的文件
+func (h *PhotoHandler) GetOriginal(c *gin.Context) {
+	id, ok := getUintParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid photo id",
+		})
+		return
+	}
+	photo, err := h.container.PhotoRepo.GetPhotoByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+	if photo == nil {
+		c.JSON(http.StatusNotFound, model.Response{
+			Code:    http.StatusNotFound,
+			Message: "Photo not found",
+		})
+		return
+	}
+
+	path, err := resolveLibraryFile(h.container.LibraryRepo, photo.Path)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			status = http.StatusNotFound
+		case errors.Is(err, errOutsideLibrary):
+			status = http.StatusForbidden
+		}
+		c.JSON(status, model.Response{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	contentType := photo.MIMEType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(photo.FileName))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := "inline"
+	if c.Query("download") == "1" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": photo.FileName}))
+	// 文件与索引时一致时 Hash 仍然有效，可作为 ETag 支持 If-Range 断点续传
+	if photo.Hash != "" && photo.Unchanged(info.Size(), info.ModTime()) {
+		c.Header("ETag", `"`+photo.Hash+`"`)
+	}
+	http.ServeContent(c.Writer, c.Request, photo.FileName, info.ModTime(), file)
+}
+
+// thumbnailSize 确定返回的缩略图尺寸
+// 请求的尺寸必须是配置的尺寸之一；大于原图的尺寸不会生成，改用不超过原图的最大尺寸
+func thumbnailSize(photo *model.Photo, value string) (int, error) {
+	available := workflow.ThumbnailSizes(model.DisplaySize(photo.Width, photo.Height, photo.Exif.Orientation))
+	if len(available) == 0 {
+		return 0, errors.New("no thumbnail size configured")
+	}
+	if value == "" {
+		return available[0], nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || !slices.Contains(config.CONFIG.ImageCompressionOption.ThumbnailSize, size) {
+		return 0, fmt.Errorf("invalid size: %s", value)
+	}
+	result := available[0]
+	for _, item := range available {
+		if item <= size {
+			result = item
+		}
+	}
+	return result, nil
+}
+
+// etagMatch If-None-Match 是否包含指定的 ETag【按弱比较，忽略 W/ 前缀】
+func etagMatch(header, etag string) bool {
+	for _, item := range strings.Split(header, ",") {
+		item = strings.TrimSpace(item)
+		if item == "*" || strings.TrimPrefix(item, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// newPhotoItem 构建列表项并填充各尺寸缩略图的 URL
+// URL 带上内容 Hash，文件内容变化后浏览器缓存自然失效
+func newPhotoItem(photo *model.Photo) *model.PhotoListItem {
+	item := model.NewPhotoListItem(photo)
+	item.Thumbnails = make(map[int]string)
+	for _, size := range workflow.ThumbnailSizes(item.Width, item.Height) {
+		item.Thumbnails[size] = thumbnailURL(photo, size)
+	}
+	return item
+}
+
+// thumbnailURL 缩略图地址
+func thumbnailURL(photo *model.Photo, size int) string {
+	version := photo.Hash
+	if len(version) > 12 {
+		version = version[:12]
+	}
+	return fmt.Sprintf("/api/v1/photos/%d/thumb?size=%d&v=%s", photo.ID, size, version)
+}
+
+// parsePhotoFilter 读取照片筛选参数：
+// library_id【可逗号分隔多个】、from、to【日期 2006-01-02 或 RFC3339，to 为日期时包含当天】、
+// make、model、lens、iso_min、iso_max、aperture_min、aperture_max、focal_min、focal_max、
+// format【可逗号分隔多个】、orientation【landscape、portrait、square】
+func parsePhotoFilter(c *gin.Context) (model.PhotoFilter, error) {
+	var filter model.PhotoFilter
+	var err error
+
+	for _, value := range splitQuery(c.Query("library_id")) {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || id == 0 {
+			return filter, fmt.Errorf("invalid library_id: %s", value)
+		}
+		filter.LibraryIDs = append(filter.LibraryIDs, uint(id))
+	}
+	if filter.From, err = parseTimeQuery(c, "from", false); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTimeQuery(c, "to", true); err != nil {
+		return filter, err
+	}
+
+	filter.Make = strings.TrimSpace(c.Query("make"))
+	filter.Model = strings.TrimSpace(c.Query("model"))
+	filter.LensID = strings.TrimSpace(c.Query("lens"))
+
+	ints := map[string]*int{"iso_min": &filter.ISOMin, "iso_max": &filter.ISOMax}
+	for name, target := range ints {
+		if value := c.Query(name); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil || *target < 0 {
+				return filter, fmt.Errorf("invalid %s: %s", name, value)
+			}
+		}
+	}
+	floats := map[string]*float64{
+		"aperture_min": &filter.ApertureMin,
+		"aperture_max": &filter.ApertureMax,
+		"focal_min":    &filter.FocalLengthMin,
+		"focal_max":    &filter.FocalLengthMax,
+	}
+	for name, target := range floats {
+		if value := c.Query(name); value != "" {
+			if *target, err = strconv.ParseFloat(value, 64); err != nil || *target < 0 {
+				return filter, fmt.Errorf("invalid %s: %s", name, value)
+			}
+		}
+	}
+
+	// 记录中的 FileType 为 exiftool 输出的大写格式名
+	for _, value := range splitQuery(c.Query("format")) {
+		filter.FileTypes = append(filter.FileTypes, strings.ToUpper(value))
+	}
+
+	filter.Orientation = strings.ToLower(c.Query("orientation"))
+	switch filter.Orientation {
+	case "", model.PhotoOrientationLandscape, model.PhotoOrientationPortrait, model.PhotoOrientationSquare:
+	default:
+		return filter, fmt.Errorf("invalid orientation: %s", filter.Orientation)
+	}
+	return filter, nil
+}
+
+// parseTimeQuery 读取时间参数，endOfDay 为 true 且只有日期时返回第二天零点【用作开区间的上限】
+func parseTimeQuery(c *gin.Context, name string, endOfDay bool) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// splitQuery 拆分逗号分隔的参数，忽略空项
+func splitQuery(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"rear/internal/config"
+	"rear/internal/model"
+	"testing"
+)
+
+func TestEtagMatch(t *testing.T) {
+	etag := `"abc-512-jpg"`
+	cases := map[string]bool{
+		"":                       false,
+		`"abc-512-jpg"`:          true,
+		`W/"abc-512-jpg"`:        true,
+		`"other", "abc-512-jpg"`: true,
+		`"abc-256-jpg"`:          false,
+		"*":                      true,
+		`"abc-512-jpg-and-more"`: false,
+	}
+	for header, want := range cases {
+		if got := etagMatch(header, etag); got != want {
+			t.Errorf("etagMatch(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestThumbnailSize(t *testing.T) {
+	config.CONFIG.ImageCompressionOption.ThumbnailSize = []int{256, 512, 720}
+	// 显示尺寸 600x400，720 不会生成
+	photo := &model.Photo{Width: 400, Height: 600, Exif: model.ExifInfo{Orientation: 6}}
+
+	cases := map[string]int{"": 256, "256": 256, "512": 512, "720": 512}
+	for value, want := range cases {
+		got, err := thumbnailSize(photo, value)
+		if err != nil || got != want {
+			t.Errorf("thumbnailSize(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"300", "abc", "-1"} {
+		if _, err := thumbnailSize(photo, value); err == nil {
+			t.Errorf("thumbnailSize(%q): want error", value)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"rear/internal/container"
+	"rear/internal/model"
+	"rear/internal/utils/tools"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 工具诊断的超时【需要依次执行各工具的版本和格式查询】
+const diagnoseTimeout = 30 * time.Second
+
+type SystemHandler struct {
+	imgContain *container.TaskContainer
+}
+
+func NewSystemHandler(imgContain *container.TaskContainer) *SystemHandler {
+	return &SystemHandler{imgContain: imgContain}
+}
+
+// GetTools 外部工具诊断：路径、版本、支持的格式和最近一次执行失败的记录
+func (h *SystemHandler) GetTools(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), diagnoseTimeout)
+	defer cancel()
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    http.StatusOK,
+		Message: "Success",
+		Data:    tools.Diagnose(ctx, h.imgContain.Tools),
+	})
+}
@@ -3,6 +3,8 @@ package model
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 )
 
 /*
@@ -50,8 +52,9 @@ type BaseImageInfo struct {
 }
 
 type ExifInfo struct {
-	Model        string  `json:"Model"`
-	Make         string  `json:"Make"`
+	// 相机和镜头建立索引，用于照片列表筛选
+	Model        string  `gorm:"index;size:255" json:"Model"`
+	Make         string  `gorm:"index;size:255" json:"Make"`
 	ISO          int     `json:"ISO"`
 	GPSLatitude  float64 `json:"GPSLatitude"`
 	GPSLongitude float64 `json:"GPSLongitude"`
@@ -59,10 +62,14 @@ type ExifInfo struct {
 	Aperture     float64 `json:"Aperture"`
 	FNumber      float64 `json:"FNumber"` // 光圈值
 	FocalLength  float64 `json:"FocalLength"`
-	LensID       string  `json:"LensID"`
+	LensID       string  `gorm:"index;size:255" json:"LensID"`
 	Title        string  `json:"Title"`
 	Description  string  `json:"Description"`
-	DateTimeOrig string  `json:"DateTimeOriginal"`
+	// 关键词和标签【IPTC Keywords、XMP Subject 和 TagsList 合并去重，逗号分隔】
+	Keywords     string `json:"Keywords"`
+	DateTimeOrig string `json:"DateTimeOriginal"`
+	// 方向【1-8，5-8 需要宽高互换】
+	Orientation int `json:"Orientation"`
 }
 
 type ParsedExif struct {
@@ -218,9 +225,13 @@ func SplitExifData(data map[string]interface{}) *ParsedExif {
 	if v, ok := data["Description"]; ok {
 		exif.Description = safeStringConvert(v)
 	}
+	exif.Keywords = joinKeywords(data["Keywords"], data["Subject"], data["TagsList"])
 	if v, ok := data["DateTimeOriginal"]; ok {
 		exif.DateTimeOrig = safeStringConvert(v)
 	}
+	if v, ok := data["Orientation"]; ok {
+		exif.Orientation = safeIntConvert(v)
+	}
 
 	// 定义已处理的字段
 	processedFields := map[string]bool{
@@ -231,6 +242,7 @@ func SplitExifData(data map[string]interface{}) *ParsedExif {
 		"Model": true, "Make": true, "ISO": true, "GPSLatitude": true, "GPSLongitude": true,
 		"ExposureTime": true, "Aperture": true, "FNumber": true, "FocalLength": true,
 		"LensID": true, "Title": true, "Description": true, "DateTimeOriginal": true,
+		"Orientation": true, "Keywords": true, "Subject": true, "TagsList": true,
 	}
 
 	// 提取剩余字段
@@ -248,6 +260,61 @@ func SplitExifData(data map[string]interface{}) *ParsedExif {
 	}
 }
 
+// joinKeywords 合并多个关键词字段并去重，exiftool 输出单个关键词时为字符串，多个时为数组
+func joinKeywords(values ...interface{}) string {
+	var keywords []string
+	seen := make(map[string]bool)
+	add := func(v interface{}) {
+		keyword := strings.TrimSpace(safeStringConvert(v))
+		if keyword != "" && !seen[keyword] {
+			seen[keyword] = true
+			keywords = append(keywords, keyword)
+		}
+	}
+	for _, value := range values {
+		if list, ok := value.([]interface{}); ok {
+			for _, item := range list {
+				add(item)
+			}
+		} else if value != nil {
+			add(value)
+		}
+	}
+	return strings.Join(keywords, ", ")
+}
+
+// DisplaySize 根据方向返回实际显示的宽高
+func DisplaySize(width, height, orientation int) (int, int) {
+	if orientation >= 5 && orientation <= 8 {
+		return height, width
+	}
+	return width, height
+}
+
+// exif 时间格式【exiftool 输出的日期不受 -n 影响】
+var exifTimeLayouts = []string{
+	"2006:01:02 15:04:05Z07:00",
+	"2006:01:02 15:04:05.999999999Z07:00",
+	"2006:01:02 15:04:05",
+	"2006:01:02 15:04:05.999999999",
+	"2006:01:02",
+}
+
+// ParseExifTime 解析 EXIF 中的时间字符串，无时区信息时按本地时间处理
+func ParseExifTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	// 部分相机未设置时间时写入全 0
+	if value == "" || strings.HasPrefix(value, "0000") {
+		return time.Time{}, false
+	}
+	for _, layout := range exifTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
 // 辅助函数：判断字符串是否在切片中
 func isIn(str string, slice ...string) bool {
 	for _, s := range slice {
@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+// 索引任务状态
+const (
+	JobStatusScanning    = "scanning"
+	JobStatusRunning     = "running"
+	JobStatusDone        = "done"
+	JobStatusFailed      = "failed"
+	JobStatusInterrupted = "interrupted"
+	JobStatusCanceled    = "canceled"
+)
+
+// IndexJob 一次存储库索引任务
+type IndexJob struct {
+	BaseModel
+	// 任务状态
+	Status string `gorm:"index;size:20" json:"status"`
+	// 索引模式【incremental, full】
+	Mode string `gorm:"size:20" json:"mode"`
+	// 扫描到的文件总数
+	Total int `json:"total"`
+	// 加入处理队列的文件数
+	Queued int `json:"queued"`
+	// 处理成功
+	Done int `json:"done"`
+	// 处理失败
+	Failed int `json:"failed"`
+	// 跳过【文件未变化或仅被移动】
+	Skipped int `json:"skipped"`
+	// 取消
+	Canceled int `json:"canceled"`
+	// 新标记为缺失的记录数
+	Missing int `json:"missing"`
+	// 是否已暂停【暂停不改变任务状态】
+	Paused bool `json:"paused"`
+	// 任务级错误信息
+	Message    string     `gorm:"size:1024" json:"message"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// Processed 已处理的文件数
+func (j *IndexJob) Processed() int {
+	return j.Done + j.Failed + j.Skipped + j.Canceled
+}
+
+// Active 任务是否还在进行中
+func (j *IndexJob) Active() bool {
+	return j.Status == JobStatusScanning || j.Status == JobStatusRunning
+}
+
+// IndexJobError 索引任务中单个文件的错误
+type IndexJobError struct {
+	BaseModel
+	JobID uint   `gorm:"index;not null" json:"job_id"`
+	Path  string `gorm:"size:1024" json:"path"`
+	Error string `gorm:"size:2048" json:"error"`
+}
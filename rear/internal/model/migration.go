@@ -0,0 +1,9 @@
+package model
+
+import "time"
+
+// Migration 已完成的一次性数据迁移【如为旧记录补全新增列】，启动时据此跳过
+type Migration struct {
+	Name      string    `gorm:"primaryKey;size:100" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 拍摄时间来源
+const (
+	CaptureTimeFromExif = "exif"
+	CaptureTimeFromFile = "file"
+)
+
+// Photo 已索引的照片（媒体资源）
+type Photo struct {
+	BaseModel
+	// 所属存储库【与各排序列组成联合索引，按存储库筛选时仍可按排序列的索引顺序分页】
+	LibraryID uint         `gorm:"index;index:idx_photos_library_capture,priority:1;index:idx_photos_library_name,priority:1;index:idx_photos_library_size,priority:1;not null" json:"library_id"`
+	Library   LibraryTable `gorm:"foreignKey:LibraryID;constraint:OnDelete:CASCADE" json:"-"`
+	// 文件完整路径
+	Path string `gorm:"uniqueIndex;not null;size:768" json:"path"`
+	// 所在目录
+	Dir string `gorm:"index;size:768" json:"dir"`
+	// 文件名
+	FileName string `gorm:"index;index:idx_photos_library_name,priority:2;size:255" json:"file_name"`
+	// 文件内容 SHA-256
+	Hash string `gorm:"index;size:64" json:"hash"`
+	// 文件大小（字节）
+	FileSize int64 `gorm:"index;index:idx_photos_library_size,priority:2" json:"file_size"`
+	// 文件最后修改时间
+	FileModTime time.Time `json:"file_mod_time"`
+	// 文件已不存在【重新索引时标记】
+	Missing bool `gorm:"index;default:false" json:"missing"`
+	// 像素尺寸
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// 文件类型
+	MIMEType string `gorm:"size:100" json:"mime_type"`
+	FileType string `gorm:"size:20" json:"file_type"`
+	// 拍摄时间【无 EXIF 时间则回退到文件修改时间】
+	CaptureTime       time.Time `gorm:"index;index:idx_photos_library_capture,priority:2" json:"capture_time"`
+	CaptureTimeSource string    `gorm:"size:10" json:"capture_time_source"`
+	// 拍摄日期 YYYYMMDD，无拍摄时间时为 0【时间线按年月日分组，索引见 db.createTimelineIndexes】
+	CaptureDay int `gorm:"not null;default:0" json:"capture_day"`
+	// 检索文本中所有 1、2 个字符的片段，以空格分隔【少于 3 个字符的检索词使用，由 UpdateSearchGrams 生成】
+	SearchGrams string `gorm:"type:text" json:"-"`
+
+	// 解析后的 EXIF 字段
+	BaseInfo BaseImageInfo `gorm:"embedded;embeddedPrefix:base_" json:"base_info"`
+	Exif     ExifInfo      `gorm:"embedded;embeddedPrefix:exif_" json:"exif"`
+}
+
+// Unchanged 文件大小和修改时间都与记录一致时认为文件未变化
+// 修改时间按秒比较，避免不同数据库时间精度不一致
+func (p *Photo) Unchanged(size int64, modTime time.Time) bool {
+	return !p.Missing && p.FileSize == size && p.FileModTime.Unix() == modTime.Unix()
+}
+
+// NewPhotoFromExif 根据解析后的 EXIF 数据构建 Photo
+func NewPhotoFromExif(libraryID uint, path string, hash string, parsed *ParsedExif) *Photo {
+	photo := &Photo{
+		LibraryID: libraryID,
+		Path:      path,
+		Dir:       filepath.Dir(path),
+		FileName:  filepath.Base(path),
+		Hash:      hash,
+		BaseInfo:  parsed.BaseInfo,
+		Exif:      parsed.Exif,
+		FileSize:  parsed.BaseInfo.FileSize,
+		Width:     parsed.BaseInfo.ImageWidth,
+		Height:    parsed.BaseInfo.ImageHeight,
+		MIMEType:  parsed.BaseInfo.MIMEType,
+		FileType:  parsed.BaseInfo.FileType,
+	}
+
+	if t, ok := ParseExifTime(parsed.Exif.DateTimeOrig); ok {
+		photo.SetCaptureTime(t, CaptureTimeFromExif)
+	} else if t, ok := ParseExifTime(parsed.BaseInfo.ModifyDate); ok {
+		photo.SetCaptureTime(t, CaptureTimeFromFile)
+	}
+	return photo
+}
+
+// SetCaptureTime 设置拍摄时间及其来源，同时更新拍摄日期
+func (p *Photo) SetCaptureTime(t time.Time, source string) {
+	p.CaptureTime = t
+	p.CaptureTimeSource = source
+	p.CaptureDay = CaptureDayOf(t)
+}
+
+// CaptureDayOf 时间所在的日期 YYYYMMDD，零值返回 0
+// 按时间自身的时区计算，即拍摄地的日期【EXIF 无时区信息时为本地时间】
+func CaptureDayOf(t time.Time) int {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Year()*10000 + int(t.Month())*100 + t.Day()
+}
+
+// UpdateSearchGrams 根据参与检索的字段重新生成 SearchGrams，字段变化后保存前调用
+func (p *Photo) UpdateSearchGrams() {
+	seen := make(map[string]bool)
+	var grams []string
+	add := func(gram string) {
+		if !seen[gram] {
+			seen[gram] = true
+			grams = append(grams, gram)
+		}
+	}
+	fields := []string{p.FileName, p.Dir, p.Exif.Title, p.Exif.Description, p.Exif.Keywords, p.Exif.Make, p.Exif.Model, p.Exif.LensID}
+	for _, field := range fields {
+		for _, run := range SearchGramRuns(field) {
+			runes := []rune(run)
+			for i := range runes {
+				add(string(runes[i]))
+				if i+1 < len(runes) {
+					add(string(runes[i : i+2]))
+				}
+			}
+		}
+	}
+	p.SearchGrams = strings.Join(grams, " ")
+}
+
+// SearchGramRuns 按字母、数字拆分文本并转为小写，片段只在同一段内生成
+func SearchGramRuns(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+}
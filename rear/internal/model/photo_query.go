@@ -0,0 +1,264 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 照片列表排序字段
+const (
+	// 拍摄时间
+	PhotoSortCaptureTime = "capture_time"
+	// 导入时间【ID 按导入顺序自增，直接按 ID 排序】
+	PhotoSortImportTime = "import_time"
+	PhotoSortFileName   = "file_name"
+	PhotoSortFileSize   = "file_size"
+)
+
+// 照片列表的画面方向筛选【按 EXIF 方向修正后的显示尺寸】
+const (
+	PhotoOrientationLandscape = "landscape"
+	PhotoOrientationPortrait  = "portrait"
+	PhotoOrientationSquare    = "square"
+)
+
+// ErrInvalidCursor 游标无法解析或与排序字段不匹配
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PhotoFilter 照片筛选条件，零值表示不限制
+type PhotoFilter struct {
+	LibraryIDs []uint
+	// 拍摄时间范围 [From, To)
+	From *time.Time
+	To   *time.Time
+	// 相机和镜头【精确匹配】
+	Make   string
+	Model  string
+	LensID string
+	// 数值范围【闭区间】
+	ISOMin         int
+	ISOMax         int
+	ApertureMin    float64
+	ApertureMax    float64
+	FocalLengthMin float64
+	FocalLengthMax float64
+	// 文件格式，如 JPEG、HEIC、NEF
+	FileTypes []string
+	// 画面方向，见 PhotoOrientation*
+	Orientation string
+}
+
+// PhotoCursor 游标分页位置：上一页最后一条记录的排序值和 ID
+type PhotoCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v,omitempty"`
+	ID    uint            `json:"id"`
+}
+
+// PhotoQuery 照片列表查询
+type PhotoQuery struct {
+	Filter PhotoFilter
+	Sort   string
+	Desc   bool
+	// 为空表示第一页
+	After *PhotoCursor
+	Limit int
+}
+
+// NewPhotoCursor 根据记录生成排序对应的游标
+func NewPhotoCursor(sort string, photo *Photo) *PhotoCursor {
+	var value interface{}
+	switch sort {
+	case PhotoSortCaptureTime:
+		value = photo.CaptureTime
+	case PhotoSortFileName:
+		value = photo.FileName
+	case PhotoSortFileSize:
+		value = photo.FileSize
+	}
+	cursor := &PhotoCursor{Sort: sort, ID: photo.ID}
+	if value != nil {
+		cursor.Value, _ = json.Marshal(value)
+	}
+	return cursor
+}
+
+// Encode 编码为 URL 安全的字符串
+func (c *PhotoCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodePhotoCursor 解析游标，排序字段必须与当前查询一致
+func DecodePhotoCursor(value, sort string) (*PhotoCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor PhotoCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := cursor.SortValue(); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// SortValue 游标中排序字段的值，按 ID 排序时为 nil
+func (c *PhotoCursor) SortValue() (interface{}, error) {
+	var err error
+	switch c.Sort {
+	case PhotoSortCaptureTime:
+		var t time.Time
+		err = json.Unmarshal(c.Value, &t)
+		return t, wrapCursorError(err)
+	case PhotoSortFileName:
+		var s string
+		err = json.Unmarshal(c.Value, &s)
+		return s, wrapCursorError(err)
+	case PhotoSortFileSize:
+		var n int64
+		err = json.Unmarshal(c.Value, &n)
+		return n, wrapCursorError(err)
+	case PhotoSortImportTime:
+		return nil, nil
+	}
+	return nil, ErrInvalidCursor
+}
+
+func wrapCursorError(err error) error {
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// PhotoListItem 照片列表中的单条记录
+type PhotoListItem struct {
+	ID          uint      `json:"id"`
+	LibraryID   uint      `json:"library_id"`
+	FileName    string    `json:"file_name"`
+	Hash        string    `json:"hash"`
+	FileSize    int64     `json:"file_size"`
+	FileType    string    `json:"file_type"`
+	MIMEType    string    `json:"mime_type"`
+	CaptureTime time.Time `json:"capture_time"`
+	ImportTime  time.Time `json:"import_time"`
+	// 按 EXIF 方向修正后的显示尺寸和宽高比
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	AspectRatio float64 `json:"aspect_ratio"`
+	Make        string  `json:"make"`
+	Model       string  `json:"model"`
+	LensID      string  `json:"lens_id"`
+	ISO         int     `json:"iso"`
+	FNumber     float64 `json:"f_number"`
+	FocalLength float64 `json:"focal_length"`
+	// 缩略图尺寸 -> URL
+	Thumbnails map[int]string `json:"thumbnails"`
+}
+
+// NewPhotoListItem 由照片记录构建列表项，缩略图 URL 由调用方填充
+func NewPhotoListItem(photo *Photo) *PhotoListItem {
+	width, height := DisplaySize(photo.Width, photo.Height, photo.Exif.Orientation)
+	item := &PhotoListItem{
+		ID:          photo.ID,
+		LibraryID:   photo.LibraryID,
+		FileName:    photo.FileName,
+		Hash:        photo.Hash,
+		FileSize:    photo.FileSize,
+		FileType:    photo.FileType,
+		MIMEType:    photo.MIMEType,
+		CaptureTime: photo.CaptureTime,
+		ImportTime:  photo.CreatedAt,
+		Width:       width,
+		Height:      height,
+		Make:        photo.Exif.Make,
+		Model:       photo.Exif.Model,
+		LensID:      photo.Exif.LensID,
+		ISO:         photo.Exif.ISO,
+		FNumber:     photo.Exif.FNumber,
+		FocalLength: photo.Exif.FocalLength,
+	}
+	if width > 0 && height > 0 {
+		item.AspectRatio = float64(width) / float64(height)
+	}
+	return item
+}
+
+// PhotoSearch 照片全文检索
+type PhotoSearch struct {
+	// 检索词，空白分隔的多个词需同时命中
+	Text   string
+	Filter PhotoFilter
+	Offset int
+	Limit  int
+}
+
+// PhotoSearchHit 检索命中的照片
+type PhotoSearchHit struct {
+	Photo Photo
+	// 相关度，越大越相关【不支持全文索引时为 0，按拍摄时间排序】
+	Score float64
+	// 命中片段，命中的文字以 <mark> 标记，其余内容已做 HTML 转义
+	Snippet string
+}
+
+// PhotoSearchItem 检索结果中的单条记录
+type PhotoSearchItem struct {
+	*PhotoListItem
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// 时间线分组粒度
+const (
+	TimelineYear  = "year"
+	TimelineMonth = "month"
+	TimelineDay   = "day"
+)
+
+// TimelineQuery 时间线统计
+type TimelineQuery struct {
+	Filter PhotoFilter
+	Group  string
+	// 只统计指定年份或月份【0 表示不限制，Month 需与 Year 同时指定】
+	Year  int
+	Month int
+	Desc  bool
+}
+
+// TimelineBucket 时间线中的一个分组，按月或按年分组时 Day 或 Month 为 0
+type TimelineBucket struct {
+	Year  int
+	Month int
+	Day   int
+	Count int64
+	// 封面照片 ID
+	CoverID uint
+}
+
+// Key 分组的日期字符串，如 2023、2023-05、2023-05-01
+func (b *TimelineBucket) Key() string {
+	switch {
+	case b.Month == 0:
+		return fmt.Sprintf("%04d", b.Year)
+	case b.Day == 0:
+		return fmt.Sprintf("%04d-%02d", b.Year, b.Month)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", b.Year, b.Month, b.Day)
+}
+
+// TimelineItem 时间线接口返回的分组
+type TimelineItem struct {
+	Key   string         `json:"key"`
+	Year  int            `json:"year"`
+	Month int            `json:"month,omitempty"`
+	Day   int            `json:"day,omitempty"`
+	Count int64          `json:"count"`
+	Cover *PhotoListItem `json:"cover"`
+}
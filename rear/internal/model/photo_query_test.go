@@ -0,0 +1,81 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPhotoCursorRoundTrip(t *testing.T) {
+	captured := time.Date(2023, 5, 1, 12, 30, 0, 0, time.FixedZone("", 8*3600))
+	photo := &Photo{BaseModel: BaseModel{ID: 42}, CaptureTime: captured, FileName: "a.jpg", FileSize: 1024}
+
+	cases := map[string]interface{}{
+		PhotoSortCaptureTime: captured,
+		PhotoSortFileName:    "a.jpg",
+		PhotoSortFileSize:    int64(1024),
+		PhotoSortImportTime:  nil,
+	}
+	for sort, want := range cases {
+		encoded := NewPhotoCursor(sort, photo).Encode()
+		cursor, err := DecodePhotoCursor(encoded, sort)
+		if err != nil {
+			t.Fatalf("%s: %v", sort, err)
+		}
+		if cursor.ID != 42 {
+			t.Errorf("%s: id = %d", sort, cursor.ID)
+		}
+		value, err := cursor.SortValue()
+		if err != nil {
+			t.Fatalf("%s: %v", sort, err)
+		}
+		if got, ok := value.(time.Time); ok {
+			if !got.Equal(captured) {
+				t.Errorf("%s: value = %v, want %v", sort, got, captured)
+			}
+		} else if value != want {
+			t.Errorf("%s: value = %v, want %v", sort, value, want)
+		}
+	}
+}
+
+func TestDecodePhotoCursorInvalid(t *testing.T) {
+	photo := &Photo{BaseModel: BaseModel{ID: 1}, FileName: "a.jpg"}
+	encoded := NewPhotoCursor(PhotoSortFileName, photo).Encode()
+	// 排序字段与游标不一致
+	if _, err := DecodePhotoCursor(encoded, PhotoSortFileSize); err != ErrInvalidCursor {
+		t.Errorf("mismatched sort: err = %v", err)
+	}
+	if _, err := DecodePhotoCursor("not a cursor", PhotoSortFileName); err != ErrInvalidCursor {
+		t.Errorf("garbage: err = %v", err)
+	}
+}
+
+func TestNewPhotoListItemAspectRatio(t *testing.T) {
+	photo := &Photo{Width: 4000, Height: 3000, Exif: ExifInfo{Orientation: 6}}
+	item := NewPhotoListItem(photo)
+	if item.Width != 3000 || item.Height != 4000 || item.AspectRatio != 0.75 {
+		t.Errorf("item = %dx%d %.2f", item.Width, item.Height, item.AspectRatio)
+	}
+}
+
+func TestCaptureDayAndTimelineKey(t *testing.T) {
+	// 按时间自身的时区取日期
+	captured := time.Date(2020, 1, 1, 1, 0, 0, 0, time.FixedZone("", 8*3600))
+	if day := CaptureDayOf(captured); day != 20200101 {
+		t.Errorf("CaptureDayOf = %d, want 20200101", day)
+	}
+	if day := CaptureDayOf(time.Time{}); day != 0 {
+		t.Errorf("CaptureDayOf(zero) = %d, want 0", day)
+	}
+
+	cases := map[string]TimelineBucket{
+		"2020":       {Year: 2020},
+		"2020-03":    {Year: 2020, Month: 3},
+		"2020-03-05": {Year: 2020, Month: 3, Day: 5},
+	}
+	for want, bucket := range cases {
+		if key := bucket.Key(); key != want {
+			t.Errorf("Key = %s, want %s", key, want)
+		}
+	}
+}
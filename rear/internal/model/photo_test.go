@@ -0,0 +1,40 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchGramRuns(t *testing.T) {
+	got := SearchGramRuns("IMG_0012.JPG 北京·故宫")
+	want := []string{"img", "0012", "jpg", "北京", "故宫"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchGramRuns = %q, want %q", got, want)
+	}
+}
+
+func TestUpdateSearchGrams(t *testing.T) {
+	photo := &Photo{FileName: "a_b.jpg", Exif: ExifInfo{Title: "北京故宫", Make: "Canon"}}
+	photo.UpdateSearchGrams()
+	grams := strings.Fields(photo.SearchGrams)
+
+	set := make(map[string]bool, len(grams))
+	for _, gram := range grams {
+		if set[gram] {
+			t.Errorf("duplicate gram %q", gram)
+		}
+		set[gram] = true
+	}
+	// 每段内的单字和相邻两字，不跨越标点
+	for _, gram := range []string{"北", "北京", "京故", "宫", "a", "b", "jp", "ca", "no", "n"} {
+		if !set[gram] {
+			t.Errorf("missing gram %q in %q", gram, photo.SearchGrams)
+		}
+	}
+	for _, gram := range []string{"ab", "北京故", "Ca", "_"} {
+		if set[gram] {
+			t.Errorf("unexpected gram %q", gram)
+		}
+	}
+}
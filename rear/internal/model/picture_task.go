@@ -0,0 +1,20 @@
+package model
+
+// PictureTaskRecord 持久化的照片处理任务，进程重启后据此恢复队列
+// 处理成功或跳过的任务会被删除，只保留排队中、执行中和失败的任务
+type PictureTaskRecord struct {
+	BaseModel
+	// 任务 ID【uuid】
+	TaskID    string `gorm:"uniqueIndex;size:36;not null" json:"task_id"`
+	Path      string `gorm:"size:1024;not null" json:"path"`
+	JobID     uint   `gorm:"index" json:"job_id"`
+	LibraryID uint   `json:"library_id"`
+	Force     bool   `json:"force"`
+	// 任务状态【pending, running, paused, failed】，failed 即死信列表
+	Status string `gorm:"index;size:20" json:"status"`
+	Error  string `gorm:"size:2048" json:"error"`
+	// 错误分类【transient, corrupt, tool_missing, unknown】
+	ErrorClass string `gorm:"size:20" json:"error_class"`
+	// 已执行次数
+	Attempts int `json:"attempts"`
+}
@@ -0,0 +1,17 @@
+package model
+
+// Thumbnail 缩略图记录【按内容 Hash 存储，相同内容的照片共用】
+type Thumbnail struct {
+	BaseModel
+	// 原图内容 SHA-256
+	Hash string `gorm:"uniqueIndex:idx_thumb_hash_size_format;not null;size:64" json:"hash"`
+	// 缩略图最长边
+	Size int `gorm:"uniqueIndex:idx_thumb_hash_size_format;not null" json:"size"`
+	// 缩略图格式
+	Format string `gorm:"uniqueIndex:idx_thumb_hash_size_format;not null;size:10" json:"format"`
+	// 缩略图文件路径
+	Path string `gorm:"not null;size:1024" json:"-"`
+	// 实际像素尺寸
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
@@ -0,0 +1,22 @@
+package model
+
+// 工具输出缓存的类型
+const (
+	ToolCacheExif      = "exif"
+	ToolCacheThumbnail = "thumbnail"
+)
+
+// ToolCache 外部工具输出缓存【按内容 Hash 存储，相同内容的文件不再重复处理】
+type ToolCache struct {
+	BaseModel
+	// 文件内容 SHA-256
+	Hash string `gorm:"uniqueIndex:idx_tool_cache_key;not null;size:64" json:"hash"`
+	// 缓存类型
+	Tool string `gorm:"uniqueIndex:idx_tool_cache_key;not null;size:20" json:"tool"`
+	// 处理参数的版本，参数变化后不再命中
+	ArgsVersion string `gorm:"uniqueIndex:idx_tool_cache_key;not null;size:64" json:"args_version"`
+	// 生成结果的工具版本，工具升级后失效
+	ToolVersion string `gorm:"size:255" json:"tool_version"`
+	// 缓存内容【JSON】
+	Data string `gorm:"size:16777216" json:"-"`
+}
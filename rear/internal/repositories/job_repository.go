@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"errors"
+	"rear/internal/db"
+	"rear/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type JobRepository struct{}
+
+func NewJobRepository() *JobRepository {
+	return &JobRepository{}
+}
+
+// CreateJob 创建索引任务（写操作）
+func (s *JobRepository) CreateJob(job *model.IndexJob) error {
+	return ExecuteWrite(func() error {
+		return db.GetDB().Create(job).Error
+	})
+}
+
+// UpdateJob 更新索引任务字段（写操作）
+func (s *JobRepository) UpdateJob(id uint, updates map[string]interface{}) error {
+	return ExecuteWrite(func() error {
+		return db.GetDB().Model(&model.IndexJob{}).
+			Where("id = ?", id).
+			Updates(updates).Error
+	})
+}
+
+// UpdateJobIf 任务处于指定状态时才更新，返回是否更新成功（写操作）
+func (s *JobRepository) UpdateJobIf(id uint, statuses []string, updates map[string]interface{}) (bool, error) {
+	var affected int64
+	err := ExecuteWrite(func() error {
+		result := db.GetDB().Model(&model.IndexJob{}).
+			Where("id = ? AND status IN ?", id, statuses).
+			Updates(updates)
+		affected = result.RowsAffected
+		return result.Error
+	})
+	return affected > 0, err
+}
+
+// IncrementCounter 累加计数字段【done, failed, skipped, canceled】（写操作）
+func (s *JobRepository) IncrementCounter(id uint, column string) error {
+	return ExecuteWrite(func() error {
+		return db.GetDB().Model(&model.IndexJob{}).
+			Where("id = ?", id).
+			UpdateColumn(column, gorm.Expr(column+" + 1")).Error
+	})
+}
+
+// FinishIfComplete 所有文件都处理完后将任务标记为完成（写操作）
+func (s *JobRepository) FinishIfComplete(id uint) error {
+	return ExecuteWrite(func() error {
+		return db.GetDB().Model(&model.IndexJob{}).
+			Where("id = ? AND status = ? AND done + failed + skipped + canceled >= total", id, model.JobStatusRunning).
+			Updates(map[string]interface{}{
+				"status":      model.JobStatusDone,
+				"finished_at": time.Now(),
+			}).Error
+	})
+}
+
+// MarkInterrupted 将上次运行中未结束的任务标记为中断，resumed 中的处理中任务除外（写操作）
+func (s *JobRepository) MarkInterrupted(resumed []uint) error {
+	return ExecuteWrite(func() error {
+		query := db.GetDB().Model(&model.IndexJob{})
+		if len(resumed) == 0 {
+			query = query.Where("status IN ?", []string{model.JobStatusScanning, model.JobStatusRunning})
+		} else {
+			query = query.Where("status = ? OR (status = ? AND id NOT IN ?)",
+				model.JobStatusScanning, model.JobStatusRunning, resumed)
+		}
+		return query.Updates(map[string]interface{}{
+			"status":      model.JobStatusInterrupted,
+			"finished_at": time.Now(),
+		}).Error
+	})
+}
+
+// AddError 记录文件错误（写操作）
+func (s *JobRepository) AddError(jobErr *model.IndexJobError) error {
+	return ExecuteWrite(func() error {
+		return db.GetDB().Create(jobErr).Error
+	})
+}
+
+// ============ 读操作（可以并发）============
+
+// GetJobByID 根据 ID 获取索引任务
+func (s *JobRepository) GetJobByID(id uint) (*model.IndexJob, error) {
+	var job model.IndexJob
+	err := ExecuteRead(func() error {
+		return db.GetDB().First(&job, id).Error
+	})
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// GetPausedJobs 获取已暂停且未结束的任务
+func (s *JobRepository) GetPausedJobs() ([]model.IndexJob, error) {
+	var jobs []model.IndexJob
+	err := ExecuteRead(func() error {
+		return db.GetDB().
+			Where("paused = ? AND status IN ?", true, []string{model.JobStatusScanning, model.JobStatusRunning}).
+			Find(&jobs).Error
+	})
+
+	return jobs, err
+}
+
+// GetJobsPaginated 分页获取索引任务，最新的在前
+func (s *JobRepository) GetJobsPaginated(offset, limit int) ([]model.IndexJob, int64, error) {
+	var jobs []model.IndexJob
+	var total int64
+
+	err := ExecuteRead(func() error {
+		if err := db.GetDB().Model(&model.IndexJob{}).Count(&total).Error; err != nil {
+			return err
+		}
+		return db.GetDB().Order("id DESC").Offset(offset).Limit(limit).Find(&jobs).Error
+	})
+
+	return jobs, total, err
+}
+
+// GetErrorsPaginated 分页获取索引任务的文件错误
+func (s *JobRepository) GetErrorsPaginated(jobID uint, offset, limit int) ([]model.IndexJobError, int64, error) {
+	var jobErrors []model.IndexJobError
+	var total int64
+
+	err := ExecuteRead(func() error {
+		if err := db.GetDB().Model(&model.IndexJobError{}).Where("job_id = ?", jobID).Count(&total).Error; err != nil {
+			return err
+		}
+		return db.GetDB().Where("job_id = ?", jobID).Order("id").Offset(offset).Limit(limit).Find(&jobErrors).Error
+	})
+
+	return jobErrors, total, err
+}
@@ -67,3 +67,13 @@ func (s *LibraryRepository) GetAllLibrary() ([]model.LibraryTable, error) {
 
 	return library, err
 }
+
+// GetEnabledLibraries 获取所有已启用的存储库
+func (s *LibraryRepository) GetEnabledLibraries() ([]model.LibraryTable, error) {
+	var libraries []model.LibraryTable
+	err := ExecuteRead(func() error {
+		return db.GetDB().Where("is_enable = ?", true).Find(&libraries).Error
+	})
+
+	return libraries, err
+}
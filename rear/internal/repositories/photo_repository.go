@@ -0,0 +1,298 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"rear/internal/db"
+	"rear/internal/model"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PhotoRepository struct{}
+
+func NewPhotoRepository() *PhotoRepository {
+	return &PhotoRepository{}
+}
+
+// SavePhoto 保存照片信息，同一路径已存在时覆盖原记录（写操作）
+func (s *PhotoRepository) SavePhoto(photo *model.Photo) error {
+	photo.UpdateSearchGrams()
+	return ExecuteWrite(func() error {
+		var existing model.Photo
+		// 包含软删除的记录，避免路径唯一索引冲突
+		err := db.GetDB().Unscoped().Where("path = ?", photo.Path).First(&existing).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if existing.ID == 0 {
+			return db.GetDB().Create(photo).Error
+		}
+
+		photo.ID = existing.ID
+		photo.CreatedAt = existing.CreatedAt
+		photo.DeletedAt = gorm.DeletedAt{}
+		return db.GetDB().Unscoped().Save(photo).Error
+	})
+}
+
+// MovePhoto 文件被移动时更新原记录的路径（写操作）
+func (s *PhotoRepository) MovePhoto(id uint, libraryID uint, path string, size int64, modTime time.Time) error {
+	return ExecuteWrite(func() error {
+		return db.GetDB().Transaction(func(tx *gorm.DB) error {
+			// 新路径上残留的旧记录（含软删除）会与路径唯一索引冲突，先清理
+			if err := tx.Unscoped().Where("path = ? AND id <> ?", path, id).Delete(&model.Photo{}).Error; err != nil {
+				return err
+			}
+			// 目录和文件名参与检索，重新生成检索片段
+			var photo model.Photo
+			if err := tx.First(&photo, id).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil
+				}
+				return err
+			}
+			photo.Dir, photo.FileName = filepath.Dir(path), filepath.Base(path)
+			photo.UpdateSearchGrams()
+			return tx.Model(&model.Photo{}).Where("id = ?", id).Updates(map[string]interface{}{
+				"library_id":    libraryID,
+				"path":          path,
+				"dir":           photo.Dir,
+				"file_name":     photo.FileName,
+				"file_size":     size,
+				"file_mod_time": modTime,
+				"missing":       false,
+				"search_grams":  photo.SearchGrams,
+			}).Error
+		})
+	})
+}
+
+// MarkMissing 将文件已不存在的照片标记为缺失（写操作）
+func (s *PhotoRepository) MarkMissing(ids []uint) error {
+	const batchSize = 500
+	for start := 0; start < len(ids); start += batchSize {
+		end := min(start+batchSize, len(ids))
+		batch := ids[start:end]
+		err := ExecuteWrite(func() error {
+			return db.GetDB().Model(&model.Photo{}).
+				Where("id IN ?", batch).
+				Update("missing", true).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkMissingByPath 将指定文件或目录下的照片标记为缺失（写操作）
+func (s *PhotoRepository) MarkMissingByPath(path string) error {
+	prefix := escapeLike(path+string(filepath.Separator)) + "%"
+	return ExecuteWrite(func() error {
+		return db.GetDB().Model(&model.Photo{}).
+			Where("path = ? OR path LIKE ? ESCAPE '!'", path, prefix).
+			Update("missing", true).Error
+	})
+}
+
+// DeletePhoto 删除照片记录（写操作）
+func (s *PhotoRepository) DeletePhoto(id uint) error {
+	return ExecuteWrite(func() error {
+		return db.GetDB().Delete(&model.Photo{}, id).Error
+	})
+}
+
+// ============ 读操作（可以并发）============
+
+// GetPhotoByID 根据 ID 获取照片
+func (s *PhotoRepository) GetPhotoByID(id uint) (*model.Photo, error) {
+	var photo model.Photo
+	err := ExecuteRead(func() error {
+		return db.GetDB().First(&photo, id).Error
+	})
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &photo, nil
+}
+
+// GetPhotoByPath 根据文件路径获取照片
+func (s *PhotoRepository) GetPhotoByPath(path string) (*model.Photo, error) {
+	var photo model.Photo
+	err := ExecuteRead(func() error {
+		return db.GetDB().Where("path = ?", path).First(&photo).Error
+	})
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &photo, nil
+}
+
+// GetPhotosByHash 获取内容相同的所有照片
+func (s *PhotoRepository) GetPhotosByHash(hash string) ([]model.Photo, error) {
+	var photos []model.Photo
+	err := ExecuteRead(func() error {
+		return db.GetDB().Where("hash = ?", hash).Find(&photos).Error
+	})
+
+	return photos, err
+}
+
+// GetFileStatesByLibrary 获取存储库下所有照片的文件状态（仅查询增量比对需要的字段）
+func (s *PhotoRepository) GetFileStatesByLibrary(libraryID uint) ([]model.Photo, error) {
+	var photos []model.Photo
+	err := ExecuteRead(func() error {
+		return db.GetDB().
+			Select("id", "path", "file_size", "file_mod_time", "missing").
+			Where("library_id = ?", libraryID).
+			Find(&photos).Error
+	})
+
+	return photos, err
+}
+
+// CountByLibrary 统计存储库下的照片数量
+func (s *PhotoRepository) CountByLibrary(libraryID uint) (int64, error) {
+	var total int64
+	err := ExecuteRead(func() error {
+		return db.GetDB().Model(&model.Photo{}).Where("library_id = ?", libraryID).Count(&total).Error
+	})
+
+	return total, err
+}
+
+// photoSortColumns 排序字段对应的列，按导入时间排序时直接使用 ID
+var photoSortColumns = map[string]string{
+	model.PhotoSortCaptureTime: "capture_time",
+	model.PhotoSortFileName:    "file_name",
+	model.PhotoSortFileSize:    "file_size",
+}
+
+// photoListColumns 照片列表需要的列，不读取完整的 EXIF 字段
+var photoListColumns = []string{
+	"id", "library_id", "file_name", "hash", "file_size", "file_type", "mime_type", "capture_time", "created_at",
+	"width", "height", "exif_make", "exif_model", "exif_lens_id", "exif_iso", "exif_f_number", "exif_focal_length",
+	"exif_orientation",
+}
+
+// ListPhotos 按游标分页查询照片，返回当前页和是否还有下一页
+// 以 (排序列, id) 作为键集分页，配合排序列上的索引，翻到任意位置都只扫描一页的数据
+func (s *PhotoRepository) ListPhotos(query *model.PhotoQuery) ([]model.Photo, bool, error) {
+	column := photoSortColumns[query.Sort]
+	order, compare := "ASC", ">"
+	if query.Desc {
+		order, compare = "DESC", "<"
+	}
+
+	var photos []model.Photo
+	err := ExecuteRead(func() error {
+		tx := applyPhotoFilter(db.GetDB().Model(&model.Photo{}), &query.Filter).Select(photoListColumns)
+		if query.After != nil {
+			value, err := query.After.SortValue()
+			if err != nil {
+				return err
+			}
+			if column == "" {
+				tx = tx.Where("id "+compare+" ?", query.After.ID)
+			} else {
+				tx = tx.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, compare), value, query.After.ID)
+			}
+		}
+		if column != "" {
+			tx = tx.Order(column + " " + order)
+		}
+		// 多取一条用于判断是否还有下一页
+		return tx.Order("id " + order).Limit(query.Limit + 1).Find(&photos).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(photos) > query.Limit
+	if hasMore {
+		photos = photos[:query.Limit]
+	}
+	return photos, hasMore, nil
+}
+
+// applyPhotoFilter 添加照片筛选条件，已删除和已标记缺失的照片不返回
+// 软删除和缺失标记几乎不过滤数据，列名前的 + 号阻止 SQLite 在没有统计信息时选用这两列的索引，
+// 使查询按排序列的索引顺序扫描并在取满一页后结束【MySQL 忽略一元 + 号】
+func applyPhotoFilter(tx *gorm.DB, filter *model.PhotoFilter) *gorm.DB {
+	tx = tx.Unscoped().Where("+deleted_at IS NULL AND +missing = ?", false)
+	switch len(filter.LibraryIDs) {
+	case 0:
+	case 1:
+		// 单个存储库可以使用 (library_id, 排序列) 联合索引
+		tx = tx.Where("library_id = ?", filter.LibraryIDs[0])
+	default:
+		tx = tx.Where("+library_id IN ?", filter.LibraryIDs)
+	}
+	if filter.From != nil {
+		tx = tx.Where("capture_time >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		tx = tx.Where("capture_time < ?", *filter.To)
+	}
+	if filter.Make != "" {
+		tx = tx.Where("exif_make = ?", filter.Make)
+	}
+	if filter.Model != "" {
+		tx = tx.Where("exif_model = ?", filter.Model)
+	}
+	if filter.LensID != "" {
+		tx = tx.Where("exif_lens_id = ?", filter.LensID)
+	}
+	if filter.ISOMin > 0 {
+		tx = tx.Where("exif_iso >= ?", filter.ISOMin)
+	}
+	if filter.ISOMax > 0 {
+		tx = tx.Where("exif_iso <= ?", filter.ISOMax)
+	}
+	if filter.ApertureMin > 0 {
+		tx = tx.Where("exif_f_number >= ?", filter.ApertureMin)
+	}
+	if filter.ApertureMax > 0 {
+		tx = tx.Where("exif_f_number <= ?", filter.ApertureMax)
+	}
+	if filter.FocalLengthMin > 0 {
+		tx = tx.Where("exif_focal_length >= ?", filter.FocalLengthMin)
+	}
+	if filter.FocalLengthMax > 0 {
+		tx = tx.Where("exif_focal_length <= ?", filter.FocalLengthMax)
+	}
+	if len(filter.FileTypes) > 0 {
+		tx = tx.Where("file_type IN ?", filter.FileTypes)
+	}
+
+	// 方向 5-8 时显示宽高与像素宽高互换
+	switch filter.Orientation {
+	case model.PhotoOrientationLandscape:
+		tx = tx.Where("((exif_orientation BETWEEN 5 AND 8 AND height > width) OR (exif_orientation NOT BETWEEN 5 AND 8 AND width > height))")
+	case model.PhotoOrientationPortrait:
+		tx = tx.Where("((exif_orientation BETWEEN 5 AND 8 AND width > height) OR (exif_orientation NOT BETWEEN 5 AND 8 AND height > width))")
+	case model.PhotoOrientationSquare:
+		tx = tx.Where("width = height AND width > 0")
+	}
+	return tx
+}
+
+// escapeLike 转义 LIKE 中的通配符【以 ! 作为转义符，反斜杠在 MySQL 和 SQLite 中含义不同】
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
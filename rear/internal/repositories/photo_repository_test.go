@@ -0,0 +1,249 @@
+package repositories
+
+import (
+	"fmt"
+	"path/filepath"
+	"rear/internal/config"
+	"rear/internal/db"
+	"rear/internal/model"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// openTestDB 使用临时 SQLite 数据库替换全局连接并完成迁移
+func openTestDB(t *testing.T) {
+	t.Helper()
+	InitBaseService()
+	conn, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = previous })
+	// 未以 sqlite_fts5 构建标签运行测试时使用 LIKE 检索
+	config.CONFIG.SearchLikeFallback = true
+	if err := db.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createListPhotos 创建两个存储库的测试照片，拍摄时间、文件名、大小大量重复
+// 另有一张已删除和一张已缺失的照片，列表中不应返回
+func createListPhotos(t *testing.T) []model.Photo {
+	t.Helper()
+	for i := 0; i < 2; i++ {
+		if err := db.DB.Create(&model.LibraryTable{ImgPath: t.TempDir()}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	makes := []string{"Canon", "Nikon", "Sony"}
+	types := []string{"JPEG", "NEF", "HEIC"}
+	var photos []model.Photo
+	for i := 0; i < 40; i++ {
+		photo := model.Photo{
+			LibraryID: uint(i%2 + 1),
+			Path:      fmt.Sprintf("/photos/%02d.jpg", i),
+			FileName:  fmt.Sprintf("IMG_%d.jpg", i%5),
+			FileSize:  int64(1000 * (i % 4)),
+			FileType:  types[i%3],
+			Width:     4000,
+			Height:    3000,
+		}
+		photo.SetCaptureTime(base.Add(time.Duration(i%6)*time.Hour), model.CaptureTimeFromExif)
+		photo.Exif.Make = makes[i%3]
+		photo.Exif.Model = photo.Exif.Make + " M" + fmt.Sprint(i%2)
+		photo.Exif.LensID = fmt.Sprintf("lens-%d", i%4)
+		photo.Exif.ISO = 100 * (i%8 + 1)
+		photo.Exif.FNumber = 1.4 * float64(i%5+1)
+		photo.Exif.FocalLength = float64(10 * (i%7 + 2))
+		switch i % 4 {
+		case 1:
+			// 旋转 90 度后为竖拍
+			photo.Exif.Orientation = 6
+		case 2:
+			photo.Width, photo.Height = 3000, 4000
+		case 3:
+			photo.Width, photo.Height = 3000, 3000
+		default:
+			photo.Exif.Orientation = 1
+		}
+		photos = append(photos, photo)
+	}
+	if err := db.DB.Create(&photos).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	hidden := []model.Photo{
+		{LibraryID: 1, Path: "/photos/missing.jpg", FileName: "IMG_0.jpg", Missing: true},
+		{LibraryID: 1, Path: "/photos/deleted.jpg", FileName: "IMG_0.jpg"},
+	}
+	for i := range hidden {
+		hidden[i].SetCaptureTime(base, model.CaptureTimeFromExif)
+	}
+	if err := db.DB.Create(&hidden).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DB.Delete(&hidden[1]).Error; err != nil {
+		t.Fatal(err)
+	}
+	return photos
+}
+
+// listAllPhotos 按游标逐页读取全部结果
+func listAllPhotos(t *testing.T, repo *PhotoRepository, query model.PhotoQuery) []uint {
+	t.Helper()
+	var ids []uint
+	for page := 0; ; page++ {
+		if page > 100 {
+			t.Fatal("too many pages")
+		}
+		photos, hasMore, err := repo.ListPhotos(&query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(photos) > query.Limit {
+			t.Fatalf("page size = %d, limit %d", len(photos), query.Limit)
+		}
+		for _, photo := range photos {
+			ids = append(ids, photo.ID)
+		}
+		if !hasMore {
+			return ids
+		}
+		if len(photos) == 0 {
+			t.Fatal("empty page with more results")
+		}
+		// 游标经过编码和解析，与接口的使用方式一致
+		cursor, err := model.DecodePhotoCursor(model.NewPhotoCursor(query.Sort, &photos[len(photos)-1]).Encode(), query.Sort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query.After = cursor
+	}
+}
+
+// expectedIDs 在内存中按 (排序值, ID) 排序得到的预期结果
+func expectedIDs(photos []model.Photo, sortBy string, desc bool, match func(*model.Photo) bool) []uint {
+	var matched []model.Photo
+	for i := range photos {
+		if match == nil || match(&photos[i]) {
+			matched = append(matched, photos[i])
+		}
+	}
+	less := func(a, b *model.Photo) bool {
+		switch sortBy {
+		case model.PhotoSortCaptureTime:
+			if !a.CaptureTime.Equal(b.CaptureTime) {
+				return a.CaptureTime.Before(b.CaptureTime)
+			}
+		case model.PhotoSortFileName:
+			if a.FileName != b.FileName {
+				return a.FileName < b.FileName
+			}
+		case model.PhotoSortFileSize:
+			if a.FileSize != b.FileSize {
+				return a.FileSize < b.FileSize
+			}
+		}
+		return a.ID < b.ID
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if desc {
+			return less(&matched[j], &matched[i])
+		}
+		return less(&matched[i], &matched[j])
+	})
+	ids := make([]uint, 0, len(matched))
+	for _, photo := range matched {
+		ids = append(ids, photo.ID)
+	}
+	return ids
+}
+
+func TestListPhotosPagination(t *testing.T) {
+	openTestDB(t)
+	photos := createListPhotos(t)
+	repo := NewPhotoRepository()
+
+	sorts := []string{model.PhotoSortCaptureTime, model.PhotoSortImportTime, model.PhotoSortFileName, model.PhotoSortFileSize}
+	for _, sortBy := range sorts {
+		for _, desc := range []bool{false, true} {
+			// 页大小小于、等于和大于相同排序值的记录数
+			for _, limit := range []int{1, 3, 7, 50} {
+				got := listAllPhotos(t, repo, model.PhotoQuery{Sort: sortBy, Desc: desc, Limit: limit})
+				want := expectedIDs(photos, sortBy, desc, nil)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("sort=%s desc=%v limit=%d:\n got %v\nwant %v", sortBy, desc, limit, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestListPhotosFilter(t *testing.T) {
+	openTestDB(t)
+	photos := createListPhotos(t)
+	repo := NewPhotoRepository()
+
+	from := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		filter model.PhotoFilter
+		match  func(*model.Photo) bool
+	}{
+		{"library", model.PhotoFilter{LibraryIDs: []uint{2}}, func(p *model.Photo) bool { return p.LibraryID == 2 }},
+		{"libraries", model.PhotoFilter{LibraryIDs: []uint{1, 2}}, nil},
+		{"time range", model.PhotoFilter{From: &from, To: &to}, func(p *model.Photo) bool {
+			return !p.CaptureTime.Before(from) && p.CaptureTime.Before(to)
+		}},
+		{"make", model.PhotoFilter{Make: "Nikon"}, func(p *model.Photo) bool { return p.Exif.Make == "Nikon" }},
+		{"model", model.PhotoFilter{Model: "Sony M1"}, func(p *model.Photo) bool { return p.Exif.Model == "Sony M1" }},
+		{"lens", model.PhotoFilter{LensID: "lens-3"}, func(p *model.Photo) bool { return p.Exif.LensID == "lens-3" }},
+		{"iso", model.PhotoFilter{ISOMin: 200, ISOMax: 500}, func(p *model.Photo) bool {
+			return p.Exif.ISO >= 200 && p.Exif.ISO <= 500
+		}},
+		{"aperture", model.PhotoFilter{ApertureMin: 2.8, ApertureMax: 5.6}, func(p *model.Photo) bool {
+			return p.Exif.FNumber >= 2.8 && p.Exif.FNumber <= 5.6
+		}},
+		{"focal length", model.PhotoFilter{FocalLengthMin: 30, FocalLengthMax: 60}, func(p *model.Photo) bool {
+			return p.Exif.FocalLength >= 30 && p.Exif.FocalLength <= 60
+		}},
+		{"file types", model.PhotoFilter{FileTypes: []string{"NEF", "HEIC"}}, func(p *model.Photo) bool {
+			return p.FileType == "NEF" || p.FileType == "HEIC"
+		}},
+		// 方向 6 的 4000x3000 照片显示为竖拍
+		{"landscape", model.PhotoFilter{Orientation: model.PhotoOrientationLandscape}, func(p *model.Photo) bool {
+			return p.Width > p.Height && p.Exif.Orientation != 6
+		}},
+		{"portrait", model.PhotoFilter{Orientation: model.PhotoOrientationPortrait}, func(p *model.Photo) bool {
+			return p.Height > p.Width || p.Exif.Orientation == 6
+		}},
+		{"square", model.PhotoFilter{Orientation: model.PhotoOrientationSquare}, func(p *model.Photo) bool {
+			return p.Width == p.Height
+		}},
+		{"combined", model.PhotoFilter{LibraryIDs: []uint{1}, Make: "Canon", Orientation: model.PhotoOrientationLandscape}, func(p *model.Photo) bool {
+			return p.LibraryID == 1 && p.Exif.Make == "Canon" && p.Width > p.Height && p.Exif.Orientation != 6
+		}},
+	}
+	for _, c := range cases {
+		want := expectedIDs(photos, model.PhotoSortCaptureTime, true, c.match)
+		if len(want) == 0 || (c.match != nil && len(want) == len(photos)) {
+			t.Fatalf("%s: test data does not exercise the filter", c.name)
+		}
+		got := listAllPhotos(t, repo, model.PhotoQuery{Filter: c.filter, Sort: model.PhotoSortCaptureTime, Desc: true, Limit: 3})
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\n got %v\nwant %v", c.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package repositories
+
+import (
+	"fmt"
+	"html"
+	"rear/internal/db"
+	"rear/internal/model"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// 检索词数量上限
+	maxSearchTerms = 8
+	// trigram 分词时少于 3 个字符的词无法使用索引
+	minTrigramLength = 3
+	// 片段中命中文字的临时标记【FTS5 snippet 和手动截取共用，转义后替换为 <mark>】
+	snippetOpen  = "\x02"
+	snippetClose = "\x03"
+	// 手动截取片段时命中位置之前保留的字符数和片段总长度
+	snippetContext = 12
+	snippetLength  = 48
+)
+
+// FTS5 各列的 bm25 权重，顺序与 photos_fts 的列一致：
+// file_name, dir, title, description, keywords, camera, lens
+const ftsRank = "bm25(photos_fts, 5.0, 1.0, 8.0, 4.0, 6.0, 2.0, 2.0)"
+
+// photoSearchRow 检索结果行
+type photoSearchRow struct {
+	model.Photo `gorm:"embedded"`
+	Score       float64
+	Snippet     string
+}
+
+// SearchPhotos 全文检索照片，返回当前页和是否还有下一页
+// 按 db.SearchMode 使用 FTS5、FULLTEXT 或 LIKE 查询，前两者按相关度排序，LIKE 按拍摄时间排序
+func (s *PhotoRepository) SearchPhotos(search *model.PhotoSearch) ([]model.PhotoSearchHit, bool, error) {
+	return s.searchPhotos(search, db.SearchMode())
+}
+
+func (s *PhotoRepository) searchPhotos(search *model.PhotoSearch, mode string) ([]model.PhotoSearchHit, bool, error) {
+	terms := searchTerms(search.Text)
+	if len(terms) == 0 {
+		return nil, false, nil
+	}
+
+	var rows []photoSearchRow
+	err := ExecuteRead(func() error {
+		var tx *gorm.DB
+		switch mode {
+		case db.SearchFTS5:
+			tx = ftsSearchQuery(terms)
+		case db.SearchFullText:
+			tx = fullTextSearchQuery(terms)
+		default:
+			tx = likeSearchQuery(terms)
+		}
+		// 多取一条用于判断是否还有下一页
+		return applyPhotoFilter(tx, &search.Filter).Offset(search.Offset).Limit(search.Limit + 1).Find(&rows).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(rows) > search.Limit
+	if hasMore {
+		rows = rows[:search.Limit]
+	}
+	hits := make([]model.PhotoSearchHit, 0, len(rows))
+	for _, row := range rows {
+		snippet := row.Snippet
+		if snippet == "" {
+			photo := &row.Photo
+			snippet = buildSnippet([]string{
+				photo.Exif.Title, photo.Exif.Description, photo.Exif.Keywords, photo.FileName, photo.Dir,
+				strings.TrimSpace(photo.Exif.Make + " " + photo.Exif.Model), photo.Exif.LensID,
+			}, terms)
+		}
+		hits = append(hits, model.PhotoSearchHit{Photo: row.Photo, Score: row.Score, Snippet: markSnippet(snippet)})
+	}
+	return hits, hasMore, nil
+}
+
+// searchColumns 检索结果需要的列【列表的列加上用于截取片段的文本列】
+func searchColumns() string {
+	columns := append(append([]string(nil), photoListColumns...), "dir", "exif_title", "exif_description", "exif_keywords")
+	for i, column := range columns {
+		columns[i] = "photos." + column
+	}
+	return strings.Join(columns, ", ")
+}
+
+// ftsSearchQuery FTS5 检索：不少于 3 个字符的词使用 MATCH；较短的词先由短词索引按片段筛选，
+// 再在 FTS 表上 LIKE 匹配确认【片段按字母、数字拆分，只含标点的词无法使用索引】
+func ftsSearchQuery(terms []string) *gorm.DB {
+	tx := db.GetDB().Table(db.PhotoFTSTable).Joins("JOIN photos ON photos.id = " + db.PhotoFTSTable + ".rowid")
+	var phrases, grams []string
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) >= minTrigramLength {
+			phrases = append(phrases, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+			continue
+		}
+		for _, gram := range model.SearchGramRuns(term) {
+			grams = append(grams, `"`+gram+`"`)
+		}
+		columns := []string{"file_name", "dir", "title", "description", "keywords", "camera", "lens"}
+		for i, column := range columns {
+			columns[i] = db.PhotoFTSTable + "." + column
+		}
+		tx = whereAnyLike(tx, columns, term)
+	}
+	if len(grams) > 0 {
+		tx = tx.Joins(fmt.Sprintf("JOIN %[1]s ON %[1]s.rowid = photos.id", db.PhotoShortFTSTable)).
+			Where(db.PhotoShortFTSTable+" MATCH ?", strings.Join(grams, " "))
+	}
+	if len(phrases) == 0 {
+		return tx.Select(searchColumns() + ", 0 AS score, '' AS snippet").Order("photos.capture_time DESC, photos.id DESC")
+	}
+	return tx.Select(fmt.Sprintf("%s, -%s AS score, snippet(%s, -1, char(2), char(3), '…', 16) AS snippet",
+		searchColumns(), ftsRank, db.PhotoFTSTable)).
+		Where(db.PhotoFTSTable+" MATCH ?", strings.Join(phrases, " ")).
+		Order(ftsRank + ", photos.id DESC")
+}
+
+// fullTextSearchQuery MySQL FULLTEXT 检索，每个词作为短语且必须命中
+func fullTextSearchQuery(terms []string) *gorm.DB {
+	phrases := make([]string, 0, len(terms))
+	for _, term := range terms {
+		phrases = append(phrases, `+"`+strings.ReplaceAll(term, `"`, " ")+`"`)
+	}
+	against := strings.Join(phrases, " ")
+	match := fmt.Sprintf("MATCH(%s) AGAINST(? IN BOOLEAN MODE)", strings.Join(db.PhotoSearchColumns, ", "))
+	return db.GetDB().Table("photos").
+		Select(searchColumns()+", "+match+" AS score, '' AS snippet", against).
+		Where(match, against).
+		Order("score DESC, photos.id DESC")
+}
+
+// likeSearchQuery 不支持全文索引时逐行匹配，每个词需在任一列中出现
+func likeSearchQuery(terms []string) *gorm.DB {
+	columns := make([]string, 0, len(db.PhotoSearchColumns))
+	for _, column := range db.PhotoSearchColumns {
+		columns = append(columns, "photos."+column)
+	}
+	tx := db.GetDB().Table("photos")
+	for _, term := range terms {
+		tx = whereAnyLike(tx, columns, term)
+	}
+	return tx.Select(searchColumns() + ", 0 AS score, '' AS snippet").Order("photos.capture_time DESC, photos.id DESC")
+}
+
+// whereAnyLike 任一列包含 term
+func whereAnyLike(tx *gorm.DB, columns []string, term string) *gorm.DB {
+	pattern := "%" + escapeLike(term) + "%"
+	conditions := make([]string, 0, len(columns))
+	args := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		conditions = append(conditions, column+" LIKE ? ESCAPE '!'")
+		args = append(args, pattern)
+	}
+	return tx.Where("("+strings.Join(conditions, " OR ")+")", args...)
+}
+
+// searchTerms 按空白拆分检索词并去重，最多 maxSearchTerms 个
+func searchTerms(text string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range strings.Fields(text) {
+		key := strings.ToLower(term)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		terms = append(terms, term)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// buildSnippet 从第一个命中的字段中截取片段并标记命中的文字【不区分大小写】
+func buildSnippet(fields []string, terms []string) string {
+	for _, field := range fields {
+		runes := []rune(field)
+		lower := make([]rune, len(runes))
+		for i, r := range runes {
+			lower[i] = unicode.ToLower(r)
+		}
+
+		marked := make([]bool, len(runes))
+		first := -1
+		for _, term := range terms {
+			pattern := []rune(strings.ToLower(term))
+			for i := 0; i+len(pattern) <= len(lower); i++ {
+				if string(lower[i:i+len(pattern)]) != string(pattern) {
+					continue
+				}
+				for j := i; j < i+len(pattern); j++ {
+					marked[j] = true
+				}
+				if first < 0 || i < first {
+					first = i
+				}
+			}
+		}
+		if first < 0 {
+			continue
+		}
+
+		start := max(0, first-snippetContext)
+		end := min(len(runes), start+snippetLength)
+		var b strings.Builder
+		if start > 0 {
+			b.WriteString("…")
+		}
+		for i := start; i < end; i++ {
+			if marked[i] && (i == start || !marked[i-1]) {
+				b.WriteString(snippetOpen)
+			}
+			b.WriteRune(runes[i])
+			if marked[i] && (i == end-1 || !marked[i+1]) {
+				b.WriteString(snippetClose)
+			}
+		}
+		if end < len(runes) {
+			b.WriteString("…")
+		}
+		return b.String()
+	}
+	return ""
+}
+
+// markSnippet 转义片段中的 HTML 并将命中标记替换为 <mark>
+func markSnippet(snippet string) string {
+	return strings.NewReplacer(snippetOpen, "<mark>", snippetClose, "</mark>").Replace(html.EscapeString(snippet))
+}
@@ -0,0 +1,205 @@
+package repositories
+
+import (
+	"fmt"
+	"rear/internal/db"
+	"rear/internal/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSearchTerms(t *testing.T) {
+	got := searchTerms("  西湖 Canon canon  日落 ")
+	want := []string{"西湖", "Canon", "日落"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("searchTerms = %v, want %v", got, want)
+	}
+	if terms := searchTerms("a b c d e f g h i j"); len(terms) != maxSearchTerms {
+		t.Errorf("len = %d, want %d", len(terms), maxSearchTerms)
+	}
+}
+
+func TestBuildSnippet(t *testing.T) {
+	cases := []struct {
+		fields []string
+		terms  []string
+		want   string
+	}{
+		// 跳过未命中的字段，不区分大小写
+		{[]string{"", "Family Trip"}, []string{"trip"}, "Family \x02Trip\x03"},
+		// 相邻的命中合并为一个标记
+		{[]string{"杭州西湖日落"}, []string{"西湖", "日落"}, "杭州\x02西湖日落\x03"},
+		// 命中位置靠后时截取并加省略号
+		{[]string{"一二三四五六七八九十一二三四五六七八九十西湖"}, []string{"西湖"}, "…九十一二三四五六七八九十\x02西湖\x03"},
+		{[]string{"abc"}, []string{"xyz"}, ""},
+	}
+	for _, c := range cases {
+		if got := buildSnippet(c.fields, c.terms); got != c.want {
+			t.Errorf("buildSnippet(%q, %q) = %q, want %q", c.fields, c.terms, got, c.want)
+		}
+	}
+}
+
+func TestMarkSnippet(t *testing.T) {
+	got := markSnippet("<b>\x02西湖\x03</b> & co")
+	want := "&lt;b&gt;<mark>西湖</mark>&lt;/b&gt; &amp; co"
+	if got != want {
+		t.Errorf("markSnippet = %q, want %q", got, want)
+	}
+}
+
+// createSearchPhotos 保存检索用的照片，返回文件名到照片的映射
+// a 只在目录中包含 sunset，b 在标题中包含；拍摄时间 a < b < c
+func createSearchPhotos(t *testing.T, repo *PhotoRepository) map[string]*model.Photo {
+	t.Helper()
+	if err := db.DB.Create(&model.LibraryTable{ImgPath: t.TempDir()}).Error; err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	photos := []*model.Photo{
+		{Path: "/photos/sunset/a.jpg", Dir: "/photos/sunset", FileName: "a.jpg"},
+		{Path: "/photos/misc/b.jpg", Dir: "/photos/misc", FileName: "b.jpg", Exif: model.ExifInfo{Title: "Sunset 西湖"}},
+		{Path: "/photos/misc/c.jpg", Dir: "/photos/misc", FileName: "c.jpg", Exif: model.ExifInfo{Title: "海边", Make: "Canon"}},
+	}
+	byName := make(map[string]*model.Photo, len(photos))
+	for i, photo := range photos {
+		photo.LibraryID = 1
+		photo.SetCaptureTime(base.Add(time.Duration(i)*time.Hour), model.CaptureTimeFromExif)
+		if err := repo.SavePhoto(photo); err != nil {
+			t.Fatal(err)
+		}
+		byName[photo.FileName] = photo
+	}
+	return byName
+}
+
+// searchNames 检索并返回命中照片的文件名，按结果顺序
+func searchNames(t *testing.T, repo *PhotoRepository, mode, text string) ([]string, []model.PhotoSearchHit) {
+	t.Helper()
+	hits, _, err := repo.searchPhotos(&model.PhotoSearch{Text: text, Limit: 10}, mode)
+	if err != nil {
+		t.Fatalf("search %q: %v", text, err)
+	}
+	names := []string{}
+	for _, hit := range hits {
+		names = append(names, hit.Photo.FileName)
+	}
+	return names, hits
+}
+
+func assertSearch(t *testing.T, repo *PhotoRepository, mode, text string, want ...string) {
+	t.Helper()
+	if want == nil {
+		want = []string{}
+	}
+	if got, _ := searchNames(t, repo, mode, text); !reflect.DeepEqual(got, want) {
+		t.Errorf("search %q = %v, want %v", text, got, want)
+	}
+}
+
+// assertFTSIntegrity 检查两个 FTS5 表与 photos 一致
+func assertFTSIntegrity(t *testing.T) {
+	t.Helper()
+	for _, table := range []string{db.PhotoFTSTable, db.PhotoShortFTSTable} {
+		statement := fmt.Sprintf("INSERT INTO %[1]s(%[1]s, rank) VALUES ('integrity-check', 1)", table)
+		if err := db.DB.Exec(statement).Error; err != nil {
+			t.Fatalf("%s integrity: %v", table, err)
+		}
+	}
+}
+
+func TestSearchPhotosFTS5(t *testing.T) {
+	openTestDB(t)
+	if db.SearchMode() != db.SearchFTS5 {
+		t.Skip("requires -tags sqlite_fts5")
+	}
+	repo := NewPhotoRepository()
+	photos := createSearchPhotos(t, repo)
+	mode := db.SearchFTS5
+
+	// 标题权重高于目录
+	names, hits := searchNames(t, repo, mode, "sunset")
+	if !reflect.DeepEqual(names, []string{"b.jpg", "a.jpg"}) || hits[0].Score <= hits[1].Score || hits[1].Score <= 0 {
+		t.Fatalf("ranking = %v, scores %v", names, hits)
+	}
+	// 短词走短词索引，长短词混合
+	assertSearch(t, repo, mode, "西湖", "b.jpg")
+	assertSearch(t, repo, mode, "湖", "b.jpg")
+	assertSearch(t, repo, mode, "canon 海边", "c.jpg")
+	assertSearch(t, repo, mode, "sunset 海边")
+
+	// 更新同步
+	b := photos["b.jpg"]
+	b.Exif.Title = "Sunrise 北京"
+	if err := repo.SavePhoto(b); err != nil {
+		t.Fatal(err)
+	}
+	assertSearch(t, repo, mode, "西湖")
+	assertSearch(t, repo, mode, "北京", "b.jpg")
+	assertSearch(t, repo, mode, "sunset", "a.jpg")
+
+	// 移动后按新文件名命中
+	c := photos["c.jpg"]
+	if err := repo.MovePhoto(c.ID, 1, "/photos/new/西湖.jpg", 0, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	assertSearch(t, repo, mode, "西湖", "西湖.jpg")
+	assertSearch(t, repo, mode, "海边", "西湖.jpg")
+
+	// 删除同步
+	if err := db.DB.Unscoped().Delete(&model.Photo{}, c.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	assertSearch(t, repo, mode, "海边")
+	assertFTSIntegrity(t)
+
+	// 触发器缺失期间的修改在迁移时重建【含未生成检索片段的旧记录】
+	for _, name := range []string{"photos_fts_au", "photos_fts_short_au"} {
+		if err := db.DB.Exec("DROP TRIGGER " + name).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := db.DB.Exec("UPDATE photos SET exif_title = '东京塔', search_grams = NULL WHERE id = ?", photos["a.jpg"].ID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSearch(t, repo, mode, "东京塔")
+	if err := db.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	assertSearch(t, repo, mode, "东京塔", "a.jpg")
+	assertSearch(t, repo, mode, "东京", "a.jpg")
+	assertFTSIntegrity(t)
+}
+
+func TestSearchPhotosLike(t *testing.T) {
+	openTestDB(t)
+	repo := NewPhotoRepository()
+	photos := createSearchPhotos(t, repo)
+	mode := db.SearchLike
+
+	// 不计算相关度，按拍摄时间倒序
+	names, hits := searchNames(t, repo, mode, "SUNSET")
+	if !reflect.DeepEqual(names, []string{"b.jpg", "a.jpg"}) || hits[0].Score != 0 {
+		t.Fatalf("search = %v, hits %v", names, hits)
+	}
+	if hits[0].Snippet != "<mark>Sunset</mark> 西湖" {
+		t.Errorf("snippet = %q", hits[0].Snippet)
+	}
+	assertSearch(t, repo, mode, "湖", "b.jpg")
+	assertSearch(t, repo, mode, "canon 海边", "c.jpg")
+	assertSearch(t, repo, mode, "sunset 海边")
+	// 通配符按字面匹配
+	assertSearch(t, repo, mode, "%")
+
+	// 缺失和已删除的照片不返回
+	if err := repo.MarkMissing([]uint{photos["b.jpg"].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeletePhoto(photos["c.jpg"].ID); err != nil {
+		t.Fatal(err)
+	}
+	assertSearch(t, repo, mode, "sunset", "a.jpg")
+	assertSearch(t, repo, mode, "海边")
+}
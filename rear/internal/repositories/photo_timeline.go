@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"rear/internal/db"
+	"rear/internal/model"
+	"slices"
+)
+
+// timelineDay 按拍摄日期统计的一行
+type timelineDay struct {
+	CaptureDay int
+	Count      int64
+	CoverID    uint
+}
+
+// Timeline 按年、月或日统计照片数量，封面取分组内最后导入的照片
+// 数据库中只按 capture_day 分组【二十年也不过数千行】，在内存中合并为月和年，
+// 筛选条件任意组合时无法维护汇总表，由 (library_id, capture_day, missing, deleted_at) 索引保证只扫描索引；
+// ID 包含在索引中，取最大 ID 作为封面同样无需回表
+func (s *PhotoRepository) Timeline(query *model.TimelineQuery) ([]model.TimelineBucket, error) {
+	var days []timelineDay
+	err := ExecuteRead(func() error {
+		tx := applyPhotoFilter(db.GetDB().Model(&model.Photo{}), &query.Filter).
+			Select("capture_day, COUNT(*) AS count, MAX(id) AS cover_id").
+			Where("capture_day > 0")
+		if low, high := timelineRange(query.Year, query.Month); high > 0 {
+			tx = tx.Where("capture_day BETWEEN ? AND ?", low, high)
+		}
+		return tx.Group("capture_day").Order("capture_day").Scan(&days).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []model.TimelineBucket
+	for _, day := range days {
+		bucket := model.TimelineBucket{Year: day.CaptureDay / 10000}
+		switch query.Group {
+		case model.TimelineDay:
+			bucket.Month, bucket.Day = day.CaptureDay/100%100, day.CaptureDay%100
+		case model.TimelineMonth:
+			bucket.Month = day.CaptureDay / 100 % 100
+		}
+		// 按日期升序遍历，同一分组的日期相邻
+		if n := len(buckets); n > 0 && buckets[n-1].Year == bucket.Year &&
+			buckets[n-1].Month == bucket.Month && buckets[n-1].Day == bucket.Day {
+			buckets[n-1].Count += day.Count
+			buckets[n-1].CoverID = max(buckets[n-1].CoverID, day.CoverID)
+			continue
+		}
+		bucket.Count, bucket.CoverID = day.Count, day.CoverID
+		buckets = append(buckets, bucket)
+	}
+	if query.Desc {
+		slices.Reverse(buckets)
+	}
+	return buckets, nil
+}
+
+// timelineRange 年份或月份对应的 capture_day 闭区间，未指定年份时 high 为 0
+func timelineRange(year, month int) (low, high int) {
+	switch {
+	case year <= 0:
+		return 0, 0
+	case month <= 0:
+		return year * 10000, year*10000 + 9999
+	}
+	return year*10000 + month*100, year*10000 + month*100 + 99
+}
+
+// GetListPhotosByIDs 按 ID 获取照片的列表字段，不存在或已删除的 ID 忽略
+func (s *PhotoRepository) GetListPhotosByIDs(ids []uint) ([]model.Photo, error) {
+	var photos []model.Photo
+	if len(ids) == 0 {
+		return photos, nil
+	}
+	err := ExecuteRead(func() error {
+		return db.GetDB().Select(photoListColumns).Where("id IN ?", ids).Find(&photos).Error
+	})
+	return photos, err
+}
@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"fmt"
+	"rear/internal/db"
+	"rear/internal/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// createTimelinePhotos 按 ID 顺序创建照片，日期打乱以检查封面取最大 ID
+// 没有拍摄时间、已缺失和已删除的照片不参与统计
+func createTimelinePhotos(t *testing.T, repo *PhotoRepository) {
+	t.Helper()
+	for i := 0; i < 2; i++ {
+		if err := db.DB.Create(&model.LibraryTable{ImgPath: t.TempDir()}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows := []struct {
+		library uint
+		date    string
+		missing bool
+		deleted bool
+	}{
+		{1, "2023-01-05", false, false}, // 1
+		{2, "2024-07-04", false, false}, // 2
+		{1, "2022-12-31", false, false}, // 3
+		{2, "2023-01-05", false, false}, // 4
+		{1, "2023-03-01", false, false}, // 5
+		{1, "2023-01-20", false, false}, // 6
+		{2, "2024-07-04", false, false}, // 7
+		{1, "2023-01-05", false, false}, // 8
+		{1, "", false, false},           // 9
+		{1, "2023-03-01", true, false},  // 10
+		{2, "2023-01-20", false, true},  // 11
+	}
+	for i, row := range rows {
+		photo := &model.Photo{LibraryID: row.library, Path: fmt.Sprintf("/photos/%d.jpg", i+1), Missing: row.missing}
+		if row.date != "" {
+			// 当天 23 点【东八区】，检查按拍摄地日期统计
+			captured, err := time.ParseInLocation("2006-01-02 15", row.date+" 23", time.FixedZone("", 8*3600))
+			if err != nil {
+				t.Fatal(err)
+			}
+			photo.SetCaptureTime(captured, model.CaptureTimeFromExif)
+		}
+		if err := db.DB.Create(photo).Error; err != nil {
+			t.Fatal(err)
+		}
+		if photo.ID != uint(i+1) {
+			t.Fatalf("photo id = %d, want %d", photo.ID, i+1)
+		}
+		if row.deleted {
+			if err := repo.DeletePhoto(photo.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	openTestDB(t)
+	repo := NewPhotoRepository()
+	createTimelinePhotos(t, repo)
+
+	type bucket = model.TimelineBucket
+	cases := []struct {
+		name  string
+		query model.TimelineQuery
+		want  []bucket
+	}{
+		{"day", model.TimelineQuery{Group: model.TimelineDay}, []bucket{
+			{Year: 2022, Month: 12, Day: 31, Count: 1, CoverID: 3},
+			{Year: 2023, Month: 1, Day: 5, Count: 3, CoverID: 8},
+			{Year: 2023, Month: 1, Day: 20, Count: 1, CoverID: 6},
+			{Year: 2023, Month: 3, Day: 1, Count: 1, CoverID: 5},
+			{Year: 2024, Month: 7, Day: 4, Count: 2, CoverID: 7},
+		}},
+		// 同月的多天合并，封面取各天中最大的 ID
+		{"month", model.TimelineQuery{Group: model.TimelineMonth}, []bucket{
+			{Year: 2022, Month: 12, Count: 1, CoverID: 3},
+			{Year: 2023, Month: 1, Count: 4, CoverID: 8},
+			{Year: 2023, Month: 3, Count: 1, CoverID: 5},
+			{Year: 2024, Month: 7, Count: 2, CoverID: 7},
+		}},
+		{"year desc", model.TimelineQuery{Group: model.TimelineYear, Desc: true}, []bucket{
+			{Year: 2024, Count: 2, CoverID: 7},
+			{Year: 2023, Count: 5, CoverID: 8},
+			{Year: 2022, Count: 1, CoverID: 3},
+		}},
+		{"year filter", model.TimelineQuery{Group: model.TimelineMonth, Year: 2023}, []bucket{
+			{Year: 2023, Month: 1, Count: 4, CoverID: 8},
+			{Year: 2023, Month: 3, Count: 1, CoverID: 5},
+		}},
+		{"month filter", model.TimelineQuery{Group: model.TimelineDay, Year: 2023, Month: 1, Desc: true}, []bucket{
+			{Year: 2023, Month: 1, Day: 20, Count: 1, CoverID: 6},
+			{Year: 2023, Month: 1, Day: 5, Count: 3, CoverID: 8},
+		}},
+		{"library", model.TimelineQuery{Group: model.TimelineYear, Filter: model.PhotoFilter{LibraryIDs: []uint{2}}}, []bucket{
+			{Year: 2023, Count: 1, CoverID: 4},
+			{Year: 2024, Count: 2, CoverID: 7},
+		}},
+		{"library and year", model.TimelineQuery{Group: model.TimelineYear, Year: 2023, Filter: model.PhotoFilter{LibraryIDs: []uint{1}}}, []bucket{
+			{Year: 2023, Count: 4, CoverID: 8},
+		}},
+		{"empty", model.TimelineQuery{Group: model.TimelineYear, Year: 2021}, nil},
+	}
+	for _, c := range cases {
+		got, err := repo.Timeline(&c.query)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"errors"
+	"rear/internal/db"
+	"rear/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type TaskRepository struct{}
+
+func NewTaskRepository() *TaskRepository {
+	return &TaskRepository{}
+}
+
+// CreateTask 保存新任务（写操作）
+func (s *TaskRepository) CreateTask(task *model.PictureTaskRecord) error {
+	return ExecuteWrite(func() error {
+		return db.GetDB().Create(task).Error
+	})
+}
+
+// CreateTasks 批量保存新任务，每批一次写入（写操作）
+func (s *TaskRepository) CreateTasks(tasks []model.PictureTaskRecord) error {
+	const batchSize = 500
+	for start := 0; start < len(tasks); start += batchSize {
+		batch := tasks[start:min(start+batchSize, len(tasks))]
+		err := ExecuteWrite(func() error {
+			return db.GetDB().Create(&batch).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateTaskStatus 更新任务状态和错误信息（写操作）
+func (s *TaskRepository) UpdateTaskStatus(taskID string, status string, message string) error {
+	return ExecuteWrite(func() error {
+		return db.GetDB().Model(&model.PictureTaskRecord{}).
+			Where("task_id = ?", taskID).
+			Updates(map[string]interface{}{
+				"status": status,
+				"error":  message,
+			}).Error
+	})
+}
+
+// UpdateTask 更新任务字段（写操作）
+func (s *TaskRepository) UpdateTask(taskID string, updates map[string]interface{}) error {
+	return ExecuteWrite(func() error {
+		return db.GetDB().Model(&model.PictureTaskRecord{}).
+			Where("task_id = ?", taskID).
+			Updates(updates).Error
+	})
+}
+
+// DeleteTask 删除已结束的任务（写操作）
+func (s *TaskRepository) DeleteTask(taskID string) error {
+	return ExecuteWrite(func() error {
+		return db.GetDB().Unscoped().Where("task_id = ?", taskID).Delete(&model.PictureTaskRecord{}).Error
+	})
+}
+
+// ResetStatus 将指定状态的任务重置为新状态，返回影响的行数（写操作）
+func (s *TaskRepository) ResetStatus(from []string, to string) (int64, error) {
+	var affected int64
+	err := ExecuteWrite(func() error {
+		result := db.GetDB().Model(&model.PictureTaskRecord{}).
+			Where("status IN ?", from).
+			Update("status", to)
+		affected = result.RowsAffected
+		return result.Error
+	})
+	return affected, err
+}
+
+// ============ 读操作（可以并发）============
+
+// GetTaskByTaskID 根据任务 ID 获取任务
+func (s *TaskRepository) GetTaskByTaskID(taskID string) (*model.PictureTaskRecord, error) {
+	var task model.PictureTaskRecord
+	err := ExecuteRead(func() error {
+		return db.GetDB().Where("task_id = ?", taskID).First(&task).Error
+	})
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &task, nil
+}
+
+// GetFailedTasksPaginated 分页获取失败的任务，errorClass 为空时不过滤
+func (s *TaskRepository) GetFailedTasksPaginated(errorClass string, offset, limit int) ([]model.PictureTaskRecord, int64, error) {
+	var tasks []model.PictureTaskRecord
+	var total int64
+
+	err := ExecuteRead(func() error {
+		if err := failedTasksQuery(errorClass).Count(&total).Error; err != nil {
+			return err
+		}
+		return failedTasksQuery(errorClass).Order("id DESC").Offset(offset).Limit(limit).Find(&tasks).Error
+	})
+
+	return tasks, total, err
+}
+
+// GetFailedTasks 获取所有失败的任务，errorClass 为空时不过滤
+func (s *TaskRepository) GetFailedTasks(errorClass string) ([]model.PictureTaskRecord, error) {
+	var tasks []model.PictureTaskRecord
+	err := ExecuteRead(func() error {
+		return failedTasksQuery(errorClass).Order("id").Find(&tasks).Error
+	})
+
+	return tasks, err
+}
+
+func failedTasksQuery(errorClass string) *gorm.DB {
+	query := db.GetDB().Model(&model.PictureTaskRecord{}).Where("status = ?", "failed")
+	if errorClass != "" {
+		query = query.Where("error_class = ?", errorClass)
+	}
+	return query
+}
+
+// GetTasksByStatus 按创建顺序获取指定状态的任务
+func (s *TaskRepository) GetTasksByStatus(status string) ([]model.PictureTaskRecord, error) {
+	var tasks []model.PictureTaskRecord
+	err := ExecuteRead(func() error {
+		return db.GetDB().Where("status = ?", status).Order("id").Find(&tasks).Error
+	})
+
+	return tasks, err
+}
@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"rear/internal/db"
+	"rear/internal/model"
+
+	"gorm.io/gorm/clause"
+)
+
+type ThumbnailRepository struct{}
+
+func NewThumbnailRepository() *ThumbnailRepository {
+	return &ThumbnailRepository{}
+}
+
+// SaveThumbnails 保存缩略图记录，已存在时更新路径和尺寸（写操作）
+func (s *ThumbnailRepository) SaveThumbnails(thumbs []model.Thumbnail) error {
+	if len(thumbs) == 0 {
+		return nil
+	}
+	return ExecuteWrite(func() error {
+		return db.GetDB().Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "hash"}, {Name: "size"}, {Name: "format"}},
+			DoUpdates: clause.AssignmentColumns([]string{"path", "width", "height", "updated_at", "deleted_at"}),
+		}).Create(&thumbs).Error
+	})
+}
+
+// ============ 读操作（可以并发）============
+
+// GetThumbnailsByHash 获取指定内容的所有缩略图
+func (s *ThumbnailRepository) GetThumbnailsByHash(hash string) ([]model.Thumbnail, error) {
+	var thumbs []model.Thumbnail
+	err := ExecuteRead(func() error {
+		return db.GetDB().Where("hash = ?", hash).Order("size").Find(&thumbs).Error
+	})
+
+	return thumbs, err
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"errors"
+	"rear/internal/db"
+	"rear/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ToolCacheRepository struct{}
+
+func NewToolCacheRepository() *ToolCacheRepository {
+	return &ToolCacheRepository{}
+}
+
+// SaveCache 保存缓存，已存在时覆盖内容和工具版本（写操作）
+func (s *ToolCacheRepository) SaveCache(cache *model.ToolCache) error {
+	return ExecuteWrite(func() error {
+		return db.GetDB().Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "hash"}, {Name: "tool"}, {Name: "args_version"}},
+			DoUpdates: clause.AssignmentColumns([]string{"tool_version", "data", "updated_at", "deleted_at"}),
+		}).Create(cache).Error
+	})
+}
+
+// DeleteStale 删除同一类型、同一参数版本下工具版本与当前不一致的缓存，返回删除的条数（写操作）
+// 其他参数版本【如另一个元数据读取器】的缓存保留
+func (s *ToolCacheRepository) DeleteStale(tool, argsVersion, toolVersion string) (int64, error) {
+	var affected int64
+	err := ExecuteWrite(func() error {
+		result := db.GetDB().Unscoped().
+			Where("tool = ? AND args_version = ? AND tool_version <> ?", tool, argsVersion, toolVersion).
+			Delete(&model.ToolCache{})
+		affected = result.RowsAffected
+		return result.Error
+	})
+	return affected, err
+}
+
+// DeleteOrphans 删除 before 之前更新、且没有照片引用其 Hash 的缓存，返回删除的条数（写操作）
+// 只清理较早的缓存：处理中的文件先保存缓存、后保存照片记录
+func (s *ToolCacheRepository) DeleteOrphans(before time.Time) (int64, error) {
+	var affected int64
+	err := ExecuteWrite(func() error {
+		result := db.GetDB().Unscoped().
+			Where("updated_at < ? AND NOT EXISTS (SELECT 1 FROM photos WHERE photos.hash = tool_caches.hash AND photos.deleted_at IS NULL)", before).
+			Delete(&model.ToolCache{})
+		affected = result.RowsAffected
+		return result.Error
+	})
+	return affected, err
+}
+
+// ============ 读操作（可以并发）============
+
+// GetCache 获取缓存，不存在或工具版本不一致时返回 nil
+func (s *ToolCacheRepository) GetCache(hash, tool, argsVersion, toolVersion string) (*model.ToolCache, error) {
+	var cache model.ToolCache
+	err := ExecuteRead(func() error {
+		return db.GetDB().
+			Where("hash = ? AND tool = ? AND args_version = ? AND tool_version = ?", hash, tool, argsVersion, toolVersion).
+			First(&cache).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cache, nil
+}
@@ -17,7 +17,11 @@ func SetupRoutes(r *gin.Engine, contain *container.DbContainer, imgContain *cont
 
 	// 资料库处理
 	libraryHandler := handler.NewLibraryHandler(contain, imgContain)
-	devImageHandler := handler.NewDevImageHandler(contain)
+	devImageHandler := handler.NewDevImageHandler(contain, imgContain)
+	systemHandler := handler.NewSystemHandler(imgContain)
+	jobHandler := handler.NewJobHandler(imgContain)
+	eventHandler := handler.NewEventHandler(imgContain)
+	photoHandler := handler.NewPhotoHandler(contain, imgContain)
 	// API版本组
 	v1 := r.Group("/api/v1")
 	{
@@ -41,6 +45,45 @@ func SetupRoutes(r *gin.Engine, contain *container.DbContainer, imgContain *cont
 			// 执行检索任务
 			library.POST("indexed", libraryHandler.LibraryIndex)
 		}
+		// 照片浏览
+		photos := v1.Group("/photos")
+		{
+			photos.GET("", photoHandler.ListPhotos)
+			// 全文检索
+			photos.GET("/search", photoHandler.SearchPhotos)
+			// 时间线【按年、月、日统计】
+			photos.GET("/timeline", photoHandler.Timeline)
+			photos.GET("/:id/thumb", photoHandler.GetThumbnail)
+			photos.GET("/:id/original", photoHandler.GetOriginal)
+		}
+		// 索引任务
+		jobs := v1.Group("/jobs")
+		{
+			jobs.GET("", jobHandler.ListJobs)
+			jobs.GET("/:id", jobHandler.GetJob)
+			jobs.POST("/:id/pause", jobHandler.PauseJob)
+			jobs.POST("/:id/resume", jobHandler.ResumeJob)
+			jobs.POST("/:id/cancel", jobHandler.CancelJob)
+		}
+		// 索引进度推送【SSE】
+		v1.GET("/events", eventHandler.Stream)
+		// 单个文件任务
+		tasks := v1.Group("/tasks")
+		{
+			// 死信列表
+			tasks.GET("/dead", jobHandler.ListDeadTasks)
+			tasks.POST("/dead/retry", jobHandler.RetryDeadTasks)
+			tasks.POST("/dead/:id/retry", jobHandler.RetryDeadTask)
+			tasks.GET("/:id", jobHandler.GetTask)
+			tasks.POST("/:id/pause", jobHandler.PauseTask)
+			tasks.POST("/:id/resume", jobHandler.ResumeTask)
+			tasks.POST("/:id/cancel", jobHandler.CancelTask)
+		}
+		// 系统信息
+		system := v1.Group("/system")
+		{
+			system.GET("/tools", systemHandler.GetTools)
+		}
 	}
 	// 开发组
 	dev := r.Group("/dev")
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrOutputTooLarge 命令输出超过上限，命令已被终止
+var ErrOutputTooLarge = errors.New("command output too large")
+
+// CommandLimits 外部命令的执行限制
+type CommandLimits struct {
+	// 默认超时，调用方的 ctx 更早到期时以 ctx 为准
+	Timeout time.Duration
+	// stdout 最大字节数，超出时终止命令并返回 ErrOutputTooLarge
+	MaxStdout int
+	// stderr 保留的最大字节数，超出部分丢弃
+	MaxStderr int
+	// 同时运行的最大进程数
+	Concurrency int
+}
+
+var (
+	// 按程序名【不含扩展名，小写】区分的执行限制
+	commandLimits = map[string]CommandLimits{
+		"vips":            {Timeout: 2 * time.Minute, MaxStdout: 16 << 20, MaxStderr: 64 << 10, Concurrency: runtime.NumCPU()},
+		"magick":          {Timeout: 2 * time.Minute, MaxStdout: 16 << 20, MaxStderr: 64 << 10, Concurrency: max(runtime.NumCPU()/2, 1)},
+		"convert":         {Timeout: 2 * time.Minute, MaxStdout: 16 << 20, MaxStderr: 64 << 10, Concurrency: max(runtime.NumCPU()/2, 1)},
+		"exiftool":        {Timeout: time.Minute, MaxStdout: 64 << 20, MaxStderr: 64 << 10, Concurrency: runtime.NumCPU()},
+		"dcraw":           {Timeout: 5 * time.Minute, MaxStdout: 512 << 20, MaxStderr: 64 << 10, Concurrency: max(runtime.NumCPU()/2, 1)},
+		"rawtherapee-cli": {Timeout: 5 * time.Minute, MaxStdout: 16 << 20, MaxStderr: 64 << 10, Concurrency: max(runtime.NumCPU()/2, 1)},
+	}
+	// 未单独配置的程序
+	defaultCommandLimits = CommandLimits{Timeout: time.Minute, MaxStdout: 16 << 20, MaxStderr: 64 << 10, Concurrency: runtime.NumCPU()}
+
+	commandLimitsMu sync.RWMutex
+	// 各程序的并发信号量，按限制创建，修改限制后重新创建
+	commandSemaphores = make(map[string]chan struct{})
+)
+
+// commandName 程序路径对应的限制名称
+func commandName(program string) string {
+	name := strings.ToLower(filepath.Base(program))
+	return strings.TrimSuffix(name, ".exe")
+}
+
+// SetCommandLimits 设置程序的执行限制，program 为程序名或路径
+func SetCommandLimits(program string, limits CommandLimits) {
+	name := commandName(program)
+	commandLimitsMu.Lock()
+	defer commandLimitsMu.Unlock()
+	commandLimits[name] = limits
+	delete(commandSemaphores, name)
+}
+
+// GetCommandLimits 程序的执行限制，未单独配置时返回默认限制
+func GetCommandLimits(program string) CommandLimits {
+	commandLimitsMu.RLock()
+	defer commandLimitsMu.RUnlock()
+	if limits, ok := commandLimits[commandName(program)]; ok {
+		return limits
+	}
+	return defaultCommandLimits
+}
+
+// commandSemaphore 程序的并发信号量，不限制并发时返回 nil
+func commandSemaphore(program string) chan struct{} {
+	limits := GetCommandLimits(program)
+	if limits.Concurrency <= 0 {
+		return nil
+	}
+	name := commandName(program)
+	commandLimitsMu.Lock()
+	defer commandLimitsMu.Unlock()
+	sem, ok := commandSemaphores[name]
+	if !ok {
+		sem = make(chan struct{}, limits.Concurrency)
+		commandSemaphores[name] = sem
+	}
+	return sem
+}
+
+// CommandError 命令执行失败，包含退出码和截断后的 stderr
+type CommandError struct {
+	Program  string
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Program)
+	if e.ExitCode >= 0 {
+		fmt.Fprintf(&b, " exited with code %d", e.ExitCode)
+	} else {
+		fmt.Fprintf(&b, " failed: %v", e.Err)
+	}
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		b.WriteString(": ")
+		b.WriteString(stderr)
+	}
+	return b.String()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// limitedBuffer 有容量上限的缓冲区
+// strict 为 true 时超出上限调用 overflow 并返回错误，否则丢弃超出部分
+type limitedBuffer struct {
+	buf       []byte
+	limit     int
+	strict    bool
+	truncated bool
+	overflow  func()
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit <= 0 {
+		b.buf = append(b.buf, p...)
+		return len(p), nil
+	}
+	if remaining := b.limit - len(b.buf); len(p) > remaining {
+		b.buf = append(b.buf, p[:max(remaining, 0)]...)
+		if !b.truncated {
+			b.truncated = true
+			if b.strict && b.overflow != nil {
+				b.overflow()
+			}
+		}
+		if b.strict {
+			return 0, ErrOutputTooLarge
+		}
+		return len(p), nil
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
@@ -0,0 +1,25 @@
+//go:build linux
+
+package utils
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 命令在独立的进程组中运行，终止时连同子进程一起终止
+// ImageMagick 的 delegate 等子进程不会因父进程被终止而退出
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// killProcessGroup 首进程退出后终止进程组中残留的子进程【如 delegate 在后台启动、未等待的进程】
+// 组内仍有进程时内核不会把该 pgid 分配给新进程，因此不会误杀；组已为空时返回 ESRCH，忽略即可
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
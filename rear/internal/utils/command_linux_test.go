@@ -0,0 +1,40 @@
+//go:build linux
+
+package utils
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 命令正常退出后，后台启动且不占用输出管道的子进程同样被终止
+func TestExecuteCommandKillsLeftoverChildren(t *testing.T) {
+	result, err := ExecuteCommand(context.Background(), "sh", "-c", "sleep 30 >/dev/null 2>&1 & echo $!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(result.Stdout)))
+	if err != nil {
+		t.Fatalf("pid %q: %v", result.Stdout, err)
+	}
+
+	// 进程不存在或已成为僵尸进程【等待回收】都视为已终止
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+		if err != nil {
+			return
+		}
+		if fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:])); len(fields) > 0 && fields[0] == "Z" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("child %d still running after command exited", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
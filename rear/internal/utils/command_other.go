@@ -0,0 +1,10 @@
+//go:build !linux
+
+package utils
+
+import "os/exec"
+
+// setProcessGroup 非 Linux 平台只终止直接启动的进程
+func setProcessGroup(*exec.Cmd) {}
+
+func killProcessGroup(*exec.Cmd) {}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func skipWithoutSh(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+}
+
+func TestExecuteCommandError(t *testing.T) {
+	skipWithoutSh(t)
+	_, err := ExecuteCommand(context.Background(), "sh", "-c", "echo bad input >&2; exit 3")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("got %v, want CommandError", err)
+	}
+	if cmdErr.ExitCode != 3 || strings.TrimSpace(cmdErr.Stderr) != "bad input" {
+		t.Fatalf("error = %+v", cmdErr)
+	}
+	if LastCommandFailure("sh") == nil {
+		t.Fatal("failure not recorded")
+	}
+}
+
+func TestExecuteCommandLimits(t *testing.T) {
+	skipWithoutSh(t)
+	SetCommandLimits("sh", CommandLimits{Timeout: 300 * time.Millisecond, MaxStdout: 1024, MaxStderr: 16})
+	t.Cleanup(func() { SetCommandLimits("sh", defaultCommandLimits) })
+
+	_, err := ExecuteCommand(context.Background(), "sh", "-c", "yes")
+	if !errors.Is(err, ErrOutputTooLarge) {
+		t.Fatalf("output: got %v, want ErrOutputTooLarge", err)
+	}
+
+	result, err := ExecuteCommand(context.Background(), "sh", "-c", "yes >&2")
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("stderr: %v", err)
+	}
+	if len(result.Stderr) != 16 {
+		t.Fatalf("stderr length = %d, want 16", len(result.Stderr))
+	}
+
+	// 超时时连同子进程一起终止，不等待子进程释放输出管道
+	start := time.Now()
+	_, err = ExecuteCommand(context.Background(), "sh", "-c", "sleep 10 & sleep 10")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("timeout: got %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); runtime.GOOS == "linux" && elapsed > 3*time.Second {
+		t.Fatalf("timeout took %s", elapsed)
+	}
+
+	// 调用方取消时返回 ctx 的错误
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ExecuteCommand(ctx, "sh", "-c", "true"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled: got %v, want Canceled", err)
+	}
+}
+
+func TestExecuteCommandConcurrency(t *testing.T) {
+	skipWithoutSh(t)
+	SetCommandLimits("sh", CommandLimits{Timeout: 5 * time.Second, Concurrency: 2})
+	t.Cleanup(func() { SetCommandLimits("sh", defaultCommandLimits) })
+
+	// 6 个 0.2 秒的命令，最多同时运行 2 个，至少需要 0.6 秒
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ExecuteCommand(context.Background(), "sh", "-c", "sleep 0.2"); err != nil {
+				t.Errorf("execute: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 600*time.Millisecond {
+		t.Fatalf("6 commands finished in %s, concurrency not limited", elapsed)
+	}
+}
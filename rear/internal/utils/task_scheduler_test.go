@@ -197,7 +197,7 @@ func (tm *TaskMonitor) printStats() {
 				taskType, stat.Total, stat.Completed, stat.Failed, stat.AvgTime)
 		}
 	}
-	fmt.Println("==========================================\n")
+	fmt.Print("==========================================\n\n")
 }
 
 // 主函数示例
@@ -1,9 +1,9 @@
 package utils
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go.uber.org/zap"
 	"os"
@@ -15,80 +15,49 @@ import (
 	"time"
 )
 
-// 全局变量存储工具路径
 var (
-	ImageMagickPath string
-	VipsPath        string
-	ExifToolPath    string
-	toolsInitOnce   sync.Once
-	toolsInitErr    error
+	// 命令执行失败回调
+	commandFailedHooks []CommandFailedHook
+	commandHooksMu     sync.RWMutex
+	// 各程序最近一次执行失败的记录
+	lastFailures = make(map[string]*CommandFailure)
 )
 
-// Config 初始化配置
-type Config struct {
-	// 工具路径（如果为空，会自动检测）
-	ImageMagickPath string
-	ExifToolPath    string
-	VipsPath        string
-}
-
-// Initialize 初始化工具路径（可选调用，如果不调用会在第一次使用时自动初始化）
-func Initialize(config *Config) error {
-	if config == nil {
-		config = &Config{}
-	}
+// 失败记录和 CommandError 中保留的 stderr 长度
+const maxFailureStderr = 1024
 
-	toolsInitOnce.Do(func() {
-		ImageMagickPath = config.ImageMagickPath
-		ExifToolPath = config.ExifToolPath
-		VipsPath = config.VipsPath
-		toolsInitErr = detectTools()
-	})
-
-	return toolsInitErr
+// CommandFailure 命令执行失败的记录
+type CommandFailure struct {
+	Time     time.Time `json:"time"`
+	Error    string    `json:"error"`
+	ExitCode int       `json:"exit_code"`
+	Stderr   string    `json:"stderr,omitempty"`
 }
 
-// detectTools 检测工具路径
-func detectTools() error {
-	// 获取可执行文件所在目录
-	execPath, err := os.Executable()
-	if err != nil {
-		return fmt.Errorf("failed to get executable path: %w", err)
-	}
-	execDir := filepath.Dir(execPath)
-
-	// 检测 ImageMagick
-	if ImageMagickPath == "" {
-		ImageMagickPath = findTool("convert", execDir)
-		if ImageMagickPath == "" {
-			ImageMagickPath = findTool("magick", execDir)
-		}
-	}
-	if ImageMagickPath == "" {
-		return fmt.Errorf("ImageMagick not found")
-	}
+// ErrToolNotFound 外部工具未找到
+var ErrToolNotFound = errors.New("not found")
 
-	// 检测 ExifTool
-	if ExifToolPath == "" {
-		ExifToolPath = findTool("exiftool", execDir)
-	}
-	if ExifToolPath == "" {
-		return fmt.Errorf("ExifTool not found")
-	}
+// 外部工具名称
+const (
+	ToolImageMagick = "ImageMagick"
+	ToolExifTool    = "ExifTool"
+	ToolVips        = "libvips"
+	// RAW 解码命令【dcraw 或 rawtherapee-cli】
+	ToolRawConverter = "RawConverter"
+)
 
-	// 检测 libvips
-	if VipsPath == "" {
-		VipsPath = findTool("vips", execDir)
-	}
-	if VipsPath == "" {
-		return fmt.Errorf("libvips not found")
-	}
+// CommandFailedHook 命令执行失败时的回调【调用方主动取消的不会触发】
+type CommandFailedHook func(program string, result *CommandResult, err error)
 
-	return nil
+// OnCommandFailed 注册命令执行失败回调
+func OnCommandFailed(hook CommandFailedHook) {
+	commandHooksMu.Lock()
+	defer commandHooksMu.Unlock()
+	commandFailedHooks = append(commandFailedHooks, hook)
 }
 
-// findTool 查找工具
-func findTool(name string, execDir string) string {
+// FindTool 在程序目录和 PATH 中查找工具，未找到时返回空字符串
+func FindTool(name string, execDir string) string {
 	// Windows 下添加 .exe 后缀
 	exeName := name
 	if runtime.GOOS == "windows" {
@@ -126,14 +95,6 @@ func findTool(name string, execDir string) string {
 	return ""
 }
 
-// EnsureInitialized 确保工具已初始化
-func EnsureInitialized() error {
-	if toolsInitErr == nil && ImageMagickPath == "" && ExifToolPath == "" && VipsPath == "" {
-		return Initialize(nil)
-	}
-	return toolsInitErr
-}
-
 // CommandResult 命令执行结果
 type CommandResult struct {
 	Stdout   []byte
@@ -143,14 +104,39 @@ type CommandResult struct {
 }
 
 // ExecuteCommand 执行命令的通用函数
+// 按程序的 CommandLimits 限制并发数、执行时间和输出大小；执行失败时返回 *CommandError
 func ExecuteCommand(ctx context.Context, program string, args ...string) (*CommandResult, error) {
-	// 创建命令
-	cmd := exec.CommandContext(ctx, program, args...)
+	limits := GetCommandLimits(program)
+	name := filepath.Base(program)
+
+	// 等待并发名额
+	if sem := commandSemaphore(program); sem != nil {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+		case <-ctx.Done():
+			return &CommandResult{ExitCode: -1}, fmt.Errorf("%s: %w", name, ctx.Err())
+		}
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if limits.Timeout > 0 {
+		runCtx, cancel = context.WithTimeout(runCtx, limits.Timeout)
+		defer cancel()
+	}
 
-	// 准备输出缓冲区
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	// 创建命令，runCtx 取消时在 Wait 返回前终止整个进程组，正常退出后再终止组内残留的子进程
+	cmd := exec.CommandContext(runCtx, program, args...)
+	setProcessGroup(cmd)
+	// 终止后子进程仍占用输出管道时不再等待
+	cmd.WaitDelay = 5 * time.Second
+
+	// 准备输出缓冲区，stdout 超出上限时终止命令
+	stdout := &limitedBuffer{limit: limits.MaxStdout, strict: true, overflow: cancel}
+	stderr := &limitedBuffer{limit: limits.MaxStderr}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
 	// 记录开始时间
 	start := time.Now()
@@ -158,15 +144,17 @@ func ExecuteCommand(ctx context.Context, program string, args ...string) (*Comma
 	// 执行命令
 	err := cmd.Run()
 	duration := time.Since(start)
+	killProcessGroup(cmd)
 
 	result := &CommandResult{
-		Stdout:   stdout.Bytes(),
-		Stderr:   stderr.Bytes(),
+		Stdout:   stdout.buf,
+		Stderr:   stderr.buf,
 		Duration: duration,
 	}
 
 	// 获取退出码
-	if exitErr, ok := err.(*exec.ExitError); ok {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
 		result.ExitCode = exitErr.ExitCode()
 	} else if err == nil {
 		result.ExitCode = 0
@@ -174,5 +162,50 @@ func ExecuteCommand(ctx context.Context, program string, args ...string) (*Comma
 		result.ExitCode = -1
 	}
 
+	// 被取消时返回 ctx 的错误，调用方可以用 errors.Is 判断
+	if err != nil && ctx.Err() != nil {
+		return result, fmt.Errorf("%s: %w", name, ctx.Err())
+	}
+
+	if err != nil {
+		switch {
+		case stdout.truncated:
+			err = fmt.Errorf("%w (limit %d bytes)", ErrOutputTooLarge, limits.MaxStdout)
+		case runCtx.Err() != nil:
+			err = fmt.Errorf("timed out after %s: %w", limits.Timeout, context.DeadlineExceeded)
+		}
+		err = &CommandError{Program: name, ExitCode: result.ExitCode, Stderr: truncateStderr(result.Stderr), Err: err}
+		NotifyCommandFailed(program, result, err)
+	}
+
 	return result, err
 }
+
+// NotifyCommandFailed 通知命令执行失败【不经过 ExecuteCommand 执行的工具调用使用】
+func NotifyCommandFailed(program string, result *CommandResult, err error) {
+	failure := &CommandFailure{Time: time.Now(), Error: err.Error()}
+	if result != nil {
+		failure.ExitCode = result.ExitCode
+		failure.Stderr = truncateStderr(result.Stderr)
+	}
+
+	commandHooksMu.Lock()
+	lastFailures[program] = failure
+	hooks := commandFailedHooks
+	commandHooksMu.Unlock()
+	for _, hook := range hooks {
+		hook(program, result, err)
+	}
+}
+
+// truncateStderr 错误信息中保留的 stderr
+func truncateStderr(stderr []byte) string {
+	return string(stderr[:min(len(stderr), maxFailureStderr)])
+}
+
+// LastCommandFailure 程序最近一次执行失败的记录，没有失败时返回 nil
+func LastCommandFailure(program string) *CommandFailure {
+	commandHooksMu.RLock()
+	defer commandHooksMu.RUnlock()
+	return lastFailures[program]
+}
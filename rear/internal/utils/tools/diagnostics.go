@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"context"
+	"rear/internal/utils"
+	"strings"
+)
+
+// ToolStatus 外部工具的诊断信息
+type ToolStatus struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Found   bool   `json:"found"`
+	Version string `json:"version,omitempty"`
+	// 可读取和写入的格式
+	Read  []string `json:"read,omitempty"`
+	Write []string `json:"write,omitempty"`
+	// 版本或格式检测失败的原因
+	Error string `json:"error,omitempty"`
+	// 最近一次执行失败的记录
+	LastError *utils.CommandFailure `json:"last_error,omitempty"`
+}
+
+// Diagnostics 工具诊断信息
+type Diagnostics struct {
+	Tools []ToolStatus `json:"tools"`
+	// 图像处理后端，按优先级排列
+	Processors []ProcessorStatus `json:"processors"`
+	// 当前使用的元数据读取器
+	MetadataReader string `json:"metadata_reader"`
+}
+
+// Diagnose 检测各工具的路径、版本和支持的格式
+// vips 和 ImageMagick 的格式取启动时的检测结果，与实际处理图像时的选择一致
+func Diagnose(ctx context.Context, kit *Toolkit) *Diagnostics {
+	processors := kit.Processors.Detect()
+	processorFormats := make(map[string]ProcessorStatus, len(processors))
+	for _, status := range processors {
+		processorFormats[status.Name] = status
+	}
+
+	exifTool := diagnoseTool(ctx, kit.Runner, utils.ToolExifTool, exifToolVersion)
+	if exifTool.Found {
+		read, err := listExifToolFormats(ctx, kit.Runner, "-listr")
+		if err == nil {
+			exifTool.Read = read
+			exifTool.Write, err = listExifToolFormats(ctx, kit.Runner, "-listwf")
+		}
+		if err != nil && exifTool.Error == "" {
+			exifTool.Error = err.Error()
+		}
+	}
+
+	vips := diagnoseTool(ctx, kit.Runner, utils.ToolVips, func(ctx context.Context, runner ToolRunner) (string, error) {
+		return NewVipsProcessor(runner).Version(ctx)
+	})
+	magick := diagnoseTool(ctx, kit.Runner, utils.ToolImageMagick, func(ctx context.Context, runner ToolRunner) (string, error) {
+		return NewMagickProcessor(runner).Version(ctx)
+	})
+	for _, tool := range []struct {
+		status    *ToolStatus
+		processor string
+	}{
+		{&vips, VipsProcessor{}.Name()},
+		{&magick, MagickProcessor{}.Name()},
+	} {
+		formats := processorFormats[tool.processor]
+		tool.status.Read, tool.status.Write = formats.Read, formats.Write
+		if tool.status.Found && tool.status.Error == "" {
+			tool.status.Error = formats.Error
+		}
+	}
+
+	return &Diagnostics{
+		Tools:          []ToolStatus{exifTool, vips, magick},
+		Processors:     processors,
+		MetadataReader: kit.Metadata.Name(),
+	}
+}
+
+// diagnoseTool 工具路径、版本和最近一次执行失败的记录
+func diagnoseTool(ctx context.Context, runner ToolRunner, name string, version func(context.Context, ToolRunner) (string, error)) ToolStatus {
+	status := ToolStatus{Name: name}
+	path, err := runner.Path(name)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Path = path
+	status.Found = true
+	status.LastError = utils.LastCommandFailure(path)
+
+	status.Version, err = version(ctx, runner)
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// listExifToolFormats 解析 exiftool -listr / -listwf 输出的扩展名列表
+func listExifToolFormats(ctx context.Context, runner ToolRunner, option string) ([]string, error) {
+	result, err := runner.Run(ctx, utils.ToolExifTool, option)
+	if err != nil {
+		return nil, err
+	}
+	formats := newFormatSupport()
+	for _, line := range strings.Split(string(result.Stdout), "\n") {
+		// 第一行为标题，如 "Recognized file types:"
+		if strings.HasSuffix(strings.TrimSpace(line), ":") {
+			continue
+		}
+		formats.addRead(strings.Fields(line)...)
+	}
+	return sortedFormats(formats.Read), nil
+}
+
+func firstLine(output string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(line)
+}
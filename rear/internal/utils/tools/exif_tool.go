@@ -3,23 +3,58 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"rear/internal/utils"
 	"strings"
 )
 
+// ExifBatchSize 单次 exiftool 调用处理的最大文件数
+const ExifBatchSize = 64
+
 // ExifData EXIF 数据
 type ExifData map[string]interface{}
 
-// GetExifData 获取 EXIF 数据
-func GetExifData(ctx context.Context, input string) (ExifData, error) {
-	if err := utils.EnsureInitialized(); err != nil {
-		return nil, err
+// ExifResult 批量读取时单个文件的结果
+type ExifResult struct {
+	Path string
+	Data ExifData
+	Err  error
+}
+
+// fileInfoKeys 与文件路径和文件系统相关的字段，内容相同的文件这些字段也可能不同
+var fileInfoKeys = []string{"SourceFile", "FileName", "Directory", "FileModifyDate", "FileAccessDate",
+	"FileCreateDate", "FileInodeChangeDate", "FilePermissions"}
+
+// WithoutFileInfo 返回去掉文件相关字段的副本，只保留由文件内容决定的数据
+func (d ExifData) WithoutFileInfo() ExifData {
+	result := make(ExifData, len(d))
+	for key, value := range d {
+		result[key] = value
+	}
+	for _, key := range fileInfoKeys {
+		delete(result, key)
 	}
+	return result
+}
+
+// WithFileInfo 返回用指定文件的路径和修改时间补全文件相关字段的副本
+func (d ExifData) WithFileInfo(path string, info os.FileInfo) ExifData {
+	result := d.WithoutFileInfo()
+	result["SourceFile"] = path
+	result["FileName"] = filepath.Base(path)
+	result["Directory"] = filepath.Dir(path)
+	result["FileModifyDate"] = info.ModTime().Format(exifFileDateLayout)
+	return result
+}
 
-	result, err := utils.ExecuteCommand(ctx, utils.ExifToolPath, "-j", "-n", input)
+// GetExifData 获取 EXIF 数据
+func GetExifData(ctx context.Context, runner ToolRunner, input string) (ExifData, error) {
+	result, err := runner.Run(ctx, utils.ToolExifTool, "-j", "-n", input)
 	if err != nil {
-		return nil, fmt.Errorf("exiftool failed: %w, stderr: %s", err, string(result.Stderr))
+		return nil, fmt.Errorf("exiftool failed: %w", err)
 	}
 
 	// 解析 JSON 输出
@@ -31,25 +66,94 @@ func GetExifData(ctx context.Context, input string) (ExifData, error) {
 	if len(data) == 0 {
 		return nil, fmt.Errorf("no exif data found")
 	}
+	if message, ok := data[0]["Error"].(string); ok {
+		return nil, &ExifToolError{Message: message}
+	}
 
 	return data[0], nil
 }
 
-// GetExifField 获取特定的 EXIF 字段
-func GetExifField(ctx context.Context, input string, fields ...string) (map[string]string, error) {
-	if err := utils.EnsureInitialized(); err != nil {
-		return nil, err
+// GetExifDataBatch 批量获取 EXIF 数据，每 ExifBatchSize 个文件调用一次 exiftool
+// 返回的结果与 paths 一一对应，单个文件的错误记录在对应结果中；整批失败时返回错误
+func GetExifDataBatch(ctx context.Context, runner ToolRunner, paths []string) ([]ExifResult, error) {
+	results := make([]ExifResult, 0, len(paths))
+	for start := 0; start < len(paths); start += ExifBatchSize {
+		chunk := paths[start:min(start+ExifBatchSize, len(paths))]
+		chunkResults, err := getExifDataChunk(ctx, runner, chunk)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, chunkResults...)
+	}
+	return results, nil
+}
+
+func getExifDataChunk(ctx context.Context, runner ToolRunner, paths []string) ([]ExifResult, error) {
+	args := append([]string{"-j", "-n"}, paths...)
+	result, err := runner.Run(ctx, utils.ToolExifTool, args...)
+	// 部分文件出错时 exiftool 在 stderr 中报告，其余文件的输出仍然有效
+	var exifErr *ExifToolError
+	if err != nil && !(errors.As(err, &exifErr) && result != nil) {
+		return nil, fmt.Errorf("exiftool failed: %w", err)
+	}
+
+	var data []ExifData
+	if len(result.Stdout) > 0 {
+		if err := json.Unmarshal(result.Stdout, &data); err != nil {
+			return nil, fmt.Errorf("failed to parse exif data: %w", err)
+		}
+	}
+
+	// exiftool 输出的 SourceFile 与参数相同【Windows 下分隔符转换为 /】
+	bySource := make(map[string]ExifData, len(data))
+	for _, item := range data {
+		if source, ok := item["SourceFile"].(string); ok {
+			bySource[filepath.ToSlash(source)] = item
+		}
 	}
 
+	results := make([]ExifResult, len(paths))
+	for i, path := range paths {
+		results[i].Path = path
+		item, ok := bySource[filepath.ToSlash(path)]
+		switch {
+		case !ok:
+			if message := fileError(result.Stderr, path); message != "" {
+				results[i].Err = &ExifToolError{Message: message}
+			} else {
+				results[i].Err = fmt.Errorf("no exif data found")
+			}
+		case item["Error"] != nil:
+			results[i].Err = &ExifToolError{Message: fmt.Sprint(item["Error"])}
+		default:
+			results[i].Data = item
+		}
+	}
+	return results, nil
+}
+
+// fileError stderr 中针对单个文件的错误，格式为 "Error: 原因 - 文件路径"
+func fileError(stderr []byte, path string) string {
+	for _, line := range strings.Split(string(stderr), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Error") && strings.HasSuffix(line, " - "+path) {
+			return line
+		}
+	}
+	return ""
+}
+
+// GetExifField 获取特定的 EXIF 字段
+func GetExifField(ctx context.Context, runner ToolRunner, input string, fields ...string) (map[string]string, error) {
 	args := []string{"-s", "-s", "-s"}
 	for _, field := range fields {
 		args = append(args, "-"+field)
 	}
 	args = append(args, input)
 
-	result, err := utils.ExecuteCommand(ctx, utils.ExifToolPath, args...)
+	result, err := runner.Run(ctx, utils.ToolExifTool, args...)
 	if err != nil {
-		return nil, fmt.Errorf("exiftool failed: %w, stderr: %s", err, string(result.Stderr))
+		return nil, fmt.Errorf("exiftool failed: %w", err)
 	}
 
 	// 解析输出
@@ -71,31 +175,22 @@ func GetExifField(ctx context.Context, input string, fields ...string) (map[stri
 }
 
 // RemoveExifData 移除 EXIF 数据
-func RemoveExifData(ctx context.Context, input string, backup bool) error {
-	if err := utils.EnsureInitialized(); err != nil {
-		return err
-	}
-
+func RemoveExifData(ctx context.Context, runner ToolRunner, input string, backup bool) error {
 	args := []string{"-all="}
 	if !backup {
 		args = append(args, "-overwrite_original")
 	}
 	args = append(args, input)
 
-	result, err := utils.ExecuteCommand(ctx, utils.ExifToolPath, args...)
-	if err != nil {
-		return fmt.Errorf("remove exif failed: %w, stderr: %s", err, string(result.Stderr))
+	if _, err := runner.Run(ctx, utils.ToolExifTool, args...); err != nil {
+		return fmt.Errorf("remove exif failed: %w", err)
 	}
 
 	return nil
 }
 
 // CopyExifData 复制 EXIF 数据从一个文件到另一个文件
-func CopyExifData(ctx context.Context, source, target string) error {
-	if err := utils.EnsureInitialized(); err != nil {
-		return err
-	}
-
+func CopyExifData(ctx context.Context, runner ToolRunner, source, target string) error {
 	args := []string{
 		"-TagsFromFile", source,
 		"-all:all",
@@ -103,44 +198,39 @@ func CopyExifData(ctx context.Context, source, target string) error {
 		target,
 	}
 
-	result, err := utils.ExecuteCommand(ctx, utils.ExifToolPath, args...)
-	if err != nil {
-		return fmt.Errorf("copy exif failed: %w, stderr: %s", err, string(result.Stderr))
+	if _, err := runner.Run(ctx, utils.ToolExifTool, args...); err != nil {
+		return fmt.Errorf("copy exif failed: %w", err)
 	}
 
 	return nil
 }
 
 // SetExifField 设置 EXIF 字段
-func SetExifField(ctx context.Context, input string, fields map[string]string) error {
-	if err := utils.EnsureInitialized(); err != nil {
-		return err
-	}
-
+func SetExifField(ctx context.Context, runner ToolRunner, input string, fields map[string]string) error {
 	args := []string{}
 	for key, value := range fields {
 		args = append(args, fmt.Sprintf("-%s=%s", key, value))
 	}
 	args = append(args, "-overwrite_original", input)
 
-	result, err := utils.ExecuteCommand(ctx, utils.ExifToolPath, args...)
-	if err != nil {
-		return fmt.Errorf("set exif failed: %w, stderr: %s", err, string(result.Stderr))
+	if _, err := runner.Run(ctx, utils.ToolExifTool, args...); err != nil {
+		return fmt.Errorf("set exif failed: %w", err)
 	}
 
 	return nil
 }
 
-// IsExifToolAvailable 检查 ExifTool 是否可用
-func IsExifToolAvailable() bool {
-	if err := utils.EnsureInitialized(); err != nil {
-		return false
+// exifToolVersion exiftool -ver 输出的版本
+func exifToolVersion(ctx context.Context, runner ToolRunner) (string, error) {
+	result, err := runner.Run(ctx, utils.ToolExifTool, "-ver")
+	if err != nil {
+		return "", err
 	}
-	return utils.ExifToolPath != ""
+	return firstLine(string(result.Stdout)), nil
 }
 
-// GetToolPaths 获取工具路径（用于调试）
-func GetToolPaths() (imageMagick, exifTool string) {
-	utils.EnsureInitialized()
-	return utils.ImageMagickPath, utils.ExifToolPath
+// IsExifToolAvailable 检查 ExifTool 是否可用
+func IsExifToolAvailable(runner ToolRunner) bool {
+	_, err := runner.Path(utils.ToolExifTool)
+	return err == nil
 }
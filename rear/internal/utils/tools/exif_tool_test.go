@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileError(t *testing.T) {
+	stderr := []byte("Warning: [minor] Bad MakerNotes offset - /a/1.jpg\n" +
+		"Error: File not found - /a/2.jpg\n" +
+		"Error: File format error - /a/b - c.jpg\n")
+
+	cases := map[string]string{
+		"/a/1.jpg":     "",
+		"/a/2.jpg":     "Error: File not found - /a/2.jpg",
+		"/a/b - c.jpg": "Error: File format error - /a/b - c.jpg",
+	}
+	for path, want := range cases {
+		if got := fileError(stderr, path); got != want {
+			t.Errorf("fileError(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestExifDataFileInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "b.jpg")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := ExifData{
+		"SourceFile":     "/old/a.jpg",
+		"FileName":       "a.jpg",
+		"Directory":      "/old",
+		"FileAccessDate": "2024:01:01 00:00:00+00:00",
+		"FileSize":       float64(1),
+		"Make":           "Canon",
+	}
+	stripped := data.WithoutFileInfo()
+	if len(stripped) != 2 || stripped["Make"] != "Canon" || stripped["FileSize"] != float64(1) {
+		t.Fatalf("WithoutFileInfo = %v", stripped)
+	}
+	if data["FileName"] != "a.jpg" {
+		t.Fatal("WithoutFileInfo modified the original data")
+	}
+
+	filled := stripped.WithFileInfo(path, info)
+	if filled["SourceFile"] != path || filled["FileName"] != "b.jpg" || filled["Directory"] != filepath.Dir(path) {
+		t.Errorf("WithFileInfo = %v", filled)
+	}
+	if filled["FileModifyDate"] != info.ModTime().Format(exifFileDateLayout) || filled["FileAccessDate"] != nil {
+		t.Errorf("WithFileInfo dates = %v", filled)
+	}
+}
@@ -0,0 +1,311 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"os/exec"
+	"rear/internal/utils"
+	"rear/pkg/logger"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 单次请求默认超时
+	defaultExifToolTimeout = 30 * time.Second
+	// 关闭时等待进程退出的时间
+	exifToolCloseTimeout = 3 * time.Second
+)
+
+var ErrExifToolPoolClosed = errors.New("exiftool pool closed")
+
+// ExifToolError exiftool 在 stderr 中报告的错误
+type ExifToolError struct {
+	Message string
+}
+
+func (e *ExifToolError) Error() string {
+	return "exiftool: " + e.Message
+}
+
+// ExifToolPool 常驻的 exiftool 进程池
+// 每个进程以 -stay_open True -@ - 启动，参数逐行写入 stdin，以 -executeN 提交，
+// stdout 读到 {readyN} 为止；stderr 通过 -echo4 输出同样的标记来分隔每次请求
+type ExifToolPool struct {
+	path    string
+	timeout time.Duration
+	// 空闲进程，容量即并发数
+	idle chan *exifWorker
+
+	mu      sync.Mutex
+	workers []*exifWorker
+	closed  bool
+}
+
+// NewExifToolPool 创建进程池，进程在第一次使用时启动
+func NewExifToolPool(path string, size int, timeout time.Duration) *ExifToolPool {
+	size = max(size, 1)
+	if timeout <= 0 {
+		timeout = defaultExifToolTimeout
+	}
+	pool := &ExifToolPool{
+		path:    path,
+		timeout: timeout,
+		idle:    make(chan *exifWorker, size),
+	}
+	for i := 0; i < size; i++ {
+		worker := &exifWorker{path: path}
+		pool.workers = append(pool.workers, worker)
+		pool.idle <- worker
+	}
+	return pool
+}
+
+// Execute 执行一次 exiftool 命令，args 与命令行参数相同
+// stderr 中有 Error 开头的行时返回 *ExifToolError，同时返回完整的输出
+func (p *ExifToolPool) Execute(ctx context.Context, args ...string) (*utils.CommandResult, error) {
+	for _, arg := range args {
+		if strings.ContainsAny(arg, "\r\n") {
+			return nil, fmt.Errorf("exiftool argument contains line break: %q", arg)
+		}
+	}
+
+	var worker *exifWorker
+	select {
+	case worker = <-p.idle:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { p.idle <- worker }()
+
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return nil, ErrExifToolPoolClosed
+	}
+
+	result, err := worker.execute(ctx, p.timeout, args)
+	if err != nil && !errors.As(err, new(*ExifToolError)) && ctx.Err() == nil {
+		utils.NotifyCommandFailed(p.path, result, err)
+	}
+	return result, err
+}
+
+// Close 结束所有进程
+func (p *ExifToolPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	workers := p.workers
+	p.mu.Unlock()
+
+	// 等待执行中的请求归还进程，全部结束后放回，之后的请求直接返回关闭错误
+	taken := make([]*exifWorker, 0, len(workers))
+	for range workers {
+		worker := <-p.idle
+		worker.close()
+		taken = append(taken, worker)
+	}
+	for _, worker := range taken {
+		p.idle <- worker
+	}
+}
+
+// exifWorker 单个 exiftool 进程，同一时间只处理一个请求
+type exifWorker struct {
+	path   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *bufio.Reader
+	exited chan struct{}
+	seq    int
+}
+
+func (w *exifWorker) start() error {
+	cmd := exec.Command(w.path, "-stay_open", "True", "-@", "-")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start exiftool: %w", err)
+	}
+
+	w.cmd = cmd
+	w.stdin = stdin
+	w.stdout = bufio.NewReader(stdout)
+	w.stderr = bufio.NewReader(stderr)
+	w.exited = make(chan struct{})
+	go func(cmd *exec.Cmd, exited chan struct{}) {
+		_ = cmd.Wait()
+		close(exited)
+	}(cmd, w.exited)
+
+	logger.Info("exiftool 进程已启动", zap.Int("pid", cmd.Process.Pid))
+	return nil
+}
+
+// exifResponse 一次请求的输出
+type exifResponse struct {
+	data []byte
+	err  error
+}
+
+func (w *exifWorker) execute(ctx context.Context, timeout time.Duration, args []string) (*utils.CommandResult, error) {
+	if w.cmd == nil {
+		if err := w.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	w.seq++
+	marker := "{ready" + strconv.Itoa(w.seq) + "}"
+
+	var request strings.Builder
+	if runtime.GOOS == "windows" {
+		// 文件名按 UTF-8 处理
+		request.WriteString("-charset\nfilename=utf8\n")
+	}
+	for _, arg := range args {
+		request.WriteString(arg)
+		request.WriteByte('\n')
+	}
+	request.WriteString("-echo4\n" + marker + "\n")
+	request.WriteString("-execute" + strconv.Itoa(w.seq) + "\n")
+
+	start := time.Now()
+	if _, err := io.WriteString(w.stdin, request.String()); err != nil {
+		w.kill()
+		return nil, fmt.Errorf("exiftool write failed: %w", err)
+	}
+
+	// stdout 和 stderr 同时读取，避免其中一个管道写满导致进程阻塞
+	stdoutCh := make(chan exifResponse, 1)
+	stderrCh := make(chan exifResponse, 1)
+	go readUntilMarker(w.stdout, marker, stdoutCh)
+	go readUntilMarker(w.stderr, marker, stderrCh)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var stdout, stderr exifResponse
+	for received := 0; received < 2; {
+		select {
+		case stdout = <-stdoutCh:
+			stdoutCh = nil
+			received++
+		case stderr = <-stderrCh:
+			stderrCh = nil
+			received++
+		case <-ctx.Done():
+			// 请求无法单独中止，结束进程，下次使用时重新启动
+			w.kill()
+			return nil, fmt.Errorf("exiftool: %w", ctx.Err())
+		case <-timer.C:
+			w.kill()
+			return nil, fmt.Errorf("exiftool timed out after %s: %w", timeout, context.DeadlineExceeded)
+		}
+	}
+
+	result := &utils.CommandResult{
+		Stdout:   stdout.data,
+		Stderr:   stderr.data,
+		Duration: time.Since(start),
+	}
+	if err := errors.Join(stdout.err, stderr.err); err != nil {
+		// 进程崩溃或退出
+		w.kill()
+		result.ExitCode = -1
+		return result, fmt.Errorf("exiftool exited unexpectedly: %w", err)
+	}
+
+	if message := firstErrorLine(result.Stderr); message != "" {
+		result.ExitCode = 1
+		return result, &ExifToolError{Message: message}
+	}
+	return result, nil
+}
+
+// readUntilMarker 读取到标记为止，标记不包含在结果中
+// -b 输出二进制数据时结尾没有换行，标记紧跟在数据之后，因此按行尾匹配
+func readUntilMarker(reader *bufio.Reader, marker string, out chan<- exifResponse) {
+	var buf bytes.Buffer
+	for {
+		line, err := reader.ReadBytes('\n')
+		if trimmed := bytes.TrimRight(line, "\r\n"); bytes.HasSuffix(trimmed, []byte(marker)) {
+			buf.Write(trimmed[:len(trimmed)-len(marker)])
+			out <- exifResponse{data: buf.Bytes()}
+			return
+		}
+		buf.Write(line)
+		if err != nil {
+			out <- exifResponse{data: buf.Bytes(), err: err}
+			return
+		}
+	}
+}
+
+// firstErrorLine stderr 中第一条错误，警告不算错误
+func firstErrorLine(stderr []byte) string {
+	for _, line := range strings.Split(string(stderr), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Error") {
+			return line
+		}
+	}
+	return ""
+}
+
+// kill 强制结束进程
+func (w *exifWorker) kill() {
+	if w.cmd == nil {
+		return
+	}
+	_ = w.cmd.Process.Kill()
+	<-w.exited
+	w.reset()
+}
+
+// close 通知进程退出，超时后强制结束
+func (w *exifWorker) close() {
+	if w.cmd == nil {
+		return
+	}
+	_, _ = io.WriteString(w.stdin, "-stay_open\nFalse\n")
+	_ = w.stdin.Close()
+	select {
+	case <-w.exited:
+		w.reset()
+	case <-time.After(exifToolCloseTimeout):
+		w.kill()
+	}
+}
+
+func (w *exifWorker) reset() {
+	w.cmd = nil
+	w.stdin = nil
+	w.stdout = nil
+	w.stderr = nil
+	w.exited = nil
+}
@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 模拟 exiftool -stay_open 协议：回显收到的参数，遇到 crash/hang/fail 参数时模拟对应的故障
+const fakeExifTool = `#!/bin/sh
+args=""
+prev=""
+marker=""
+while IFS= read -r line; do
+	case "$line" in
+	-execute*)
+		case "$args" in
+		*" crash"*) exit 3 ;;
+		*" hang"*) sleep 10 ;;
+		*" fail"*) echo "Error: File not found - fail" >&2 ;;
+		esac
+		case "$args" in
+		*" binary"*) printf 'BIN' ;;
+		*) echo "args:$args" ;;
+		esac
+		echo "{ready${line#-execute}}"
+		echo "$marker" >&2
+		args=""
+		;;
+	-stay_open) ;;
+	False) exit 0 ;;
+	*)
+		if [ "$prev" = "-echo4" ]; then
+			marker="$line"
+		elif [ "$line" != "-echo4" ]; then
+			args="$args $line"
+		fi
+		;;
+	esac
+	prev="$line"
+done
+`
+
+func newFakePool(t *testing.T, size int, timeout time.Duration) *ExifToolPool {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake exiftool requires sh")
+	}
+	path := filepath.Join(t.TempDir(), "exiftool")
+	if err := os.WriteFile(path, []byte(fakeExifTool), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	pool := NewExifToolPool(path, size, timeout)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestExifToolPoolExecute(t *testing.T) {
+	pool := newFakePool(t, 2, 5*time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := pool.Execute(context.Background(), "-j", "-n", "a.jpg")
+			if err != nil {
+				t.Errorf("execute: %v", err)
+				return
+			}
+			if got := strings.TrimSpace(string(result.Stdout)); got != "args: -j -n a.jpg" {
+				t.Errorf("stdout = %q", got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, err := pool.Execute(context.Background(), "fail")
+	var exifErr *ExifToolError
+	if !errors.As(err, &exifErr) || !strings.Contains(exifErr.Message, "File not found") {
+		t.Fatalf("execute fail: got %v, want ExifToolError", err)
+	}
+
+	// -b 输出的二进制数据结尾没有换行
+	result, err := pool.Execute(context.Background(), "-b", "binary")
+	if err != nil || string(result.Stdout) != "BIN" {
+		t.Fatalf("binary: got %q, %v", result.Stdout, err)
+	}
+
+	if _, err := pool.Execute(context.Background(), "a\nb"); err == nil {
+		t.Fatal("argument with line break accepted")
+	}
+}
+
+func TestExifToolPoolRestart(t *testing.T) {
+	pool := newFakePool(t, 1, 200*time.Millisecond)
+
+	if _, err := pool.Execute(context.Background(), "crash"); err == nil {
+		t.Fatal("crash: want error")
+	}
+	if _, err := pool.Execute(context.Background(), "a.jpg"); err != nil {
+		t.Fatalf("execute after crash: %v", err)
+	}
+
+	if _, err := pool.Execute(context.Background(), "hang"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("hang: got %v, want DeadlineExceeded", err)
+	}
+	if _, err := pool.Execute(context.Background(), "a.jpg"); err != nil {
+		t.Fatalf("execute after timeout: %v", err)
+	}
+
+	pool.Close()
+	if _, err := pool.Execute(context.Background(), "a.jpg"); !errors.Is(err, ErrExifToolPoolClosed) {
+		t.Fatalf("execute after close: got %v, want ErrExifToolPoolClosed", err)
+	}
+}
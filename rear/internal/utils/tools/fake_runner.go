@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"rear/internal/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeRunner 可编程的 ToolRunner，用于在没有外部工具的环境中测试处理流程
+// 按工具和参数返回预设的输出、延迟和错误，并记录每次调用
+type FakeRunner struct {
+	mu    sync.Mutex
+	rules map[string][]fakeRule
+	paths map[string]string
+	calls []FakeCall
+}
+
+// FakeCall 一次工具调用
+type FakeCall struct {
+	Tool string
+	Args []string
+}
+
+// FakeResponse 预设的执行结果
+type FakeResponse struct {
+	Stdout []byte
+	Stderr []byte
+	// 非 0 时返回 *utils.CommandError
+	ExitCode int
+	// 返回前等待的时间，期间 ctx 取消时返回 ctx 的错误
+	Delay time.Duration
+	// 直接返回的错误，优先于 ExitCode
+	Err error
+	// 返回前执行，如写入输出文件
+	Do func(args []string) error
+}
+
+type fakeRule struct {
+	match    string
+	response FakeResponse
+}
+
+// NewFakeRunner 创建没有任何工具的执行器，所有工具都返回 ErrToolNotFound
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{rules: make(map[string][]fakeRule), paths: make(map[string]string)}
+}
+
+// SetPath 设置 Path 返回的工具路径【默认为 fake/工具名称】，如区分 RAW 解码命令的类型
+func (f *FakeRunner) SetPath(tool, path string) *FakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths[tool] = path
+	return f
+}
+
+// On 设置工具的执行结果，match 为参数中需要包含的内容【为空时匹配所有调用】
+// 同一工具的规则按后设置的优先匹配；设置后该工具视为已安装
+func (f *FakeRunner) On(tool, match string, response FakeResponse) *FakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules[tool] = append([]fakeRule{{match: match, response: response}}, f.rules[tool]...)
+	return f
+}
+
+// Calls 已执行的调用，tool 为空时返回所有工具的调用
+func (f *FakeRunner) Calls(tool string) []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []FakeCall
+	for _, call := range f.calls {
+		if tool == "" || call.Tool == tool {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func (f *FakeRunner) Path(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.rules[name]; !ok {
+		return "", fmt.Errorf("%s %w", name, utils.ErrToolNotFound)
+	}
+	if path := f.paths[name]; path != "" {
+		return path, nil
+	}
+	return filepath.Join("fake", name), nil
+}
+
+func (f *FakeRunner) Run(ctx context.Context, name string, args ...string) (*utils.CommandResult, error) {
+	f.mu.Lock()
+	rules, ok := f.rules[name]
+	if !ok {
+		f.mu.Unlock()
+		return nil, fmt.Errorf("%s %w", name, utils.ErrToolNotFound)
+	}
+	f.calls = append(f.calls, FakeCall{Tool: name, Args: append([]string(nil), args...)})
+	var response *FakeResponse
+	joined := strings.Join(args, " ")
+	for i := range rules {
+		if strings.Contains(joined, rules[i].match) {
+			response = &rules[i].response
+			break
+		}
+	}
+	f.mu.Unlock()
+	if response == nil {
+		return nil, fmt.Errorf("fake %s: no response for %q", name, joined)
+	}
+
+	start := time.Now()
+	if response.Delay > 0 {
+		timer := time.NewTimer(response.Delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return &utils.CommandResult{ExitCode: -1, Duration: time.Since(start)}, fmt.Errorf("%s: %w", name, ctx.Err())
+		}
+	}
+
+	result := &utils.CommandResult{
+		Stdout:   response.Stdout,
+		Stderr:   response.Stderr,
+		ExitCode: response.ExitCode,
+	}
+	err := response.Err
+	if err == nil && response.Do != nil {
+		err = response.Do(args)
+	}
+	if err == nil && response.ExitCode != 0 {
+		err = &utils.CommandError{
+			Program:  name,
+			ExitCode: response.ExitCode,
+			Stderr:   string(response.Stderr),
+			Err:      fmt.Errorf("exit status %d", response.ExitCode),
+		}
+	}
+	result.Duration = time.Since(start)
+	return result, err
+}
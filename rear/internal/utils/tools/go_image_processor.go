@@ -0,0 +1,223 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/image/draw"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+
+	// 注册解码器
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+	_ "image/gif"
+)
+
+// GoProcessor 纯 Go 实现的图像处理后端，不依赖外部工具
+// 读取 JPEG、PNG、GIF、WebP、BMP、TIFF，写入 JPEG、PNG；速度较慢，只在没有其他后端时使用
+type GoProcessor struct{}
+
+func (GoProcessor) Name() string {
+	return "go"
+}
+
+// Version 处理逻辑变化时递增
+func (GoProcessor) Version(context.Context) (string, error) {
+	return "1", nil
+}
+
+func (GoProcessor) Detect(context.Context) (*FormatSupport, error) {
+	formats := newFormatSupport()
+	formats.addRead("jpeg", "png", "gif", "webp", "bmp", "tiff")
+	formats.addWrite("jpeg", "png")
+	return formats, nil
+}
+
+func (GoProcessor) Process(ctx context.Context, input, output string, options *ProcessOptions) error {
+	if options == nil {
+		options = DefaultOptions()
+	}
+	format := outputFormatOf(output, options)
+	if format != "jpeg" && format != "png" {
+		return fmt.Errorf("%w: go processor cannot write %s", ErrUnsupportedFormat, format)
+	}
+
+	file, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	src, _, err := image.Decode(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("decode %s failed: %w", filepath.Base(input), err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 方向为 5-8 时宽高互换，先按显示方向计算尺寸，缩放后再旋转
+	orientation := 1
+	if options.AutoRotate {
+		orientation = readOrientation(ctx, input)
+	}
+	sizing := *options
+	if orientation >= 5 && orientation <= 8 {
+		sizing.Width, sizing.Height = sizing.Height, sizing.Width
+	}
+	img := orientImage(resizeImage(src, &sizing), orientation)
+
+	if format == "jpeg" {
+		img = flattenImage(img, options.Background)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "jpeg":
+		quality := options.Quality
+		if quality <= 0 || quality > 100 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(out, img, &jpeg.Options{Quality: quality})
+	case "png":
+		encoder := png.Encoder{CompressionLevel: png.DefaultCompression}
+		if options.Optimize {
+			encoder.CompressionLevel = png.BestCompression
+		}
+		err = encoder.Encode(out, img)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(output)
+		return fmt.Errorf("encode %s failed: %w", format, err)
+	}
+	return nil
+}
+
+// readOrientation 读取 EXIF 方向，读取失败时按正常方向处理
+func readOrientation(ctx context.Context, path string) int {
+	data, err := NativeReader{}.Read(ctx, path)
+	if err != nil {
+		return 1
+	}
+	if orientation, ok := toFloat(data["Orientation"]); ok && orientation >= 1 && orientation <= 8 {
+		return int(orientation)
+	}
+	return 1
+}
+
+// resizeImage 按选项缩放，规则与 vips thumbnail 相同
+func resizeImage(src image.Image, options *ProcessOptions) *image.RGBA {
+	bounds := src.Bounds()
+	width, height := float64(bounds.Dx()), float64(bounds.Dy())
+
+	scale := 1.0
+	cover := false
+	switch {
+	case options.Width > 0 && options.Height > 0:
+		scaleX, scaleY := float64(options.Width)/width, float64(options.Height)/height
+		if options.Crop {
+			scale, cover = math.Max(scaleX, scaleY), true
+		} else {
+			scale = math.Min(scaleX, scaleY)
+		}
+	case options.Width > 0:
+		scale = float64(options.Width) / width
+	case options.Height > 0:
+		scale = float64(options.Height) / height
+	case options.MaxSize > 0:
+		scale = float64(options.MaxSize) / math.Max(width, height)
+	}
+	if options.NoEnlarge {
+		scale = math.Min(scale, 1)
+	}
+
+	scaledWidth := max(1, int(math.Round(width*scale)))
+	scaledHeight := max(1, int(math.Round(height*scale)))
+	dst := image.NewRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+	if scale == 1 {
+		draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	} else {
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	}
+
+	if cover {
+		// 居中裁剪到目标尺寸
+		cropWidth, cropHeight := min(options.Width, scaledWidth), min(options.Height, scaledHeight)
+		x, y := (scaledWidth-cropWidth)/2, (scaledHeight-cropHeight)/2
+		cropped := image.NewRGBA(image.Rect(0, 0, cropWidth, cropHeight))
+		draw.Draw(cropped, cropped.Bounds(), dst, image.Pt(x, y), draw.Src)
+		return cropped
+	}
+	return dst
+}
+
+// orientImage 按 EXIF 方向旋转或翻转为正常方向
+func orientImage(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+
+	// 目标坐标对应的源坐标
+	var source func(x, y int) (int, int)
+	switch orientation {
+	case 2: // 水平翻转
+		source = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3: // 旋转 180°
+		source = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4: // 垂直翻转
+		source = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5: // 沿左上-右下对角线翻转
+		source = func(x, y int) (int, int) { return y, x }
+	case 6: // 顺时针旋转 90°
+		source = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7: // 沿右上-左下对角线翻转
+		source = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8: // 逆时针旋转 90°
+		source = func(x, y int) (int, int) { return w - 1 - y, x }
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			sx, sy := source(x, y)
+			si := src.PixOffset(sx+src.Rect.Min.X, sy+src.Rect.Min.Y)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// flattenImage 透明区域填充背景色【JPEG 不支持透明】
+func flattenImage(src *image.RGBA, background string) *image.RGBA {
+	if src.Opaque() {
+		return src
+	}
+	bg := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	if background == "black" {
+		bg = color.RGBA{A: 255}
+	}
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Over)
+	return dst
+}
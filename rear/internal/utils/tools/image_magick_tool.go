@@ -3,7 +3,11 @@ package tools
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"rear/internal/utils"
+	"strconv"
+	"strings"
 )
 
 // ImageInfo 图片信息
@@ -16,50 +20,171 @@ type ImageInfo struct {
 }
 
 // ConvertImage 使用 ImageMagick 转换图片
-// 示例: ConvertImage(ctx, "input.jpg", "output.png", "-quality", "90")
-func ConvertImage(ctx context.Context, input, output string, options ...string) error {
-	if err := utils.EnsureInitialized(); err != nil {
-		return err
-	}
-
+// 示例: ConvertImage(ctx, runner, "input.jpg", "output.png", "-quality", "90")
+func ConvertImage(ctx context.Context, runner ToolRunner, input, output string, options ...string) error {
 	args := append([]string{input}, options...)
 	args = append(args, output)
 
-	result, err := utils.ExecuteCommand(ctx, utils.ImageMagickPath, args...)
+	_, err := runner.Run(ctx, utils.ToolImageMagick, args...)
 	if err != nil {
-		return fmt.Errorf("convert failed: %w, stderr: %s", err, string(result.Stderr))
+		return fmt.Errorf("convert failed: %w", err)
 	}
 
 	return nil
 }
 
 // ResizeImage 调整图片大小
-func ResizeImage(ctx context.Context, input, output string, width, height int) error {
+func ResizeImage(ctx context.Context, runner ToolRunner, input, output string, width, height int) error {
 	size := fmt.Sprintf("%dx%d", width, height)
-	return ConvertImage(ctx, input, output, "-resize", size)
+	return ConvertImage(ctx, runner, input, output, "-resize", size)
 }
 
 // ResizeImageKeepAspect 按比例调整图片大小（保持宽高比）
-func ResizeImageKeepAspect(ctx context.Context, input, output string, maxWidth, maxHeight int) error {
+func ResizeImageKeepAspect(ctx context.Context, runner ToolRunner, input, output string, maxWidth, maxHeight int) error {
 	size := fmt.Sprintf("%dx%d>", maxWidth, maxHeight)
-	return ConvertImage(ctx, input, output, "-resize", size)
+	return ConvertImage(ctx, runner, input, output, "-resize", size)
 }
 
 // CropImage 裁剪图片
-func CropImage(ctx context.Context, input, output string, width, height, x, y int) error {
+func CropImage(ctx context.Context, runner ToolRunner, input, output string, width, height, x, y int) error {
 	crop := fmt.Sprintf("%dx%d+%d+%d", width, height, x, y)
-	return ConvertImage(ctx, input, output, "-crop", crop)
+	return ConvertImage(ctx, runner, input, output, "-crop", crop)
 }
 
 // RotateImage 旋转图片
-func RotateImage(ctx context.Context, input, output string, degrees float64) error {
-	return ConvertImage(ctx, input, output, "-rotate", fmt.Sprintf("%.2f", degrees))
+func RotateImage(ctx context.Context, runner ToolRunner, input, output string, degrees float64) error {
+	return ConvertImage(ctx, runner, input, output, "-rotate", fmt.Sprintf("%.2f", degrees))
 }
 
 // IsImageMagickAvailable 检查 ImageMagick 是否可用
-func IsImageMagickAvailable() bool {
-	if err := utils.EnsureInitialized(); err != nil {
-		return false
+func IsImageMagickAvailable(runner ToolRunner) bool {
+	_, err := runner.Path(utils.ToolImageMagick)
+	return err == nil
+}
+
+// MagickProcessor ImageMagick 图像处理后端
+type MagickProcessor struct {
+	runner ToolRunner
+}
+
+// NewMagickProcessor 通过 runner 执行 ImageMagick 的后端
+func NewMagickProcessor(runner ToolRunner) MagickProcessor {
+	return MagickProcessor{runner: runner}
+}
+
+func (MagickProcessor) Name() string {
+	return "imagemagick"
+}
+
+// Detect 通过 -list format 获取支持的格式
+func (p MagickProcessor) Detect(ctx context.Context) (*FormatSupport, error) {
+	result, err := p.runner.Run(ctx, utils.ToolImageMagick, "-list", "format")
+	if err != nil {
+		return nil, fmt.Errorf("magick -list format failed: %w", err)
+	}
+	formats := parseMagickFormats(string(result.Stdout))
+	if len(formats.Read) == 0 {
+		return nil, fmt.Errorf("magick -list format: no formats found")
+	}
+	return formats, nil
+}
+
+// Version -version 输出的版本，如 ImageMagick 7.1.1-15 Q16-HDRI x86_64 ...
+func (p MagickProcessor) Version(ctx context.Context) (string, error) {
+	result, err := p.runner.Run(ctx, utils.ToolImageMagick, "-version")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimPrefix(firstLine(string(result.Stdout)), "Version:")), nil
+}
+
+func (p MagickProcessor) Process(ctx context.Context, input, output string, options *ProcessOptions) error {
+	if options == nil {
+		options = DefaultOptions()
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	format := outputFormatOf(output, options)
+	// 多帧图像只取第一帧
+	return ConvertImage(ctx, p.runner, input+"[0]", format+":"+output, buildMagickArgs(format, options)...)
+}
+
+// buildMagickArgs 构建与 vips 参数效果相同的 ImageMagick 参数
+func buildMagickArgs(format string, options *ProcessOptions) []string {
+	var args []string
+	if options.AutoRotate {
+		args = append(args, "-auto-orient")
+	}
+
+	suffix := ""
+	if options.NoEnlarge {
+		suffix = ">"
+	}
+	switch {
+	case options.Width > 0 && options.Height > 0 && options.Crop:
+		// 缩放到覆盖目标尺寸后居中裁剪
+		size := fmt.Sprintf("%dx%d", options.Width, options.Height)
+		args = append(args, "-thumbnail", size+"^"+suffix, "-gravity", "center", "-extent", size)
+	case options.Width > 0 && options.Height > 0:
+		args = append(args, "-thumbnail", fmt.Sprintf("%dx%d%s", options.Width, options.Height, suffix))
+	case options.Width > 0:
+		args = append(args, "-thumbnail", fmt.Sprintf("%d%s", options.Width, suffix))
+	case options.Height > 0:
+		args = append(args, "-thumbnail", fmt.Sprintf("x%d%s", options.Height, suffix))
+	case options.MaxSize > 0:
+		args = append(args, "-thumbnail", fmt.Sprintf("%dx%d%s", options.MaxSize, options.MaxSize, suffix))
+	}
+
+	if options.Strip {
+		args = append(args, "-strip")
+	}
+	if options.Quality > 0 && options.Quality <= 100 {
+		args = append(args, "-quality", strconv.Itoa(options.Quality))
+	}
+
+	switch format {
+	case "jpeg":
+		if options.Background != "" {
+			args = append(args, "-background", options.Background, "-alpha", "remove", "-alpha", "off")
+		}
+		if options.Interlace {
+			args = append(args, "-interlace", "Plane")
+		}
+	case "webp":
+		if options.WebPLossless {
+			args = append(args, "-define", "webp:lossless=true")
+		}
+		if options.WebPEffort >= 0 && options.WebPEffort <= 6 {
+			args = append(args, "-define", "webp:method="+strconv.Itoa(options.WebPEffort))
+		}
+	}
+	return args
+}
+
+// parseMagickFormats 解析 -list format 的输出，例如：
+//
+//	JPEG* JPEG      rw-   Joint Photographic Experts Group JFIF format
+//
+// 第三列为模式：r 可读，w 可写
+func parseMagickFormats(output string) *FormatSupport {
+	formats := newFormatSupport()
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		mode := fields[2]
+		if len(mode) != 3 || strings.Trim(mode, "rw+-") != "" {
+			continue
+		}
+		name := strings.TrimRight(fields[0], "*")
+		if mode[0] == 'r' {
+			formats.addRead(name)
+		}
+		if mode[1] == 'w' {
+			formats.addWrite(name)
+		}
 	}
-	return utils.ImageMagickPath != ""
+	return formats
 }
@@ -0,0 +1,247 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"path/filepath"
+	"rear/internal/utils"
+	"rear/pkg/logger"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 检测后端支持格式的超时
+const detectTimeout = 10 * time.Second
+
+// ImageProcessor 图像处理后端
+type ImageProcessor interface {
+	// Name 后端名称
+	Name() string
+	// Detect 检测后端是否可用及支持的格式，外部工具缺失时返回 ErrToolNotFound
+	Detect(ctx context.Context) (*FormatSupport, error)
+	// Version 后端版本，版本变化时缓存的处理结果失效
+	Version(ctx context.Context) (string, error)
+	// Process 按选项缩放、旋转并转换格式
+	Process(ctx context.Context, input, output string, options *ProcessOptions) error
+}
+
+// FormatSupport 后端支持读取和写入的格式【小写，jpg 统一为 jpeg，tif 统一为 tiff】
+type FormatSupport struct {
+	Read  map[string]bool
+	Write map[string]bool
+}
+
+func newFormatSupport() *FormatSupport {
+	return &FormatSupport{Read: make(map[string]bool), Write: make(map[string]bool)}
+}
+
+func (f *FormatSupport) addRead(formats ...string) {
+	for _, format := range formats {
+		if format = NormalizeFormat(format); format != "" {
+			f.Read[format] = true
+		}
+	}
+}
+
+func (f *FormatSupport) addWrite(formats ...string) {
+	for _, format := range formats {
+		if format = NormalizeFormat(format); format != "" {
+			f.Write[format] = true
+		}
+	}
+}
+
+// CanProcess 是否可以读取 input 格式并写入 output 格式
+func (f *FormatSupport) CanProcess(input, output string) bool {
+	return f != nil && f.Read[input] && f.Write[output]
+}
+
+// NormalizeFormat 格式名称或扩展名统一为小写的格式名称
+func NormalizeFormat(format string) string {
+	format = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(format), "."))
+	switch format {
+	case "jpg", "jpe", "jfif":
+		return "jpeg"
+	case "tif":
+		return "tiff"
+	}
+	return format
+}
+
+// ProcessorStatus 后端的检测结果
+type ProcessorStatus struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version,omitempty"`
+	Read    []string `json:"read"`
+	Write   []string `json:"write"`
+	// 不可用的原因，为空表示可用
+	Error string `json:"error,omitempty"`
+}
+
+type processorEntry struct {
+	processor ImageProcessor
+	formats   *FormatSupport
+	version   string
+	err       error
+}
+
+// ImageProcessors 按优先级排列的图像处理后端
+type ImageProcessors struct {
+	processors []ImageProcessor
+
+	mu sync.Mutex
+	// 检测结果，Reset 后为 nil，只整体替换不修改，可以在锁外遍历
+	entries []processorEntry
+}
+
+// NewImageProcessors 创建后端列表，processors 为空时使用默认的后端
+// 默认按优先级排列：vips 速度最快，ImageMagick 格式最多，内置实现不依赖外部工具
+func NewImageProcessors(runner ToolRunner, processors ...ImageProcessor) *ImageProcessors {
+	if len(processors) == 0 {
+		processors = []ImageProcessor{NewVipsProcessor(runner), NewMagickProcessor(runner), GoProcessor{}}
+	}
+	return &ImageProcessors{processors: processors}
+}
+
+// Detect 检测所有后端支持的格式，结果保留到 Reset【启动时调用，未调用时在第一次处理图像时执行】
+func (p *ImageProcessors) Detect() []ProcessorStatus {
+	entries := p.detect()
+	statuses := make([]ProcessorStatus, 0, len(entries))
+	for _, entry := range entries {
+		status := ProcessorStatus{Name: entry.processor.Name(), Version: entry.version}
+		if entry.err != nil {
+			status.Error = entry.err.Error()
+		}
+		if entry.formats != nil {
+			status.Read = sortedFormats(entry.formats.Read)
+			status.Write = sortedFormats(entry.formats.Write)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Reset 清除检测结果，下次使用时重新检测【工具路径变化后调用】
+func (p *ImageProcessors) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries = nil
+}
+
+// detect 返回检测结果，尚未检测时检测各后端
+func (p *ImageProcessors) detect() []processorEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.entries != nil {
+		return p.entries
+	}
+
+	entries := make([]processorEntry, 0, len(p.processors))
+	for _, processor := range p.processors {
+		ctx, cancel := context.WithTimeout(context.Background(), detectTimeout)
+		formats, err := processor.Detect(ctx)
+		version := ""
+		if err == nil {
+			version, err = processor.Version(ctx)
+		}
+		cancel()
+
+		entries = append(entries, processorEntry{processor: processor, formats: formats, version: version, err: err})
+		if err != nil {
+			logger.Warn("图像处理后端不可用", zap.String("processor", processor.Name()), zap.Error(err))
+			continue
+		}
+		logger.Info("图像处理后端可用",
+			zap.String("processor", processor.Name()),
+			zap.String("version", version),
+			zap.Strings("read", sortedFormats(formats.Read)),
+			zap.Strings("write", sortedFormats(formats.Write)),
+		)
+	}
+	p.entries = entries
+	return entries
+}
+
+func sortedFormats(formats map[string]bool) []string {
+	result := make([]string, 0, len(formats))
+	for format := range formats {
+		result = append(result, format)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Version 可用后端的名称和版本，任一后端升级或可用的后端变化时改变
+func (p *ImageProcessors) Version() string {
+	var versions []string
+	for _, entry := range p.detect() {
+		if entry.err == nil {
+			versions = append(versions, entry.processor.Name()+" "+entry.version)
+		}
+	}
+	return strings.Join(versions, "; ")
+}
+
+// CanRead 是否有可用的后端能读取该格式
+func (p *ImageProcessors) CanRead(format string) bool {
+	format = NormalizeFormat(format)
+	for _, entry := range p.detect() {
+		if entry.err == nil && entry.formats.Read[format] {
+			return true
+		}
+	}
+	return false
+}
+
+// CanWrite 是否有可用的后端能写入该格式
+func (p *ImageProcessors) CanWrite(format string) bool {
+	format = NormalizeFormat(format)
+	for _, entry := range p.detect() {
+		if entry.err == nil && entry.formats.Write[format] {
+			return true
+		}
+	}
+	return false
+}
+
+// Process 选择可用的后端处理图像，后端工具缺失或不支持该格式时依次尝试下一个
+// 输出格式取 options.Format，为空时按输出文件扩展名
+func (p *ImageProcessors) Process(ctx context.Context, input, output string, options *ProcessOptions) error {
+	entries := p.detect()
+	if options == nil {
+		options = DefaultOptions()
+	}
+	inputFormat := NormalizeFormat(filepath.Ext(input))
+	outputFormat := outputFormatOf(output, options)
+
+	var errs []error
+	for _, entry := range entries {
+		if entry.err != nil || !entry.formats.CanProcess(inputFormat, outputFormat) {
+			continue
+		}
+		err := entry.processor.Process(ctx, input, output, options)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, utils.ErrToolNotFound) && !errors.Is(err, ErrUnsupportedFormat) {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", entry.processor.Name(), err))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return fmt.Errorf("%w: no image processor can convert %s to %s", ErrUnsupportedFormat, inputFormat, outputFormat)
+}
+
+// outputFormatOf 输出格式
+func outputFormatOf(output string, options *ProcessOptions) string {
+	if options.Format != "" {
+		return NormalizeFormat(options.Format)
+	}
+	return NormalizeFormat(filepath.Ext(output))
+}
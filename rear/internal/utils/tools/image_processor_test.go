@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"rear/internal/utils"
+	"strings"
+	"testing"
+)
+
+func TestParseVipsFormats(t *testing.T) {
+	output := `VipsForeign (foreign), load and save image files
+  VipsForeignLoad (fileload), file loaders
+    VipsForeignLoadJpegFile (jpegload), load jpeg from file (.jpg, .jpeg, .jpe, .jfif), priority=50, is_a, get_flags, header, load
+    VipsForeignLoadHeifFile (heifload), load a HEIF image (.heic, .heif, .avif), priority=0, is_a, get_flags, header, load
+  VipsForeignSave (filesave), file savers
+    VipsForeignSaveJpegFile (jpegsave), save image to jpeg file (.jpg, .jpeg, .jpe, .jfif), priority=0, mono rgb cmyk
+    VipsForeignSaveWebpFile (webpsave), save as WebP (.webp), priority=0, rgb alpha
+`
+	formats := parseVipsFormats(output)
+	for _, format := range []string{"jpeg", "heic", "heif", "avif"} {
+		if !formats.Read[format] {
+			t.Errorf("read %s: want true", format)
+		}
+	}
+	if !formats.CanProcess("heic", "webp") || formats.Write["heic"] {
+		t.Errorf("write = %v", formats.Write)
+	}
+}
+
+func TestParseMagickFormats(t *testing.T) {
+	output := `   Format  Module    Mode  Description
+-------------------------------------------------------------------------------
+      AVIF  HEIC      rw+   AV1 Image File Format (1.17.6)
+      JPEG* JPEG      rw-   Joint Photographic Experts Group JFIF format (80)
+       PDF* PDF       rw+   Portable Document Format
+      RAW*  RAW       r--   Raw image
+           See https://imagemagick.org/script/formats.php for more information.
+`
+	formats := parseMagickFormats(output)
+	if !formats.CanProcess("avif", "jpeg") || !formats.Read["raw"] || formats.Write["raw"] {
+		t.Errorf("formats = %+v", formats)
+	}
+	if formats.Read["format"] || formats.Read["see"] {
+		t.Errorf("header parsed as format: %+v", formats.Read)
+	}
+}
+
+func TestGoProcessorAutoRotate(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 30)), nil); err != nil {
+		t.Fatal(err)
+	}
+	// 方向为 6，显示时顺时针旋转 90°
+	exif := append([]byte("Exif\x00\x00"), sampleTIFF()...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(exif)+2))
+	input := writeFile(t, "a.jpg", append([]byte{0xFF, 0xD8}, append(append(app1, exif...), buf.Bytes()[2:]...)...))
+	output := filepath.Join(t.TempDir(), "thumb", "a.jpg")
+
+	err := GoProcessor{}.Process(context.Background(), input, output, &ProcessOptions{
+		MaxSize:    20,
+		Quality:    80,
+		AutoRotate: true,
+		NoEnlarge:  true,
+	})
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	config, err := jpeg.DecodeConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 15 || config.Height != 20 {
+		t.Errorf("size = %dx%d, want 15x20", config.Width, config.Height)
+	}
+}
+
+func TestOrientImage(t *testing.T) {
+	// 2x1：左红右蓝
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Pix[0], src.Pix[3] = 255, 255
+	src.Pix[6], src.Pix[7] = 255, 255
+
+	// 顺时针旋转 90° 后为 1x2，上红下蓝
+	dst := orientImage(src, 6)
+	if dst.Bounds().Dx() != 1 || dst.Bounds().Dy() != 2 {
+		t.Fatalf("size = %v", dst.Bounds())
+	}
+	if dst.Pix[0] != 255 || dst.Pix[6] != 255 {
+		t.Errorf("pixels = %v", dst.Pix)
+	}
+	// 逆时针旋转 90° 后上蓝下红
+	dst = orientImage(src, 8)
+	if dst.Pix[2] != 255 || dst.Pix[4] != 255 {
+		t.Errorf("pixels = %v", dst.Pix)
+	}
+}
+
+func TestDiagnoseWithoutTools(t *testing.T) {
+	diagnostics := Diagnose(context.Background(), NewToolkit(NewFakeRunner()))
+	if len(diagnostics.Tools) != 3 {
+		t.Fatalf("tools = %+v", diagnostics.Tools)
+	}
+	// 内置后端始终可用
+	last := diagnostics.Processors[len(diagnostics.Processors)-1]
+	if last.Name != "go" || last.Error != "" || len(last.Write) == 0 {
+		t.Errorf("go processor = %+v", last)
+	}
+	for _, tool := range diagnostics.Tools {
+		if !tool.Found && tool.Error == "" {
+			t.Errorf("%s: missing tool without error", tool.Name)
+		}
+	}
+}
+
+func TestToolkitReload(t *testing.T) {
+	runner := NewFakeRunner()
+	kit := NewToolkit(runner)
+	if name := kit.Metadata.Name(); name != "native" {
+		t.Fatalf("metadata reader = %s, want native", name)
+	}
+	vips := kit.Processors.Detect()[0]
+	if !strings.Contains(vips.Error, utils.ErrToolNotFound.Error()) {
+		t.Fatalf("vips = %+v, want tool not found", vips)
+	}
+
+	// 安装工具后重新检测：切换到 exiftool，vips 按新的执行结果检测
+	runner.On(utils.ToolExifTool, "-ver", FakeResponse{Stdout: []byte("12.76\n")})
+	runner.On(utils.ToolVips, "", FakeResponse{Err: errors.New("vips broken")})
+	kit.Reload()
+	if name, version := kit.Metadata.Name(), kit.Metadata.Version(); name != "exiftool" || version != "12.76" {
+		t.Errorf("metadata reader = %s %s, want exiftool 12.76", name, version)
+	}
+	if vips := kit.Processors.Detect()[0]; !strings.Contains(vips.Error, "vips broken") {
+		t.Errorf("vips = %+v, want re-detected", vips)
+	}
+
+	// FakeRunner 不支持通过 Toolkit 修改路径
+	if err := kit.SetPath(utils.ToolExifTool, "/usr/bin/exiftool"); err == nil {
+		t.Error("SetPath on FakeRunner succeeded")
+	}
+}
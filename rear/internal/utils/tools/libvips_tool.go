@@ -30,9 +30,10 @@ type ProcessOptions struct {
 	WebPEffort   int  // WebP压缩努力程度 (0-6)
 
 	// 尺寸控制
-	Width  int  // 固定宽度（如果设置则忽略MaxSize）
-	Height int  // 固定高度（如果设置则忽略MaxSize）
-	Crop   bool // 是否裁剪以填充指定尺寸
+	Width     int  // 固定宽度（如果设置则忽略MaxSize）
+	Height    int  // 固定高度（如果设置则忽略MaxSize）
+	Crop      bool // 是否裁剪以填充指定尺寸
+	NoEnlarge bool // 原图小于目标尺寸时不放大
 }
 
 // VipsImageInfo vips图片信息
@@ -66,12 +67,8 @@ func DefaultOptions() *ProcessOptions {
 }
 
 // ProcessImageWithVips 使用 libvips 处理图像
-// 示例: ProcessImageWithVips(ctx, "input.jpg", "output.webp", DefaultOptions())
-func ProcessImageWithVips(ctx context.Context, inputPath, outputPath string, options *ProcessOptions) error {
-	if err := utils.EnsureInitialized(); err != nil {
-		return err
-	}
-
+// 示例: ProcessImageWithVips(ctx, runner, "input.jpg", "output.webp", DefaultOptions())
+func ProcessImageWithVips(ctx context.Context, runner ToolRunner, inputPath, outputPath string, options *ProcessOptions) error {
 	if options == nil {
 		options = DefaultOptions()
 	}
@@ -91,67 +88,68 @@ func ProcessImageWithVips(ctx context.Context, inputPath, outputPath string, opt
 	args := buildVipsArgs(inputPath, outputPath, options)
 
 	// 执行命令
-	result, err := utils.ExecuteCommand(ctx, utils.VipsPath, args...)
+	_, err := runner.Run(ctx, utils.ToolVips, args...)
 	if err != nil {
-		return fmt.Errorf("vips command failed: %w, stderr: %s", err, string(result.Stderr))
+		return fmt.Errorf("vips command failed: %w", err)
 	}
 
 	return nil
 }
 
 // ThumbnailImage 生成缩略图（使用vips thumbnail命令）
-func ThumbnailImage(ctx context.Context, input, output string, size int) error {
-	return ProcessImageWithVips(ctx, input, output, &ProcessOptions{
-		MaxSize: size,
-		Quality: 85,
-		Strip:   true,
+func ThumbnailImage(ctx context.Context, runner ToolRunner, input, output string, size int) error {
+	return ProcessImageWithVips(ctx, runner, input, output, &ProcessOptions{
+		MaxSize:    size,
+		Quality:    85,
+		Strip:      true,
+		AutoRotate: true,
 	})
 }
 
 // ResizeImageWithVips 使用vips调整图片大小
-func ResizeImageWithVips(ctx context.Context, input, output string, width, height int) error {
-	return ProcessImageWithVips(ctx, input, output, &ProcessOptions{
-		Width:   width,
-		Height:  height,
-		Quality: 85,
-		Strip:   true,
+func ResizeImageWithVips(ctx context.Context, runner ToolRunner, input, output string, width, height int) error {
+	return ProcessImageWithVips(ctx, runner, input, output, &ProcessOptions{
+		Width:      width,
+		Height:     height,
+		Quality:    85,
+		Strip:      true,
+		AutoRotate: true,
 	})
 }
 
 // ResizeImageKeepAspectVips 使用vips按比例调整图片大小（保持宽高比）
-func ResizeImageKeepAspectVips(ctx context.Context, input, output string, maxSize int) error {
-	return ProcessImageWithVips(ctx, input, output, &ProcessOptions{
-		MaxSize: maxSize,
-		Quality: 85,
-		Strip:   true,
+func ResizeImageKeepAspectVips(ctx context.Context, runner ToolRunner, input, output string, maxSize int) error {
+	return ProcessImageWithVips(ctx, runner, input, output, &ProcessOptions{
+		MaxSize:    maxSize,
+		Quality:    85,
+		Strip:      true,
+		AutoRotate: true,
 	})
 }
 
 // ConvertImageFormat 转换图像格式
-func ConvertImageFormat(ctx context.Context, input, output, format string) error {
-	return ProcessImageWithVips(ctx, input, output, &ProcessOptions{
-		Format:  format,
-		Quality: 85,
-		Strip:   true,
+func ConvertImageFormat(ctx context.Context, runner ToolRunner, input, output, format string) error {
+	return ProcessImageWithVips(ctx, runner, input, output, &ProcessOptions{
+		Format:     format,
+		Quality:    85,
+		Strip:      true,
+		AutoRotate: true,
 	})
 }
 
 // CompressImage 压缩图像
-func CompressImage(ctx context.Context, input, output string, quality int) error {
-	return ProcessImageWithVips(ctx, input, output, &ProcessOptions{
-		Quality:  quality,
-		Strip:    true,
-		Optimize: true,
+func CompressImage(ctx context.Context, runner ToolRunner, input, output string, quality int) error {
+	return ProcessImageWithVips(ctx, runner, input, output, &ProcessOptions{
+		Quality:    quality,
+		Strip:      true,
+		Optimize:   true,
+		AutoRotate: true,
 	})
 }
 
 // GetVipsImageInfo 获取图像信息
-func GetVipsImageInfo(ctx context.Context, imagePath string) (*VipsImageInfo, error) {
-	if err := utils.EnsureInitialized(); err != nil {
-		return nil, err
-	}
-
-	result, err := utils.ExecuteCommand(ctx, utils.VipsPath, "identify", imagePath)
+func GetVipsImageInfo(ctx context.Context, runner ToolRunner, imagePath string) (*VipsImageInfo, error) {
+	result, err := runner.Run(ctx, utils.ToolVips, "identify", imagePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get image info: %w", err)
 	}
@@ -197,7 +195,7 @@ func GetVipsImageInfo(ctx context.Context, imagePath string) (*VipsImageInfo, er
 }
 
 // BatchProcessImages 批量处理图像
-func BatchProcessImages(ctx context.Context, inputDir, outputDir string, options *ProcessOptions) error {
+func BatchProcessImages(ctx context.Context, runner ToolRunner, inputDir, outputDir string, options *ProcessOptions) error {
 	if options == nil {
 		options = DefaultOptions()
 	}
@@ -242,96 +240,177 @@ func BatchProcessImages(ctx context.Context, inputDir, outputDir string, options
 			outputPath = strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + "." + options.Format
 		}
 
-		return ProcessImageWithVips(ctx, path, outputPath, options)
+		return ProcessImageWithVips(ctx, runner, path, outputPath, options)
 	})
 }
 
 // IsVipsAvailable 检查 libvips 是否可用
-func IsVipsAvailable() bool {
-	if err := utils.EnsureInitialized(); err != nil {
-		return false
-	}
-	return utils.VipsPath != ""
+func IsVipsAvailable(runner ToolRunner) bool {
+	_, err := runner.Path(utils.ToolVips)
+	return err == nil
 }
 
 // buildVipsArgs 构建vips命令参数
+// vips thumbnail 只接受尺寸相关参数，编码参数需要以 out.jpg[Q=85,strip] 的形式附加在输出路径后
 func buildVipsArgs(inputPath, outputPath string, options *ProcessOptions) []string {
 	args := []string{"thumbnail"}
 
 	// 输入文件
 	args = append(args, inputPath)
 
-	// 输出文件
-	args = append(args, outputPath)
+	// 输出文件【附带保存参数】
+	args = append(args, outputPath+buildVipsSaveOptions(outputPath, options))
 
 	// 尺寸参数
 	if options.Width > 0 && options.Height > 0 {
+		args = append(args, strconv.Itoa(options.Width), "--height", strconv.Itoa(options.Height))
 		if options.Crop {
 			// 裁剪模式：填充指定尺寸
-			args = append(args, fmt.Sprintf("%dx%d^", options.Width, options.Height))
 			args = append(args, "--crop", "centre")
-		} else {
-			// 适应模式：保持比例
-			args = append(args, fmt.Sprintf("%dx%d", options.Width, options.Height))
 		}
 	} else if options.Width > 0 {
 		args = append(args, strconv.Itoa(options.Width))
 	} else if options.Height > 0 {
-		args = append(args, fmt.Sprintf("x%d", options.Height))
-	} else {
+		// 宽度不限制，只约束高度
+		args = append(args, "10000000", "--height", strconv.Itoa(options.Height))
+	} else if options.MaxSize > 0 {
 		// 使用MaxSize（最长边）
 		args = append(args, strconv.Itoa(options.MaxSize))
+	} else {
+		// 未指定尺寸时保持原尺寸，只转换格式
+		args = append(args, "10000000", "--height", "10000000")
 	}
 
-	// 质量设置
-	if options.Quality > 0 && options.Quality <= 100 {
-		args = append(args, "--quality", strconv.Itoa(options.Quality))
+	// 不放大
+	if options.NoEnlarge || (options.Width <= 0 && options.Height <= 0 && options.MaxSize <= 0) {
+		args = append(args, "--size", "down")
+	}
+
+	// vips 默认根据 EXIF 自动旋转
+	if !options.AutoRotate {
+		args = append(args, "--no-rotate")
 	}
 
-	// 格式转换
-	if options.Format != "" {
-		args = append(args, "--format", options.Format)
+	return args
+}
+
+// buildVipsSaveOptions 构建输出文件的保存参数
+func buildVipsSaveOptions(outputPath string, options *ProcessOptions) string {
+	var opts []string
+
+	// 质量设置
+	if options.Quality > 0 && options.Quality <= 100 {
+		opts = append(opts, "Q="+strconv.Itoa(options.Quality))
 	}
 
 	// 移除元数据
 	if options.Strip {
-		args = append(args, "--strip")
+		opts = append(opts, "strip")
 	}
 
-	// 渐进式JPEG
-	if options.Interlace {
-		args = append(args, "--interlace")
+	format := strings.ToLower(options.Format)
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(outputPath)), ".")
 	}
 
-	// 优化
-	if options.Optimize {
-		args = append(args, "--optimize")
+	switch format {
+	case "jpg", "jpeg":
+		// 渐进式JPEG
+		if options.Interlace {
+			opts = append(opts, "interlace")
+		}
+		// 优化霍夫曼编码
+		if options.Optimize {
+			opts = append(opts, "optimize_coding")
+		}
+		// 透明图像转 JPEG 时的背景色
+		switch options.Background {
+		case "white":
+			opts = append(opts, "background=255")
+		case "black":
+			opts = append(opts, "background=0")
+		}
+	case "webp":
+		// WebP特定选项
+		if options.WebPLossless {
+			opts = append(opts, "lossless")
+		}
+		if options.WebPEffort >= 0 && options.WebPEffort <= 6 {
+			opts = append(opts, "effort="+strconv.Itoa(options.WebPEffort))
+		}
 	}
 
-	// 背景色
-	if options.Background != "" {
-		args = append(args, "--background", options.Background)
+	if len(opts) == 0 {
+		return ""
 	}
+	return "[" + strings.Join(opts, ",") + "]"
+}
+
+// VipsProcessor libvips 图像处理后端
+type VipsProcessor struct {
+	runner ToolRunner
+}
+
+// NewVipsProcessor 通过 runner 执行 vips 的后端
+func NewVipsProcessor(runner ToolRunner) VipsProcessor {
+	return VipsProcessor{runner: runner}
+}
 
-	// 锐化
-	if options.Sharpen {
-		args = append(args, "--sharpen", "1")
+func (VipsProcessor) Name() string {
+	return "vips"
+}
+
+// Detect 通过 vips -l 列出的加载器和保存器获取支持的格式
+func (p VipsProcessor) Detect(ctx context.Context) (*FormatSupport, error) {
+	result, err := p.runner.Run(ctx, utils.ToolVips, "-l", "foreign")
+	if err != nil {
+		return nil, fmt.Errorf("vips -l failed: %w", err)
 	}
+	formats := parseVipsFormats(string(result.Stdout))
+	if len(formats.Read) == 0 {
+		return nil, fmt.Errorf("vips -l: no loaders found")
+	}
+	return formats, nil
+}
 
-	// 自动旋转
-	if options.AutoRotate {
-		args = append(args, "--auto-rotate")
+// Version vips --version 输出的版本，如 vips-8.15.1
+func (p VipsProcessor) Version(ctx context.Context) (string, error) {
+	result, err := p.runner.Run(ctx, utils.ToolVips, "--version")
+	if err != nil {
+		return "", err
 	}
+	return firstLine(string(result.Stdout)), nil
+}
 
-	// WebP特定选项
-	if strings.ToLower(options.Format) == "webp" {
-		if options.WebPLossless {
-			args = append(args, "--webp-lossless")
+func (p VipsProcessor) Process(ctx context.Context, input, output string, options *ProcessOptions) error {
+	return ProcessImageWithVips(ctx, p.runner, input, output, options)
+}
+
+// parseVipsFormats 解析 vips -l 的输出，例如：
+// VipsForeignLoadJpegFile (jpegload), load jpeg from file (.jpg, .jpeg, .jpe, .jfif), priority=50, ...
+func parseVipsFormats(output string) *FormatSupport {
+	formats := newFormatSupport()
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		load := strings.HasPrefix(line, "VipsForeignLoad")
+		save := strings.HasPrefix(line, "VipsForeignSave")
+		if !load && !save {
+			continue
 		}
-		if options.WebPEffort >= 0 && options.WebPEffort <= 6 {
-			args = append(args, "--webp-effort", strconv.Itoa(options.WebPEffort))
+		start := strings.Index(line, "(.")
+		if start < 0 {
+			continue
+		}
+		end := strings.Index(line[start:], ")")
+		if end < 0 {
+			continue
+		}
+		exts := strings.Split(line[start+1:start+end], ",")
+		if load {
+			formats.addRead(exts...)
+		} else {
+			formats.addWrite(exts...)
 		}
 	}
-
-	return args
+	return formats
 }
@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"rear/pkg/logger"
+	"sync"
+)
+
+// MetadataReader 图像元数据读取器，输出的键与 exiftool -j -n 相同，可直接交给 model.SplitExifData
+type MetadataReader interface {
+	// Name 读取器名称
+	Name() string
+	// Version 读取器版本，版本变化时缓存的读取结果失效
+	Version() string
+	// Read 读取单个文件
+	Read(ctx context.Context, path string) (ExifData, error)
+	// ReadBatch 批量读取，结果与 paths 一一对应，单个文件的错误记录在对应结果中
+	ReadBatch(ctx context.Context, paths []string) ([]ExifResult, error)
+}
+
+// ExifToolReader 通过 exiftool 读取，支持的格式和字段最全
+type ExifToolReader struct {
+	runner  ToolRunner
+	version func() string
+}
+
+// NewExifToolReader 通过 runner 执行 exiftool 的读取器
+func NewExifToolReader(runner ToolRunner) ExifToolReader {
+	return ExifToolReader{
+		runner: runner,
+		// 第一次使用时查询，查询失败时返回空字符串
+		version: sync.OnceValue(func() string {
+			ctx, cancel := context.WithTimeout(context.Background(), detectTimeout)
+			defer cancel()
+			version, err := exifToolVersion(ctx, runner)
+			if err != nil {
+				logger.Warn("exiftool 版本查询失败", zap.Error(err))
+			}
+			return version
+		}),
+	}
+}
+
+func (ExifToolReader) Name() string {
+	return "exiftool"
+}
+
+func (r ExifToolReader) Version() string {
+	return r.version()
+}
+
+func (r ExifToolReader) Read(ctx context.Context, path string) (ExifData, error) {
+	return GetExifData(ctx, r.runner, path)
+}
+
+func (r ExifToolReader) ReadBatch(ctx context.Context, paths []string) ([]ExifResult, error) {
+	return GetExifDataBatch(ctx, r.runner, paths)
+}
+
+// NewMetadataReader exiftool 可用时使用 exiftool，否则使用内置读取器
+func NewMetadataReader(runner ToolRunner) MetadataReader {
+	if IsExifToolAvailable(runner) {
+		return NewExifToolReader(runner)
+	}
+	logger.Warn("未找到 exiftool，使用内置读取器，仅支持 JPEG、PNG、WebP 的基本元数据")
+	return NativeReader{}
+}
+
+// switchableReader 按当前工具配置选择的读取器，工具路径变化后由 reload 重新选择
+// 持有读取器的一方【如 EXIF 批量读取】不必重新获取
+type switchableReader struct {
+	runner ToolRunner
+
+	mu     sync.RWMutex
+	reader MetadataReader
+}
+
+func newSwitchableReader(runner ToolRunner) *switchableReader {
+	return &switchableReader{runner: runner, reader: NewMetadataReader(runner)}
+}
+
+func (r *switchableReader) current() MetadataReader {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.reader
+}
+
+// reload 重新选择读取器，版本在下次使用时重新查询
+func (r *switchableReader) reload() {
+	reader := NewMetadataReader(r.runner)
+	r.mu.Lock()
+	r.reader = reader
+	r.mu.Unlock()
+}
+
+func (r *switchableReader) Name() string {
+	return r.current().Name()
+}
+
+func (r *switchableReader) Version() string {
+	return r.current().Version()
+}
+
+func (r *switchableReader) Read(ctx context.Context, path string) (ExifData, error) {
+	return r.current().Read(ctx, path)
+}
+
+func (r *switchableReader) ReadBatch(ctx context.Context, paths []string) ([]ExifResult, error) {
+	return r.current().ReadBatch(ctx, paths)
+}
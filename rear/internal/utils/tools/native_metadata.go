@@ -0,0 +1,688 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedFormat 内置读取器不支持的文件格式
+var ErrUnsupportedFormat = errors.New("unsupported file format")
+
+const (
+	// 文件修改时间格式，与 exiftool 的 FileModifyDate 相同
+	exifFileDateLayout = "2006:01:02 15:04:05-07:00"
+	// 单个元数据块的最大长度，超过时跳过
+	maxMetadataChunk = 16 << 20
+	// 单个 IFD 的最大条目数，超过时视为数据损坏
+	maxIFDEntries = 1024
+)
+
+// NativeReader 纯 Go 实现的元数据读取器，不依赖外部工具
+// 支持 JPEG【APP1 Exif】、PNG【IHDR、eXIf、tEXt、zTXt、iTXt】和 WebP【VP8X/VP8/VP8L、EXIF】
+type NativeReader struct{}
+
+func (NativeReader) Name() string {
+	return "native"
+}
+
+// Version 解析逻辑变化时递增
+func (NativeReader) Version() string {
+	return "1"
+}
+
+func (NativeReader) Read(ctx context.Context, path string) (ExifData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data := ExifData{
+		"SourceFile":     path,
+		"FileName":       info.Name(),
+		"FileSize":       info.Size(),
+		"FileModifyDate": info.ModTime().Format(exifFileDateLayout),
+	}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, fmt.Errorf("%w: file too short", ErrUnsupportedFormat)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8}):
+		err = readJPEGMetadata(file, data)
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		err = readPNGMetadata(file, data)
+	case bytes.HasPrefix(header, []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WEBP")):
+		err = readWebPMetadata(file, data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	addCompositeTags(data)
+	return data, nil
+}
+
+func (r NativeReader) ReadBatch(ctx context.Context, paths []string) ([]ExifResult, error) {
+	results := make([]ExifResult, len(paths))
+	for i, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		results[i].Path = path
+		results[i].Data, results[i].Err = r.Read(ctx, path)
+	}
+	return results, nil
+}
+
+// readJPEGMetadata 读取 JPEG 的尺寸【SOF】和 EXIF【APP1】，读到图像数据【SOS】为止
+func readJPEGMetadata(r io.Reader, data ExifData) error {
+	data["FileType"] = "JPEG"
+	data["FileTypeExtension"] = "jpg"
+	data["MIMEType"] = "image/jpeg"
+
+	reader := bufio.NewReader(r)
+	if _, err := reader.Discard(2); err != nil {
+		return err
+	}
+
+	exifFound := false
+	for {
+		// 标记以 0xFF 开头，之前可能有填充字节
+		b, err := reader.ReadByte()
+		if err != nil {
+			return jpegEOF(err)
+		}
+		if b != 0xFF {
+			continue
+		}
+		marker, err := reader.ReadByte()
+		if err != nil {
+			return jpegEOF(err)
+		}
+		switch {
+		case marker == 0xFF || marker == 0x00 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// 填充或没有长度的标记
+			continue
+		case marker == 0xD9 || marker == 0xDA:
+			// 图像结束或图像数据开始，之后不再有元数据
+			return nil
+		}
+
+		var length uint16
+		if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+			return jpegEOF(err)
+		}
+		if length < 2 {
+			return fmt.Errorf("%w: invalid JPEG segment length", ErrUnsupportedFormat)
+		}
+		size := int(length) - 2
+
+		switch {
+		case marker == 0xE1 && !exifFound:
+			segment := make([]byte, size)
+			if _, err := io.ReadFull(reader, segment); err != nil {
+				return jpegEOF(err)
+			}
+			if bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+				exifFound = true
+				// EXIF 损坏时保留已读取的字段和图像尺寸
+				_ = parseTIFF(segment[6:], data)
+			}
+		case isJPEGSOF(marker):
+			segment := make([]byte, size)
+			if _, err := io.ReadFull(reader, segment); err != nil {
+				return jpegEOF(err)
+			}
+			if len(segment) >= 6 {
+				data["EncodingProcess"] = int(marker - 0xC0)
+				data["BitsPerSample"] = int(segment[0])
+				data["ImageHeight"] = int(binary.BigEndian.Uint16(segment[1:3]))
+				data["ImageWidth"] = int(binary.BigEndian.Uint16(segment[3:5]))
+				data["ColorComponents"] = int(segment[5])
+			}
+		default:
+			if _, err := reader.Discard(size); err != nil {
+				return jpegEOF(err)
+			}
+		}
+	}
+}
+
+// isJPEGSOF 是否为帧头标记【SOF0-SOF15，不包括 DHT、JPG、DAC】
+func isJPEGSOF(marker byte) bool {
+	return marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC
+}
+
+func jpegEOF(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: truncated JPEG", ErrUnsupportedFormat)
+	}
+	return err
+}
+
+// readPNGMetadata 读取 PNG 的 IHDR、eXIf 和文本块，图像数据块直接跳过
+func readPNGMetadata(r io.ReadSeeker, data ExifData) error {
+	data["FileType"] = "PNG"
+	data["FileTypeExtension"] = "png"
+	data["MIMEType"] = "image/png"
+
+	if _, err := r.Seek(8, io.SeekStart); err != nil {
+		return err
+	}
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			// 缺少 IEND 时保留已读取的内容
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return err
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		chunkType := string(header[4:8])
+
+		switch chunkType {
+		case "IHDR", "eXIf", "tEXt", "zTXt", "iTXt":
+			if length > maxMetadataChunk {
+				break
+			}
+			chunk := make([]byte, length)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return fmt.Errorf("%w: truncated PNG", ErrUnsupportedFormat)
+			}
+			readPNGChunk(chunkType, chunk, data)
+			length = 0
+		case "IEND":
+			return nil
+		}
+
+		// 跳过剩余数据和 CRC
+		if _, err := r.Seek(length+4, io.SeekCurrent); err != nil {
+			return err
+		}
+	}
+}
+
+func readPNGChunk(chunkType string, chunk []byte, data ExifData) {
+	switch chunkType {
+	case "IHDR":
+		if len(chunk) >= 10 {
+			data["ImageWidth"] = int(binary.BigEndian.Uint32(chunk[0:4]))
+			data["ImageHeight"] = int(binary.BigEndian.Uint32(chunk[4:8]))
+			data["BitDepth"] = int(chunk[8])
+			data["ColorType"] = int(chunk[9])
+		}
+	case "eXIf":
+		_ = parseTIFF(chunk, data)
+	case "tEXt":
+		if keyword, text, ok := bytes.Cut(chunk, []byte{0}); ok {
+			setPNGText(data, string(keyword), decodeLatin1(text))
+		}
+	case "zTXt":
+		// 关键字\0 压缩方法 压缩的文本
+		if keyword, rest, ok := bytes.Cut(chunk, []byte{0}); ok && len(rest) > 0 {
+			if text, err := inflate(rest[1:]); err == nil {
+				setPNGText(data, string(keyword), decodeLatin1(text))
+			}
+		}
+	case "iTXt":
+		// 关键字\0 压缩标志 压缩方法 语言\0 翻译的关键字\0 文本
+		keyword, rest, ok := bytes.Cut(chunk, []byte{0})
+		if !ok || len(rest) < 2 {
+			return
+		}
+		compressed := rest[0] == 1
+		_, rest, ok = bytes.Cut(rest[2:], []byte{0})
+		if !ok {
+			return
+		}
+		_, text, ok := bytes.Cut(rest, []byte{0})
+		if !ok {
+			return
+		}
+		if compressed {
+			var err error
+			if text, err = inflate(text); err != nil {
+				return
+			}
+		}
+		setPNGText(data, string(keyword), string(text))
+	}
+}
+
+// setPNGText 文本块的关键字去掉空格等字符后作为键，与 exiftool 相同【如 Creation Time -> CreationTime】
+func setPNGText(data ExifData, keyword, text string) {
+	key := strings.Map(func(r rune) rune {
+		if r < 0x80 && (r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return -1
+	}, keyword)
+	if key == "" {
+		return
+	}
+	if _, exists := data[key]; !exists {
+		data[key] = text
+	}
+}
+
+func decodeLatin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func inflate(b []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(io.LimitReader(reader, maxMetadataChunk))
+}
+
+// readWebPMetadata 读取 WebP 的尺寸和 EXIF 块
+func readWebPMetadata(r io.ReadSeeker, data ExifData) error {
+	data["FileType"] = "WEBP"
+	data["FileTypeExtension"] = "webp"
+	data["MIMEType"] = "image/webp"
+
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return err
+	}
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return err
+		}
+		fourCC := string(header[:4])
+		length := int64(binary.LittleEndian.Uint32(header[4:8]))
+		// 块长度为奇数时有一个填充字节
+		skip := length + length%2
+
+		var chunk []byte
+		switch fourCC {
+		case "VP8X", "VP8 ", "VP8L":
+			chunk = make([]byte, min(length, 10))
+		case "EXIF":
+			if length <= maxMetadataChunk {
+				chunk = make([]byte, length)
+			}
+		}
+		if chunk != nil {
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return fmt.Errorf("%w: truncated WebP", ErrUnsupportedFormat)
+			}
+			readWebPChunk(fourCC, chunk, data)
+			skip -= int64(len(chunk))
+		}
+		if _, err := r.Seek(skip, io.SeekCurrent); err != nil {
+			return err
+		}
+	}
+}
+
+func readWebPChunk(fourCC string, chunk []byte, data ExifData) {
+	// VP8X 中的画布尺寸优先，其余格式取第一帧的尺寸
+	_, sized := data["ImageWidth"]
+	switch fourCC {
+	case "VP8X":
+		if len(chunk) >= 10 {
+			data["ImageWidth"] = int(uint24(chunk[4:7])) + 1
+			data["ImageHeight"] = int(uint24(chunk[7:10])) + 1
+		}
+	case "VP8 ":
+		if !sized && len(chunk) >= 10 && bytes.Equal(chunk[3:6], []byte{0x9d, 0x01, 0x2a}) {
+			data["ImageWidth"] = int(binary.LittleEndian.Uint16(chunk[6:8]) & 0x3fff)
+			data["ImageHeight"] = int(binary.LittleEndian.Uint16(chunk[8:10]) & 0x3fff)
+		}
+	case "VP8L":
+		if !sized && len(chunk) >= 5 && chunk[0] == 0x2f {
+			bits := binary.LittleEndian.Uint32(chunk[1:5])
+			data["ImageWidth"] = int(bits&0x3fff) + 1
+			data["ImageHeight"] = int(bits>>14&0x3fff) + 1
+		}
+	case "EXIF":
+		// 部分程序写入时保留了 JPEG 中的 Exif 头
+		_ = parseTIFF(bytes.TrimPrefix(chunk, []byte("Exif\x00\x00")), data)
+	}
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// TIFF 标签，名称与 exiftool 相同
+var (
+	ifd0Tags = map[uint16]string{
+		0x010E: "ImageDescription",
+		0x010F: "Make",
+		0x0110: "Model",
+		0x0112: "Orientation",
+		0x011A: "XResolution",
+		0x011B: "YResolution",
+		0x0128: "ResolutionUnit",
+		0x0131: "Software",
+		0x0132: "ModifyDate",
+		0x013B: "Artist",
+		0x8298: "Copyright",
+	}
+	exifIFDTags = map[uint16]string{
+		0x829A: "ExposureTime",
+		0x829D: "FNumber",
+		0x8822: "ExposureProgram",
+		0x8827: "ISO",
+		0x9003: "DateTimeOriginal",
+		0x9004: "CreateDate",
+		0x9010: "OffsetTime",
+		0x9011: "OffsetTimeOriginal",
+		0x9012: "OffsetTimeDigitized",
+		0x9204: "ExposureCompensation",
+		0x9207: "MeteringMode",
+		0x9209: "Flash",
+		0x920A: "FocalLength",
+		0x9290: "SubSecTime",
+		0x9291: "SubSecTimeOriginal",
+		0xA001: "ColorSpace",
+		0xA002: "ExifImageWidth",
+		0xA003: "ExifImageHeight",
+		0xA402: "ExposureMode",
+		0xA403: "WhiteBalance",
+		0xA405: "FocalLengthIn35mmFormat",
+		0xA431: "SerialNumber",
+		0xA432: "LensInfo",
+		0xA433: "LensMake",
+		0xA434: "LensModel",
+	}
+	gpsIFDTags = map[uint16]string{
+		0x0001: "GPSLatitudeRef",
+		0x0002: "GPSLatitude",
+		0x0003: "GPSLongitudeRef",
+		0x0004: "GPSLongitude",
+		0x0005: "GPSAltitudeRef",
+		0x0006: "GPSAltitude",
+		0x0007: "GPSTimeStamp",
+		0x001D: "GPSDateStamp",
+	}
+)
+
+const (
+	tagExifIFD = 0x8769
+	tagGPSIFD  = 0x8825
+)
+
+// TIFF 数据类型的字节数
+var tiffTypeSize = map[uint16]uint64{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+type tiffReader struct {
+	buf   []byte
+	order binary.ByteOrder
+	// 已读取的 IFD，防止偏移量循环引用
+	visited map[uint32]bool
+}
+
+// parseTIFF 解析 TIFF 结构的 EXIF 数据【IFD0、Exif IFD、GPS IFD】
+func parseTIFF(buf []byte, data ExifData) error {
+	if len(buf) < 8 {
+		return fmt.Errorf("%w: EXIF too short", ErrUnsupportedFormat)
+	}
+	t := &tiffReader{buf: buf, visited: make(map[uint32]bool)}
+	switch string(buf[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return fmt.Errorf("%w: invalid TIFF byte order", ErrUnsupportedFormat)
+	}
+	if t.order.Uint16(buf[2:4]) != 42 {
+		return fmt.Errorf("%w: invalid TIFF header", ErrUnsupportedFormat)
+	}
+
+	pointers, err := t.readIFD(t.order.Uint32(buf[4:8]), ifd0Tags, data)
+	if err != nil {
+		return err
+	}
+	if offset, ok := pointers[tagExifIFD]; ok {
+		if _, err := t.readIFD(offset, exifIFDTags, data); err != nil {
+			return err
+		}
+	}
+	if offset, ok := pointers[tagGPSIFD]; ok {
+		if _, err := t.readIFD(offset, gpsIFDTags, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readIFD 读取一个 IFD 中的已知标签，返回其中指向子 IFD 的偏移量
+func (t *tiffReader) readIFD(offset uint32, tags map[uint16]string, data ExifData) (map[uint16]uint32, error) {
+	if t.visited[offset] {
+		return nil, nil
+	}
+	t.visited[offset] = true
+
+	if uint64(offset)+2 > uint64(len(t.buf)) {
+		return nil, fmt.Errorf("%w: IFD offset out of range", ErrUnsupportedFormat)
+	}
+	count := int(t.order.Uint16(t.buf[offset:]))
+	if count > maxIFDEntries {
+		return nil, fmt.Errorf("%w: too many IFD entries", ErrUnsupportedFormat)
+	}
+
+	pointers := make(map[uint16]uint32)
+	for i := 0; i < count; i++ {
+		start := uint64(offset) + 2 + uint64(i)*12
+		if start+12 > uint64(len(t.buf)) {
+			return pointers, fmt.Errorf("%w: truncated IFD", ErrUnsupportedFormat)
+		}
+		entry := t.buf[start : start+12]
+		tag := t.order.Uint16(entry[0:2])
+		typ := t.order.Uint16(entry[2:4])
+		n := uint64(t.order.Uint32(entry[4:8]))
+
+		if tag == tagExifIFD || tag == tagGPSIFD {
+			pointers[tag] = t.order.Uint32(entry[8:12])
+			continue
+		}
+		name, ok := tags[tag]
+		if !ok {
+			continue
+		}
+		raw, ok := t.entryValue(typ, n, entry[8:12])
+		if !ok {
+			continue
+		}
+		if value := t.convert(typ, n, raw); value != nil {
+			data[name] = value
+		}
+	}
+	return pointers, nil
+}
+
+// entryValue 值长度不超过 4 字节时直接存放在条目中，否则为偏移量
+func (t *tiffReader) entryValue(typ uint16, n uint64, field []byte) ([]byte, bool) {
+	size, ok := tiffTypeSize[typ]
+	if !ok || n == 0 || n > maxMetadataChunk {
+		return nil, false
+	}
+	total := size * n
+	if total <= 4 {
+		return field[:total], true
+	}
+	offset := uint64(t.order.Uint32(field))
+	if offset+total > uint64(len(t.buf)) {
+		return nil, false
+	}
+	return t.buf[offset : offset+total], true
+}
+
+// convert 转换为 exiftool -n 的输出形式：单个数值为数字，多个数值以空格分隔，字符串去掉结尾的 \0
+func (t *tiffReader) convert(typ uint16, n uint64, raw []byte) interface{} {
+	if typ == 2 {
+		text := strings.TrimSpace(strings.TrimRight(string(raw), "\x00"))
+		if text == "" {
+			return nil
+		}
+		return text
+	}
+
+	values := make([]float64, 0, n)
+	for i := uint64(0); i < n; i++ {
+		switch typ {
+		case 1, 7:
+			values = append(values, float64(raw[i]))
+		case 6:
+			values = append(values, float64(int8(raw[i])))
+		case 3:
+			values = append(values, float64(t.order.Uint16(raw[i*2:])))
+		case 8:
+			values = append(values, float64(int16(t.order.Uint16(raw[i*2:]))))
+		case 4:
+			values = append(values, float64(t.order.Uint32(raw[i*4:])))
+		case 9:
+			values = append(values, float64(int32(t.order.Uint32(raw[i*4:]))))
+		case 5:
+			num, den := t.order.Uint32(raw[i*8:]), t.order.Uint32(raw[i*8+4:])
+			if den == 0 {
+				return nil
+			}
+			values = append(values, float64(num)/float64(den))
+		case 10:
+			num, den := int32(t.order.Uint32(raw[i*8:])), int32(t.order.Uint32(raw[i*8+4:]))
+			if den == 0 {
+				return nil
+			}
+			values = append(values, float64(num)/float64(den))
+		case 11:
+			values = append(values, float64(math.Float32frombits(t.order.Uint32(raw[i*4:]))))
+		case 12:
+			values = append(values, math.Float64frombits(t.order.Uint64(raw[i*8:])))
+		}
+	}
+	// UNDEFINED 类型多为二进制数据，只保留单字节的值
+	if typ == 7 && n > 1 {
+		return nil
+	}
+
+	if len(values) == 1 {
+		if values[0] == math.Trunc(values[0]) && math.Abs(values[0]) < 1<<53 {
+			return int64(values[0])
+		}
+		return values[0]
+	}
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strings.Join(parts, " ")
+}
+
+// addCompositeTags 生成 exiftool 的组合标签：带符号的 GPS 坐标、Aperture、LensID、ImageSize 等
+func addCompositeTags(data ExifData) {
+	for _, axis := range []struct{ key, ref, negative string }{
+		{"GPSLatitude", "GPSLatitudeRef", "S"},
+		{"GPSLongitude", "GPSLongitudeRef", "W"},
+	} {
+		value, ok := data[axis.key]
+		if !ok {
+			continue
+		}
+		degrees, ok := dmsToDegrees(value)
+		if !ok {
+			delete(data, axis.key)
+			continue
+		}
+		if ref, _ := data[axis.ref].(string); strings.EqualFold(ref, axis.negative) {
+			degrees = -degrees
+		}
+		data[axis.key] = degrees
+	}
+	if ref, ok := data["GPSAltitudeRef"].(int64); ok && ref == 1 {
+		if altitude, ok := toFloat(data["GPSAltitude"]); ok {
+			data["GPSAltitude"] = -altitude
+		}
+	}
+
+	if fNumber, ok := data["FNumber"]; ok {
+		data["Aperture"] = fNumber
+	}
+	if lens, ok := data["LensModel"]; ok {
+		data["LensID"] = lens
+	}
+
+	width, okW := toFloat(data["ImageWidth"])
+	height, okH := toFloat(data["ImageHeight"])
+	if okW && okH && width > 0 && height > 0 {
+		data["ImageSize"] = fmt.Sprintf("%d %d", int(width), int(height))
+		data["Megapixels"] = width * height / 1e6
+	}
+}
+
+// dmsToDegrees 度分秒【"30 15 10.5"】转换为度
+func dmsToDegrees(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case string:
+		fields := strings.Fields(v)
+		if len(fields) == 0 || len(fields) > 3 {
+			return 0, false
+		}
+		degrees := 0.0
+		for i, field := range fields {
+			f, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return 0, false
+			}
+			degrees += f / math.Pow(60, float64(i))
+		}
+		return degrees, true
+	default:
+		return toFloat(v)
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,248 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"rear/internal/model"
+	"testing"
+)
+
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func asciiEntry(tag uint16, s string) tiffEntry {
+	return tiffEntry{tag, 2, uint32(len(s) + 1), append([]byte(s), 0)}
+}
+
+func shortEntry(tag uint16, v uint16) tiffEntry {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, v)
+	return tiffEntry{tag, 3, 1, b}
+}
+
+func rationalEntry(tag uint16, values ...uint32) tiffEntry {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(b[i*4:], v)
+	}
+	return tiffEntry{tag, 5, uint32(len(values) / 2), b}
+}
+
+// buildTIFF 生成小端序的 TIFF 数据：IFD0 + Exif IFD + GPS IFD
+func buildTIFF(ifd0, exifIFD, gpsIFD []tiffEntry) []byte {
+	ifdSize := func(entries []tiffEntry) int { return 2 + 12*len(entries) + 4 }
+	ifd0Count := len(ifd0) + 2
+	exifOffset := 8 + 2 + 12*ifd0Count + 4
+	gpsOffset := exifOffset + ifdSize(exifIFD)
+	dataOffset := gpsOffset + ifdSize(gpsIFD)
+
+	var head, extra bytes.Buffer
+	head.WriteString("II")
+	_ = binary.Write(&head, binary.LittleEndian, uint16(42))
+	_ = binary.Write(&head, binary.LittleEndian, uint32(8))
+
+	writeIFD := func(entries []tiffEntry) {
+		_ = binary.Write(&head, binary.LittleEndian, uint16(len(entries)))
+		for _, e := range entries {
+			_ = binary.Write(&head, binary.LittleEndian, e.tag)
+			_ = binary.Write(&head, binary.LittleEndian, e.typ)
+			_ = binary.Write(&head, binary.LittleEndian, e.count)
+			if len(e.value) <= 4 {
+				field := make([]byte, 4)
+				copy(field, e.value)
+				head.Write(field)
+				continue
+			}
+			_ = binary.Write(&head, binary.LittleEndian, uint32(dataOffset+extra.Len()))
+			extra.Write(e.value)
+		}
+		_ = binary.Write(&head, binary.LittleEndian, uint32(0))
+	}
+
+	pointer := func(tag uint16, offset int) tiffEntry {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, uint32(offset))
+		return tiffEntry{tag, 4, 1, b}
+	}
+	writeIFD(append(ifd0, pointer(tagExifIFD, exifOffset), pointer(tagGPSIFD, gpsOffset)))
+	writeIFD(exifIFD)
+	writeIFD(gpsIFD)
+	return append(head.Bytes(), extra.Bytes()...)
+}
+
+func sampleTIFF() []byte {
+	return buildTIFF(
+		[]tiffEntry{
+			asciiEntry(0x010F, "Canon"),
+			asciiEntry(0x0110, "Canon EOS R5"),
+			shortEntry(0x0112, 6),
+		},
+		[]tiffEntry{
+			rationalEntry(0x829A, 1, 250),
+			rationalEntry(0x829D, 28, 10),
+			shortEntry(0x8827, 400),
+			asciiEntry(0x9003, "2024:05:01 10:20:30"),
+			rationalEntry(0x920A, 50, 1),
+			asciiEntry(0xA434, "RF24-70mm F2.8 L IS USM"),
+		},
+		[]tiffEntry{
+			asciiEntry(0x0001, "S"),
+			rationalEntry(0x0002, 33, 1, 52, 1, 1830, 100),
+			asciiEntry(0x0003, "E"),
+			rationalEntry(0x0004, 151, 1, 12, 1, 3600, 100),
+		},
+	)
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func checkSampleExif(t *testing.T, data ExifData, width, height int) {
+	t.Helper()
+	parsed := model.SplitExifData(data)
+	if parsed.BaseInfo.ImageWidth != width || parsed.BaseInfo.ImageHeight != height {
+		t.Errorf("size = %dx%d, want %dx%d", parsed.BaseInfo.ImageWidth, parsed.BaseInfo.ImageHeight, width, height)
+	}
+	exif := parsed.Exif
+	if exif.Make != "Canon" || exif.Model != "Canon EOS R5" {
+		t.Errorf("make/model = %q/%q", exif.Make, exif.Model)
+	}
+	if exif.Orientation != 6 {
+		t.Errorf("orientation = %d, want 6", exif.Orientation)
+	}
+	if exif.ISO != 400 || exif.ExposureTime != 0.004 || exif.FNumber != 2.8 || exif.Aperture != 2.8 || exif.FocalLength != 50 {
+		t.Errorf("exposure = %+v", exif)
+	}
+	if exif.DateTimeOrig != "2024:05:01 10:20:30" {
+		t.Errorf("DateTimeOriginal = %q", exif.DateTimeOrig)
+	}
+	if exif.LensID != "RF24-70mm F2.8 L IS USM" {
+		t.Errorf("LensID = %q", exif.LensID)
+	}
+	if math.Abs(exif.GPSLatitude+33.8717) > 1e-4 || math.Abs(exif.GPSLongitude-151.21) > 1e-4 {
+		t.Errorf("gps = %v, %v", exif.GPSLatitude, exif.GPSLongitude)
+	}
+}
+
+func TestNativeReaderJPEG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 30)), nil); err != nil {
+		t.Fatal(err)
+	}
+	exif := append([]byte("Exif\x00\x00"), sampleTIFF()...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(exif)+2))
+	file := append([]byte{0xFF, 0xD8}, append(append(app1, exif...), buf.Bytes()[2:]...)...)
+
+	data, err := NativeReader{}.Read(context.Background(), writeFile(t, "a.jpg", file))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	checkSampleExif(t, data, 40, 30)
+	if data["MIMEType"] != "image/jpeg" || data["ImageSize"] != "40 30" {
+		t.Errorf("MIMEType = %v, ImageSize = %v", data["MIMEType"], data["ImageSize"])
+	}
+}
+
+func pngChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func TestNativeReaderPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 16, 9))); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	// 签名 8 字节 + IHDR 25 字节之后插入
+	ihdrEnd := 8 + 25
+	var file []byte
+	file = append(file, encoded[:ihdrEnd]...)
+	file = append(file, pngChunk("tEXt", []byte("Description\x00caf\xe9"))...)
+	file = append(file, pngChunk("eXIf", sampleTIFF())...)
+	file = append(file, encoded[ihdrEnd:]...)
+
+	data, err := NativeReader{}.Read(context.Background(), writeFile(t, "a.png", file))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	checkSampleExif(t, data, 16, 9)
+	if got := model.SplitExifData(data).Exif.Description; got != "café" {
+		t.Errorf("Description = %q", got)
+	}
+}
+
+func TestNativeReaderWebP(t *testing.T) {
+	riffChunk := func(fourCC string, data []byte) []byte {
+		chunk := append([]byte(fourCC), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+		chunk = append(chunk, data...)
+		if len(data)%2 == 1 {
+			chunk = append(chunk, 0)
+		}
+		return chunk
+	}
+	// VP8X：EXIF 标志，画布 1920x1080
+	vp8x := []byte{0x08, 0, 0, 0}
+	vp8x = append(vp8x, 0x7F, 0x07, 0x00, 0x37, 0x04, 0x00)
+	// VP8L：签名 + 14 位宽高【减 1】
+	vp8l := []byte{0x2f, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(vp8l[1:], uint32(99)|uint32(49)<<14)
+
+	body := []byte("WEBP")
+	body = append(body, riffChunk("VP8X", vp8x)...)
+	body = append(body, riffChunk("VP8L", vp8l)...)
+	body = append(body, riffChunk("EXIF", sampleTIFF())...)
+	file := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	file = append(file, body...)
+
+	data, err := NativeReader{}.Read(context.Background(), writeFile(t, "a.webp", file))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	checkSampleExif(t, data, 1920, 1080)
+}
+
+func TestNativeReaderUnsupported(t *testing.T) {
+	results, err := NativeReader{}.ReadBatch(context.Background(), []string{
+		writeFile(t, "a.txt", []byte("not an image at all")),
+		filepath.Join(t.TempDir(), "missing.jpg"),
+	})
+	if err != nil {
+		t.Fatalf("read batch: %v", err)
+	}
+	if results[0].Err == nil || results[1].Err == nil {
+		t.Fatalf("results = %+v, want errors", results)
+	}
+}
+
+func TestParseTIFFLoop(t *testing.T) {
+	// IFD0 的 Exif 指针指向自身，不能无限循环
+	tiff := []byte("II*\x00\x08\x00\x00\x00" + "\x01\x00" + "\x69\x87\x04\x00\x01\x00\x00\x00\x08\x00\x00\x00" + "\x00\x00\x00\x00")
+	if err := parseTIFF(tiff, ExifData{}); err != nil {
+		t.Fatalf("parseTIFF: %v", err)
+	}
+	if err := parseTIFF([]byte("II*\x00\xff\xff\x00\x00"), ExifData{}); err == nil {
+		t.Fatal("offset out of range: want error")
+	}
+}
@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"rear/internal/utils"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNoRawPreview RAW 文件中没有可用的内嵌预览
+var ErrNoRawPreview = errors.New("no embedded preview found")
+
+// rawPreviewTags 可能包含内嵌 JPEG 预览的标签
+var rawPreviewTags = []string{"JpgFromRaw", "PreviewImage", "OtherImage", "ThumbnailImage"}
+
+// exiftool -j 输出二进制标签时的格式，如 "(Binary data 1234567 bytes, use -b option to extract)"
+var binarySizePattern = regexp.MustCompile(`^\(Binary data (\d+) bytes`)
+
+// RawPreview 提取的内嵌预览
+type RawPreview struct {
+	// 预览所在的标签
+	Tag    string
+	Width  int
+	Height int
+}
+
+// largestPreviewTag 从 exiftool -j 输出中选择数据最大的预览标签
+func largestPreviewTag(output []byte) (string, error) {
+	var data []map[string]interface{}
+	if err := json.Unmarshal(output, &data); err != nil {
+		return "", fmt.Errorf("failed to parse preview tags: %w", err)
+	}
+	if len(data) == 0 {
+		return "", ErrNoRawPreview
+	}
+
+	tag, largest := "", int64(0)
+	for _, name := range rawPreviewTags {
+		value, ok := data[0][name].(string)
+		if !ok {
+			continue
+		}
+		match := binarySizePattern.FindStringSubmatch(value)
+		if match == nil {
+			continue
+		}
+		size, err := strconv.ParseInt(match[1], 10, 64)
+		if err == nil && size > largest {
+			tag, largest = name, size
+		}
+	}
+	if tag == "" {
+		return "", ErrNoRawPreview
+	}
+	return tag, nil
+}
+
+// ExtractRawPreview 提取 RAW 文件中最大的内嵌 JPEG 预览并写入 output
+func ExtractRawPreview(ctx context.Context, runner ToolRunner, input, output string) (*RawPreview, error) {
+	args := []string{"-j"}
+	for _, tag := range rawPreviewTags {
+		args = append(args, "-"+tag)
+	}
+	result, err := runner.Run(ctx, utils.ToolExifTool, append(args, input)...)
+	if err != nil {
+		return nil, fmt.Errorf("exiftool failed: %w", err)
+	}
+	tag, err := largestPreviewTag(result.Stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err = runner.Run(ctx, utils.ToolExifTool, "-b", "-"+tag, input)
+	if err != nil {
+		return nil, fmt.Errorf("extract %s failed: %w", tag, err)
+	}
+	data := result.Stdout
+	if !bytes.HasPrefix(data, []byte{0xFF, 0xD8}) {
+		return nil, fmt.Errorf("%w: %s is not a JPEG", ErrNoRawPreview, tag)
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrNoRawPreview, tag, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return nil, err
+	}
+	return &RawPreview{Tag: tag, Width: config.Width, Height: config.Height}, nil
+}
+
+// ConvertRaw 使用配置的 RAW 解码命令【dcraw 或 rawtherapee-cli】完整解码 RAW 文件
+// dcraw 输出 TIFF，rawtherapee-cli 输出 JPEG；返回实际的输出文件路径【扩展名按解码命令确定】
+func ConvertRaw(ctx context.Context, runner ToolRunner, input, output string) (string, error) {
+	converter, err := runner.Path(utils.ToolRawConverter)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	base := strings.TrimSuffix(output, filepath.Ext(output))
+
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(converter), filepath.Ext(converter)))
+	switch {
+	case strings.Contains(name, "dcraw"):
+		// -c 输出到 stdout，-w 相机白平衡，-h 半尺寸，-T 输出 TIFF
+		output = base + ".tiff"
+		result, err := runner.Run(ctx, utils.ToolRawConverter, "-c", "-w", "-h", "-T", input)
+		if err != nil {
+			return "", fmt.Errorf("dcraw failed: %w", err)
+		}
+		if err := os.WriteFile(output, result.Stdout, 0644); err != nil {
+			return "", err
+		}
+	case strings.Contains(name, "rawtherapee"):
+		// -Y 覆盖已存在的输出，-j90 输出质量 90 的 JPEG，-c 之后为输入文件
+		output = base + ".jpg"
+		if _, err := runner.Run(ctx, utils.ToolRawConverter, "-o", output, "-j90", "-Y", "-c", input); err != nil {
+			return "", fmt.Errorf("rawtherapee-cli failed: %w", err)
+		}
+	default:
+		return "", fmt.Errorf("%w: unknown raw converter %s", ErrUnsupportedFormat, converter)
+	}
+	return output, nil
+}
@@ -0,0 +1,23 @@
+package tools
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLargestPreviewTag(t *testing.T) {
+	output := []byte(`[{
+		"SourceFile": "a.nef",
+		"JpgFromRaw": "(Binary data 2409183 bytes, use -b option to extract)",
+		"PreviewImage": "(Binary data 112345 bytes, use -b option to extract)",
+		"ThumbnailImage": "(Binary data 9876 bytes, use -b option to extract)"
+	}]`)
+	tag, err := largestPreviewTag(output)
+	if err != nil || tag != "JpgFromRaw" {
+		t.Fatalf("tag = %q, %v, want JpgFromRaw", tag, err)
+	}
+
+	if _, err := largestPreviewTag([]byte(`[{"SourceFile": "a.raw"}]`)); !errors.Is(err, ErrNoRawPreview) {
+		t.Fatalf("no preview: got %v, want ErrNoRawPreview", err)
+	}
+}
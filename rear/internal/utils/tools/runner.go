@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"rear/internal/utils"
+	"rear/pkg/logger"
+	"runtime"
+	"sync"
+)
+
+// ToolRunner 外部工具的查找和执行
+// name 为 utils.ToolExifTool 等工具名称，工具未配置或未找到时返回 utils.ErrToolNotFound
+type ToolRunner interface {
+	// Path 工具路径
+	Path(name string) (string, error)
+	// Run 执行工具
+	Run(ctx context.Context, name string, args ...string) (*utils.CommandResult, error)
+}
+
+// ToolPaths 外部工具路径，为空时自动查找【RAW 解码命令不自动查找】
+type ToolPaths struct {
+	ImageMagick  string
+	ExifTool     string
+	Vips         string
+	RawConverter string
+}
+
+// ExecRunner 执行本机的外部工具，exiftool 通过常驻进程池执行
+type ExecRunner struct {
+	mu    sync.RWMutex
+	paths map[string]string
+	// exiftool 进程池，第一次使用时创建
+	exifPool *ExifToolPool
+	closed   bool
+}
+
+// NewExecRunner 按配置的路径创建执行器，未配置的工具在程序目录和 PATH 中查找
+func NewExecRunner(paths ToolPaths) *ExecRunner {
+	r := &ExecRunner{paths: make(map[string]string)}
+
+	execDir := ""
+	if execPath, err := os.Executable(); err == nil {
+		execDir = filepath.Dir(execPath)
+	}
+	find := func(configured string, names ...string) string {
+		if configured != "" {
+			return configured
+		}
+		for _, name := range names {
+			if path := utils.FindTool(name, execDir); path != "" {
+				return path
+			}
+		}
+		return ""
+	}
+
+	r.paths[utils.ToolImageMagick] = find(paths.ImageMagick, "convert", "magick")
+	r.paths[utils.ToolExifTool] = find(paths.ExifTool, "exiftool")
+	r.paths[utils.ToolVips] = find(paths.Vips, "vips")
+	r.paths[utils.ToolRawConverter] = paths.RawConverter
+	for _, name := range []string{utils.ToolImageMagick, utils.ToolExifTool, utils.ToolVips} {
+		if r.paths[name] == "" {
+			// 依赖它的功能在使用时返回 ErrToolNotFound，其余功能不受影响
+			logger.Warn("外部工具未找到，相关功能不可用", zap.String("tool", name))
+		}
+	}
+	return r
+}
+
+// SetPath 修改工具路径，修改 exiftool 路径时结束旧的进程池
+// 通过 Toolkit.SetPath 修改，图像处理后端和元数据读取器才会重新检测
+func (r *ExecRunner) SetPath(name, path string) {
+	r.mu.Lock()
+	var old *ExifToolPool
+	if name == utils.ToolExifTool && r.paths[name] != path {
+		old, r.exifPool = r.exifPool, nil
+	}
+	r.paths[name] = path
+	r.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+}
+
+func (r *ExecRunner) Path(name string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if path := r.paths[name]; path != "" {
+		return path, nil
+	}
+	return "", fmt.Errorf("%s %w", name, utils.ErrToolNotFound)
+}
+
+func (r *ExecRunner) Run(ctx context.Context, name string, args ...string) (*utils.CommandResult, error) {
+	if name == utils.ToolExifTool {
+		pool, err := r.exifTool()
+		if err != nil {
+			return nil, err
+		}
+		return pool.Execute(ctx, args...)
+	}
+	path, err := r.Path(name)
+	if err != nil {
+		return nil, err
+	}
+	return utils.ExecuteCommand(ctx, path, args...)
+}
+
+// exifTool exiftool 进程池，第一次使用时创建
+func (r *ExecRunner) exifTool() (*ExifToolPool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, ErrExifToolPoolClosed
+	}
+	if r.exifPool == nil {
+		path := r.paths[utils.ToolExifTool]
+		if path == "" {
+			return nil, fmt.Errorf("%s %w", utils.ToolExifTool, utils.ErrToolNotFound)
+		}
+		r.exifPool = NewExifToolPool(path, min(max(runtime.NumCPU(), 2), 8), defaultExifToolTimeout)
+	}
+	return r.exifPool, nil
+}
+
+// Close 结束常驻的 exiftool 进程
+func (r *ExecRunner) Close() {
+	r.mu.Lock()
+	pool := r.exifPool
+	r.exifPool, r.closed = nil, true
+	r.mu.Unlock()
+	if pool != nil {
+		pool.Close()
+	}
+}
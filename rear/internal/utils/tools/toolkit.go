@@ -0,0 +1,40 @@
+package tools
+
+import "fmt"
+
+// Toolkit 照片处理流程使用的外部工具：执行器、图像处理后端和元数据读取器
+type Toolkit struct {
+	Runner     ToolRunner
+	Processors *ImageProcessors
+	Metadata   MetadataReader
+}
+
+// NewToolkit 使用 runner 创建默认的图像处理后端和元数据读取器
+func NewToolkit(runner ToolRunner) *Toolkit {
+	return &Toolkit{
+		Runner:     runner,
+		Processors: NewImageProcessors(runner),
+		Metadata:   newSwitchableReader(runner),
+	}
+}
+
+// SetPath 运行时修改工具路径，并重新检测图像处理后端和元数据读取器
+func (k *Toolkit) SetPath(name, path string) error {
+	setter, ok := k.Runner.(interface{ SetPath(name, path string) })
+	if !ok {
+		return fmt.Errorf("runner %T does not support changing tool paths", k.Runner)
+	}
+	setter.SetPath(name, path)
+	k.Reload()
+	return nil
+}
+
+// Reload 重新检测图像处理后端并重新选择元数据读取器
+// 工具缓存的键包含后端和读取器的名称、版本，每次使用时读取，重新检测后旧的缓存不再命中
+func (k *Toolkit) Reload() {
+	k.Processors.Reset()
+	if reader, ok := k.Metadata.(*switchableReader); ok {
+		reader.reload()
+	}
+	k.Processors.Detect()
+}
@@ -0,0 +1,104 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+)
+
+// eventKind 合并后的事件类型
+type eventKind int
+
+const (
+	eventChanged eventKind = iota
+	eventRemoved
+)
+
+// debouncer 合并同一路径的连续事件
+// 复制大文件时会产生大量写事件，等待文件静止 delay 时间后才通知，以最后一次事件为准
+type debouncer struct {
+	delay     time.Duration
+	libraryID uint
+	sink      EventSink
+
+	mu      sync.Mutex
+	pending map[string]*pendingEvent
+	stopped bool
+}
+
+type pendingEvent struct {
+	kind  eventKind
+	timer *time.Timer
+}
+
+func newDebouncer(delay time.Duration, libraryID uint, sink EventSink) *debouncer {
+	return &debouncer{
+		delay:     delay,
+		libraryID: libraryID,
+		sink:      sink,
+		pending:   make(map[string]*pendingEvent),
+	}
+}
+
+// changed 文件被创建或修改
+func (d *debouncer) changed(path string) {
+	d.push(path, eventChanged)
+}
+
+// removed 文件或目录被删除、移走
+func (d *debouncer) removed(path string) {
+	d.push(path, eventRemoved)
+}
+
+func (d *debouncer) push(path string, kind eventKind) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return
+	}
+
+	if event, ok := d.pending[path]; ok {
+		event.kind = kind
+		event.timer.Reset(d.delay)
+		return
+	}
+
+	event := &pendingEvent{kind: kind}
+	event.timer = time.AfterFunc(d.delay, func() {
+		d.fire(path)
+	})
+	d.pending[path] = event
+}
+
+func (d *debouncer) fire(path string) {
+	d.mu.Lock()
+	event, ok := d.pending[path]
+	if ok {
+		delete(d.pending, path)
+	}
+	stopped := d.stopped
+	d.mu.Unlock()
+
+	if !ok || stopped {
+		return
+	}
+
+	switch event.kind {
+	case eventChanged:
+		d.sink.FileChanged(d.libraryID, path)
+	case eventRemoved:
+		d.sink.FileRemoved(d.libraryID, path)
+	}
+}
+
+// stop 丢弃所有未触发的事件
+func (d *debouncer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stopped = true
+	for path, event := range d.pending {
+		event.timer.Stop()
+		delete(d.pending, path)
+	}
+}
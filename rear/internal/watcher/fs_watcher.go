@@ -0,0 +1,154 @@
+package watcher
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"rear/pkg/logger"
+	"sync"
+)
+
+// fsWatcher 基于系统文件事件的监听【Linux 下为 inotify】
+// inotify 不支持递归，需要为每个子目录单独添加监听
+type fsWatcher struct {
+	root        string
+	isSupported func(path string) bool
+	events      *debouncer
+	watcher     *fsnotify.Watcher
+
+	mu   sync.Mutex
+	dirs map[string]bool
+	done chan struct{}
+}
+
+func newFsWatcher(root string, isSupported func(path string) bool, events *debouncer) (*fsWatcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	fw := &fsWatcher{
+		root:        root,
+		isSupported: isSupported,
+		events:      events,
+		watcher:     w,
+		dirs:        make(map[string]bool),
+		done:        make(chan struct{}),
+	}
+	if err := fw.addTree(root, false); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	go fw.loop()
+	return fw, nil
+}
+
+func (fw *fsWatcher) Mode() string {
+	return "fsnotify"
+}
+
+func (fw *fsWatcher) Close() {
+	fw.watcher.Close()
+	<-fw.done
+	fw.events.stop()
+}
+
+// addTree 监听目录及其所有子目录
+// notify 为 true 时同时通知目录中已有的文件【新建或移入的目录在添加监听前可能已经写入文件】
+func (fw *fsWatcher) addTree(root string, notify bool) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 根目录不可读直接失败，子目录不可读则跳过
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if err := fw.watcher.Add(path); err != nil {
+				return err
+			}
+			fw.mu.Lock()
+			fw.dirs[path] = true
+			fw.mu.Unlock()
+			return nil
+		}
+		if notify && fw.isSupported(path) {
+			fw.events.changed(path)
+		}
+		return nil
+	})
+}
+
+// forgetTree 目录被删除或移走后清理记录
+func (fw *fsWatcher) forgetTree(root string) bool {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if !fw.dirs[root] {
+		return false
+	}
+	prefix := root + string(filepath.Separator)
+	for dir := range fw.dirs {
+		if dir == root || len(dir) > len(prefix) && dir[:len(prefix)] == prefix {
+			delete(fw.dirs, dir)
+		}
+	}
+	return true
+}
+
+func (fw *fsWatcher) loop() {
+	defer close(fw.done)
+
+	for {
+		select {
+		case event, ok := <-fw.watcher.Events:
+			if !ok {
+				return
+			}
+			fw.handle(event)
+		case err, ok := <-fw.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warn("文件监听错误", zap.String("root", fw.root), zap.Error(err))
+		}
+	}
+}
+
+func (fw *fsWatcher) handle(event fsnotify.Event) {
+	path := event.Name
+
+	switch {
+	case event.Has(fsnotify.Create):
+		info, err := os.Stat(path)
+		if err != nil {
+			return
+		}
+		if info.IsDir() {
+			if err := fw.addTree(path, true); err != nil {
+				logger.Warn("子目录监听失败", zap.String("path", path), zap.Error(err))
+			}
+			return
+		}
+		if fw.isSupported(path) {
+			fw.events.changed(path)
+		}
+
+	case event.Has(fsnotify.Write):
+		if fw.isSupported(path) {
+			fw.events.changed(path)
+		}
+
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		// 重命名会在旧路径上产生 Rename 事件，新路径上产生 Create 事件
+		// 旧路径按删除处理，新路径的任务会通过内容 Hash 识别为移动
+		isDir := fw.forgetTree(path)
+		if isDir || fw.isSupported(path) {
+			fw.events.removed(path)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+//go:build linux
+
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 收不到 inotify 事件的文件系统类型
+var networkFsTypes = map[string]bool{
+	"nfs":         true,
+	"nfs4":        true,
+	"cifs":        true,
+	"smbfs":       true,
+	"smb3":        true,
+	"9p":          true,
+	"afs":         true,
+	"davfs":       true,
+	"fuse.sshfs":  true,
+	"fuse.rclone": true,
+}
+
+// isNetworkMount 判断路径是否位于网络挂载目录下
+func isNetworkMount(path string) bool {
+	content, err := os.ReadFile("/proc/mounts")
+	if err != nil {
+		return false
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+
+	// 取最长匹配的挂载点
+	var fsType string
+	longest := -1
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		mountPoint := fields[1]
+		if abs != mountPoint && !strings.HasPrefix(abs, strings.TrimSuffix(mountPoint, "/")+"/") {
+			continue
+		}
+		if len(mountPoint) > longest {
+			longest = len(mountPoint)
+			fsType = fields[2]
+		}
+	}
+	return networkFsTypes[fsType]
+}
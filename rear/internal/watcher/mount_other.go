@@ -0,0 +1,10 @@
+//go:build !linux
+
+package watcher
+
+import "strings"
+
+// isNetworkMount 非 Linux 平台只识别 UNC 路径
+func isNetworkMount(path string) bool {
+	return strings.HasPrefix(path, `\\`) || strings.HasPrefix(path, "//")
+}
@@ -0,0 +1,112 @@
+package watcher
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// fileState 轮询比对用的文件状态
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// pollWatcher 定时扫描目录比对文件状态
+// 用于 NFS/SMB 等收不到文件事件的网络挂载目录
+type pollWatcher struct {
+	root        string
+	interval    time.Duration
+	isSupported func(path string) bool
+	events      *debouncer
+
+	snapshot map[string]fileState
+	stopCh   chan struct{}
+	done     chan struct{}
+}
+
+func newPollWatcher(root string, interval time.Duration, isSupported func(path string) bool, events *debouncer) *pollWatcher {
+	pw := &pollWatcher{
+		root:        root,
+		interval:    interval,
+		isSupported: isSupported,
+		events:      events,
+		stopCh:      make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	// 首次扫描只建立快照，已有文件由手动索引处理
+	pw.snapshot = pw.scan()
+	go pw.loop()
+	return pw
+}
+
+func (pw *pollWatcher) Mode() string {
+	return "polling"
+}
+
+func (pw *pollWatcher) Close() {
+	close(pw.stopCh)
+	<-pw.done
+	pw.events.stop()
+}
+
+func (pw *pollWatcher) loop() {
+	defer close(pw.done)
+
+	ticker := time.NewTicker(pw.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pw.stopCh:
+			return
+		case <-ticker.C:
+			pw.compare(pw.scan())
+		}
+	}
+}
+
+// scan 获取目录下所有支持文件的状态，扫描失败时返回 nil
+func (pw *pollWatcher) scan() map[string]fileState {
+	states := make(map[string]fileState)
+	err := filepath.WalkDir(pw.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == pw.root {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() || !pw.isSupported(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		states[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil
+	}
+	return states
+}
+
+func (pw *pollWatcher) compare(current map[string]fileState) {
+	// 挂载断开时不要把所有文件当成删除
+	if current == nil {
+		return
+	}
+
+	for path, state := range current {
+		old, ok := pw.snapshot[path]
+		if !ok || old.size != state.size || !old.modTime.Equal(state.modTime) {
+			pw.events.changed(path)
+		}
+	}
+	for path := range pw.snapshot {
+		if _, ok := current[path]; !ok {
+			pw.events.removed(path)
+		}
+	}
+	pw.snapshot = current
+}
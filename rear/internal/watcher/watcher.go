@@ -0,0 +1,190 @@
+package watcher
+
+import (
+	"go.uber.org/zap"
+	"path/filepath"
+	"rear/internal/config"
+	"rear/internal/model"
+	"rear/pkg/logger"
+	"strings"
+	"sync"
+)
+
+// EventSink 文件变化的接收方
+type EventSink interface {
+	// FileChanged 文件被创建或修改
+	FileChanged(libraryID uint, path string)
+	// FileRemoved 文件或目录被删除、移走
+	FileRemoved(libraryID uint, path string)
+}
+
+// libraryWatcher 单个存储库的监听实现
+type libraryWatcher interface {
+	// Mode 监听方式
+	Mode() string
+	Close()
+}
+
+// 监听正在启动【遍历目录或首次扫描尚未完成】
+const modeStarting = "starting"
+
+// watchEntry 正在监听的存储库
+type watchEntry struct {
+	path string
+	// 启动完成前为 nil
+	watcher libraryWatcher
+	// 启动完成前已被停止，启动完成后直接关闭
+	stopped bool
+}
+
+// Manager 管理所有已开启存储库的文件监听
+type Manager struct {
+	mu       sync.Mutex
+	entries  map[uint]*watchEntry
+	sink     EventSink
+	config   config.WatcherConfig
+	fileExts map[string]bool
+	closed   bool
+	// 正在后台启动的监听
+	starting sync.WaitGroup
+}
+
+func NewManager(sink EventSink, cfg config.WatcherConfig, supportedTypes []string) *Manager {
+	exts := make(map[string]bool, len(supportedTypes))
+	for _, ext := range supportedTypes {
+		exts[strings.ToLower(ext)] = true
+	}
+	return &Manager{
+		entries:  make(map[uint]*watchEntry),
+		sink:     sink,
+		config:   cfg,
+		fileExts: exts,
+	}
+}
+
+// Sync 按存储库列表调整监听：开启的存储库开始监听，关闭或已删除的停止监听
+// 遍历目录和首次扫描在后台进行，大存储库也不会阻塞调用方【由创建、修改存储库的接口同步调用】
+func (m *Manager) Sync(libraries []model.LibraryTable) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed || !m.config.Enable {
+		return
+	}
+
+	wanted := make(map[uint]model.LibraryTable)
+	for _, library := range libraries {
+		if library.IsEnable {
+			wanted[library.ID] = library
+		}
+	}
+
+	// 停止不再需要的监听
+	for id, entry := range m.entries {
+		library, ok := wanted[id]
+		if !ok || library.ImgPath != entry.path {
+			m.stopLocked(id)
+		}
+	}
+
+	// 启动新的监听
+	for id, library := range wanted {
+		if _, ok := m.entries[id]; ok {
+			continue
+		}
+		m.startLocked(library)
+	}
+}
+
+// Watching 当前正在监听的存储库及监听方式
+func (m *Manager) Watching() map[uint]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[uint]string, len(m.entries))
+	for id, entry := range m.entries {
+		if entry.watcher == nil {
+			result[id] = modeStarting
+		} else {
+			result[id] = entry.watcher.Mode()
+		}
+	}
+	return result
+}
+
+// Close 停止所有监听，并等待后台启动中的监听结束
+func (m *Manager) Close() {
+	m.mu.Lock()
+	for id := range m.entries {
+		m.stopLocked(id)
+	}
+	m.closed = true
+	m.mu.Unlock()
+
+	m.starting.Wait()
+}
+
+// startLocked 登记监听并在后台启动
+func (m *Manager) startLocked(library model.LibraryTable) {
+	entry := &watchEntry{path: library.ImgPath}
+	m.entries[library.ID] = entry
+	m.starting.Add(1)
+	go func() {
+		defer m.starting.Done()
+		m.start(library, entry)
+	}()
+}
+
+// start 创建监听，期间监听已被停止时直接关闭
+func (m *Manager) start(library model.LibraryTable, entry *watchEntry) {
+	events := newDebouncer(m.config.Debounce, library.ID, m.sink)
+
+	var w libraryWatcher
+	var err error
+	if !m.config.ForcePolling && !isNetworkMount(library.ImgPath) {
+		w, err = newFsWatcher(library.ImgPath, m.isSupported, events)
+		if err != nil {
+			// inotify 监听数量达到上限等情况下退回轮询
+			logger.Warn("文件监听启动失败，改用轮询!",
+				zap.String("path", library.ImgPath),
+				zap.Error(err),
+			)
+		}
+	}
+	if w == nil {
+		w = newPollWatcher(library.ImgPath, m.config.PollInterval, m.isSupported, events)
+	}
+
+	m.mu.Lock()
+	stopped := entry.stopped
+	if !stopped {
+		entry.watcher = w
+	}
+	m.mu.Unlock()
+	if stopped {
+		w.Close()
+		return
+	}
+	logger.Info("开始监听存储库",
+		zap.String("path", library.ImgPath),
+		zap.String("mode", w.Mode()),
+	)
+}
+
+func (m *Manager) stopLocked(id uint) {
+	entry, ok := m.entries[id]
+	if !ok {
+		return
+	}
+	entry.stopped = true
+	if entry.watcher != nil {
+		entry.watcher.Close()
+	}
+	delete(m.entries, id)
+	logger.Info("停止监听存储库", zap.String("path", entry.path))
+}
+
+// isSupported 是否为需要索引的文件类型
+func (m *Manager) isSupported(path string) bool {
+	return m.fileExts[strings.ToLower(filepath.Ext(path))]
+}
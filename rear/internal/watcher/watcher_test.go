@@ -0,0 +1,134 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"rear/internal/config"
+	"rear/internal/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordSink 记录收到的事件
+type recordSink struct {
+	mu      sync.Mutex
+	changed []string
+	removed []string
+}
+
+func (s *recordSink) FileChanged(libraryID uint, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed = append(s.changed, path)
+}
+
+func (s *recordSink) FileRemoved(libraryID uint, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removed = append(s.removed, path)
+}
+
+func (s *recordSink) snapshot() ([]string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.changed...), append([]string(nil), s.removed...)
+}
+
+// waitFor 等待条件成立
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("condition not met before timeout")
+}
+
+func runWatcherTest(t *testing.T, cfg config.WatcherConfig) {
+	dir := t.TempDir()
+	sink := &recordSink{}
+	manager := NewManager(sink, cfg, []string{".jpg"})
+	manager.Sync([]model.LibraryTable{{BaseModel: model.BaseModel{ID: 1}, ImgPath: dir, IsEnable: true}})
+	defer manager.Close()
+
+	if mode := manager.Watching()[1]; mode == "" {
+		t.Fatal("library is not watched")
+	}
+	// 启动完成后才能收到事件
+	waitFor(t, func() bool { return manager.Watching()[1] != modeStarting })
+
+	photo := filepath.Join(dir, "a.jpg")
+	// 多次写入只应通知一次
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(photo, []byte{byte(i)}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "note.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool {
+		changed, _ := sink.snapshot()
+		return len(changed) > 0
+	})
+	time.Sleep(3 * cfg.Debounce)
+	if changed, _ := sink.snapshot(); len(changed) != 1 || changed[0] != photo {
+		t.Fatalf("unexpected changed events: %v", changed)
+	}
+
+	if err := os.Remove(photo); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		_, removed := sink.snapshot()
+		return len(removed) == 1 && removed[0] == photo
+	})
+
+	// 关闭存储库后停止监听
+	manager.Sync([]model.LibraryTable{{BaseModel: model.BaseModel{ID: 1}, ImgPath: dir, IsEnable: false}})
+	if len(manager.Watching()) != 0 {
+		t.Fatal("disabled library is still watched")
+	}
+}
+
+func TestFsWatcher(t *testing.T) {
+	runWatcherTest(t, config.WatcherConfig{
+		Enable:       true,
+		Debounce:     50 * time.Millisecond,
+		PollInterval: time.Hour,
+	})
+}
+
+func TestPollWatcher(t *testing.T) {
+	runWatcherTest(t, config.WatcherConfig{
+		Enable:       true,
+		Debounce:     50 * time.Millisecond,
+		PollInterval: 100 * time.Millisecond,
+		ForcePolling: true,
+	})
+}
+
+func TestStopWhileStarting(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewManager(&recordSink{}, config.WatcherConfig{
+		Enable:       true,
+		Debounce:     50 * time.Millisecond,
+		PollInterval: time.Hour,
+	}, []string{".jpg"})
+	defer manager.Close()
+
+	// 启动尚未完成就关闭存储库，后台创建的监听应被关闭而不是登记
+	library := model.LibraryTable{BaseModel: model.BaseModel{ID: 1}, ImgPath: dir, IsEnable: true}
+	manager.Sync([]model.LibraryTable{library})
+	library.IsEnable = false
+	manager.Sync([]model.LibraryTable{library})
+	manager.starting.Wait()
+	if watching := manager.Watching(); len(watching) != 0 {
+		t.Fatalf("disabled library is still watched: %v", watching)
+	}
+}
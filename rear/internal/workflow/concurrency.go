@@ -0,0 +1,172 @@
+package workflow
+
+import (
+	"rear/internal/config"
+	"rear/pkg/utils"
+	"sync"
+)
+
+// workerLimiter 可动态调整上限的并发计数
+// 调小上限时不影响已在执行的任务，只是在执行数降到上限以下之前不再放行新任务
+// 等待方除 ImgTaskManager.run 外还有暂停后恢复、重新等待名额的任务
+type workerLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	active int
+	// 执行数或上限变化时关闭并替换，通知所有等待方
+	changed chan struct{}
+}
+
+func newWorkerLimiter(limit int) *workerLimiter {
+	return &workerLimiter{
+		limit:   max(limit, 1),
+		changed: make(chan struct{}),
+	}
+}
+
+// acquire 等待空闲名额，stop 关闭时返回 false
+func (l *workerLimiter) acquire(stop <-chan struct{}) bool {
+	for {
+		l.mu.Lock()
+		if l.active < l.limit {
+			l.active++
+			l.mu.Unlock()
+			return true
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// release 归还名额
+func (l *workerLimiter) release() {
+	l.mu.Lock()
+	l.active--
+	l.notifyLocked()
+	l.mu.Unlock()
+}
+
+// setLimit 调整上限，最小为 1
+func (l *workerLimiter) setLimit(n int) {
+	l.mu.Lock()
+	l.limit = max(n, 1)
+	l.notifyLocked()
+	l.mu.Unlock()
+}
+
+// stats 当前上限和执行数
+func (l *workerLimiter) stats() (limit, active int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit, l.active
+}
+
+func (l *workerLimiter) notifyLocked() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// workerSlot 执行中的任务占用的名额，任务暂停期间归还，恢复后重新等待
+// 只由执行任务的协程使用，不需要加锁
+type workerSlot struct {
+	limiter *workerLimiter
+	held    bool
+}
+
+// acquire 等待名额，done 关闭时返回 false
+func (s *workerSlot) acquire(done <-chan struct{}) bool {
+	if s == nil || s.held {
+		return true
+	}
+	s.held = s.limiter.acquire(done)
+	return s.held
+}
+
+// release 归还名额，未持有时忽略
+func (s *workerSlot) release() {
+	if s == nil || !s.held {
+		return
+	}
+	s.held = false
+	s.limiter.release()
+}
+
+// loadSampler 采样系统 CPU 和内存使用率
+type loadSampler struct {
+	sys     *utils.SysUtils
+	prev    utils.CPUTimes
+	hasPrev bool
+}
+
+func newLoadSampler() *loadSampler {
+	return &loadSampler{sys: utils.NewSysUtils()}
+}
+
+// sample 返回自上次采样以来的 CPU 使用率和当前内存使用率，无法获取时为 -1
+func (s *loadSampler) sample() (cpu, mem float64) {
+	cpu, mem = -1, -1
+
+	times, err := s.sys.GetCPUTimes()
+	if err == nil {
+		if s.hasPrev {
+			cpu = times.UsageSince(s.prev)
+		}
+		s.prev = times
+		s.hasPrev = true
+	}
+
+	if info := s.sys.GetMemoryInfo(); info.Total > 0 {
+		mem = info.UsedPct
+	}
+	return cpu, mem
+}
+
+// concurrencyTuner 根据负载决定并发数
+// 高于高水位连续 Stable 次后减少 1/4，低于低水位连续 Stable 次后加 1，两者之间保持不变
+type concurrencyTuner struct {
+	cfg  config.ConcurrencyConfig
+	high int
+	low  int
+}
+
+// next 根据本次采样返回新的并发数，不需要调整时返回 current
+func (t *concurrencyTuner) next(current int, cpu, mem float64) int {
+	overloaded := cpu > t.cfg.CPUHigh || mem > t.cfg.MemHigh
+	// 无法采样的指标不阻止增加并发
+	idle := (cpu < 0 || cpu < t.cfg.CPULow) && (mem < 0 || mem < t.cfg.MemLow) && (cpu >= 0 || mem >= 0)
+
+	switch {
+	case overloaded:
+		t.high++
+		t.low = 0
+	case idle:
+		t.low++
+		t.high = 0
+	default:
+		t.high, t.low = 0, 0
+	}
+
+	stable := max(t.cfg.Stable, 1)
+	target := current
+	if t.high >= stable {
+		target = current - max(current/4, 1)
+		t.high = 0
+	} else if t.low >= stable {
+		target = current + 1
+		t.low = 0
+	}
+	return t.clamp(target)
+}
+
+// clamp 限制在配置的上下限内
+func (t *concurrencyTuner) clamp(n int) int {
+	minWorkers := max(t.cfg.Min, 1)
+	maxWorkers := max(t.cfg.Max, minWorkers)
+	return min(max(n, minWorkers), maxWorkers)
+}
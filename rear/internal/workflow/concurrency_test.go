@@ -0,0 +1,115 @@
+package workflow
+
+import (
+	"rear/internal/config"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyTuner(t *testing.T) {
+	tuner := &concurrencyTuner{cfg: config.ConcurrencyConfig{
+		Min: 2, Max: 8,
+		CPUHigh: 85, CPULow: 60,
+		MemHigh: 90, MemLow: 80,
+		Stable: 2,
+	}}
+
+	// 单次高负载不调整
+	if got := tuner.next(8, 95, 50); got != 8 {
+		t.Fatalf("after one overloaded sample got %d, want 8", got)
+	}
+	// 连续两次高负载减少 1/4
+	if got := tuner.next(8, 95, 50); got != 6 {
+		t.Fatalf("after two overloaded samples got %d, want 6", got)
+	}
+	// 中间区间清零计数
+	tuner.next(6, 95, 50)
+	if got := tuner.next(6, 70, 50); got != 6 {
+		t.Fatalf("between thresholds got %d, want 6", got)
+	}
+	if got := tuner.next(6, 95, 50); got != 6 {
+		t.Fatalf("hysteresis not reset, got %d", got)
+	}
+	// 不低于下限
+	tuner.next(2, 99, 99)
+	if got := tuner.next(2, 99, 99); got != 2 {
+		t.Fatalf("below min: got %d, want 2", got)
+	}
+	// 空闲时逐个增加，不超过上限
+	tuner.next(7, 10, 10)
+	if got := tuner.next(7, 10, 10); got != 8 {
+		t.Fatalf("after two idle samples got %d, want 8", got)
+	}
+	tuner.next(8, 10, 10)
+	if got := tuner.next(8, 10, 10); got != 8 {
+		t.Fatalf("above max: got %d, want 8", got)
+	}
+	// 内存紧张时即使 CPU 空闲也不增加
+	tuner.next(4, 10, 85)
+	if got := tuner.next(4, 10, 85); got != 4 {
+		t.Fatalf("memory between thresholds got %d, want 4", got)
+	}
+	// 都无法采样时保持不变
+	tuner.next(4, -1, -1)
+	if got := tuner.next(4, -1, -1); got != 4 {
+		t.Fatalf("without samples got %d, want 4", got)
+	}
+}
+
+func TestWorkerLimiterShrink(t *testing.T) {
+	limiter := newWorkerLimiter(2)
+	stop := make(chan struct{})
+
+	if !limiter.acquire(stop) || !limiter.acquire(stop) {
+		t.Fatal("acquire within limit failed")
+	}
+
+	// 缩小上限不影响已在执行的任务
+	limiter.setLimit(1)
+	if _, active := limiter.stats(); active != 2 {
+		t.Fatalf("active = %d, want 2", active)
+	}
+
+	acquired := make(chan bool, 1)
+	go func() {
+		acquired <- limiter.acquire(stop)
+	}()
+
+	// 执行数降到 1 时仍然达到上限，不放行
+	limiter.release()
+	select {
+	case <-acquired:
+		t.Fatal("acquired while at the shrunken limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	limiter.release()
+	select {
+	case ok := <-acquired:
+		if !ok {
+			t.Fatal("acquire returned false")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire not released after workers finished")
+	}
+
+	// 关闭时等待方退出
+	go func() {
+		acquired <- limiter.acquire(stop)
+	}()
+	close(stop)
+	select {
+	case ok := <-acquired:
+		if ok {
+			t.Fatal("acquire succeeded after stop")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire not interrupted by stop")
+	}
+
+	// 上限至少为 1
+	limiter.setLimit(0)
+	if limit, _ := limiter.stats(); limit != 1 {
+		t.Fatalf("limit = %d, want 1", limit)
+	}
+}
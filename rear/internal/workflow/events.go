@@ -0,0 +1,146 @@
+package workflow
+
+import (
+	"path/filepath"
+	toolutils "rear/internal/utils"
+	"sync"
+	"sync/atomic"
+)
+
+// 推送事件类型
+const (
+	// EventTask 单个文件任务状态变化，数据为 TaskInfo
+	EventTask = "task"
+	// EventCounters 全局任务计数，数据为 TaskCounters
+	EventCounters = "counters"
+	// EventJob 索引任务计数变化，数据为 JobProgress【不含文件错误】
+	EventJob = "job"
+	// EventToolError 外部工具执行失败，数据为 ToolError
+	EventToolError = "tool_error"
+)
+
+// 订阅方缓冲的事件数，消费跟不上时丢弃新事件
+const subscriberBuffer = 256
+
+// 推送的工具错误输出最大长度
+const maxToolStderr = 1024
+
+// Event 推送给客户端的事件
+type Event struct {
+	// 递增序号，客户端可据此判断是否丢失事件
+	ID   uint64      `json:"id"`
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// TaskCounters 全局任务计数
+type TaskCounters struct {
+	Done      int `json:"done"`
+	Remaining int `json:"remaining"`
+}
+
+// ToolError 外部工具执行失败的信息
+type ToolError struct {
+	Program  string `json:"program"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error"`
+	Stderr   string `json:"stderr,omitempty"`
+}
+
+// EventBus 进度事件的发布订阅
+// 发布不会阻塞任务执行，订阅方缓冲已满时该订阅方丢弃事件
+type EventBus struct {
+	mu     sync.RWMutex
+	subs   map[chan Event]struct{}
+	seq    atomic.Uint64
+	closed bool
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan Event]struct{})}
+}
+
+// Subscribe 订阅事件，结束后需调用 Unsubscribe
+// EventBus 关闭后返回的通道会被关闭
+func (b *EventBus) Subscribe() chan Event {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.subs[ch] = struct{}{}
+	return ch
+}
+
+// Unsubscribe 取消订阅
+func (b *EventBus) Unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// HasSubscribers 是否有订阅方，用于跳过需要额外查询的事件
+func (b *EventBus) HasSubscribers() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs) > 0
+}
+
+// Publish 发布事件
+func (b *EventBus) Publish(eventType string, data interface{}) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed || len(b.subs) == 0 {
+		return
+	}
+
+	event := Event{ID: b.seq.Add(1), Type: eventType, Data: data}
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Close 关闭所有订阅，用于服务关闭时结束长连接
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// ToolFailed 推送外部工具执行失败，作为 toolutils.OnCommandFailed 的回调
+func (b *EventBus) ToolFailed(program string, result *toolutils.CommandResult, err error) {
+	toolErr := ToolError{
+		Program: filepath.Base(program),
+		Error:   err.Error(),
+	}
+	if result != nil {
+		toolErr.ExitCode = result.ExitCode
+		stderr := result.Stderr
+		if len(stderr) > maxToolStderr {
+			stderr = stderr[:maxToolStderr]
+		}
+		toolErr.Stderr = string(stderr)
+	}
+	b.Publish(EventToolError, toolErr)
+}
@@ -0,0 +1,108 @@
+package workflow
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"rear/internal/utils/tools"
+	"rear/pkg/logger"
+	"sync"
+	"time"
+)
+
+// exifBatchTimeout 一组文件批量读取的时限
+const exifBatchTimeout = 2 * time.Minute
+
+// exifBatcher 按目录批量读取 EXIF
+// 索引时同一目录的文件登记为一组，组内第一个执行的任务一次读取整组，其余任务直接取结果；
+// 未登记的文件或整批读取失败时按单个文件读取
+type exifBatcher struct {
+	reader tools.MetadataReader
+
+	mu sync.Mutex
+	// 文件路径 -> 所在的组
+	groups map[string]*exifGroup
+}
+
+// exifGroup 一次 exiftool 调用读取的文件
+type exifGroup struct {
+	paths []string
+	once  sync.Once
+
+	mu      sync.Mutex
+	results map[string]tools.ExifResult
+}
+
+func newExifBatcher(reader tools.MetadataReader) *exifBatcher {
+	return &exifBatcher{reader: reader, groups: make(map[string]*exifGroup)}
+}
+
+// add 登记同一目录下的文件，每 tools.ExifBatchSize 个为一组
+func (b *exifBatcher) add(paths []string) {
+	if len(paths) < 2 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for start := 0; start < len(paths); start += tools.ExifBatchSize {
+		group := &exifGroup{paths: paths[start:min(start+tools.ExifBatchSize, len(paths))]}
+		for _, path := range group.paths {
+			b.groups[path] = group
+		}
+	}
+}
+
+// forget 取消登记【任务在读取 EXIF 前结束时调用】，已读取的结果一并释放
+func (b *exifBatcher) forget(path string) {
+	b.mu.Lock()
+	group := b.groups[path]
+	delete(b.groups, path)
+	b.mu.Unlock()
+	if group != nil {
+		group.take(path)
+	}
+}
+
+// get 获取文件的 EXIF 数据
+func (b *exifBatcher) get(ctx context.Context, path string) (tools.ExifData, error) {
+	b.mu.Lock()
+	group := b.groups[path]
+	delete(b.groups, path)
+	b.mu.Unlock()
+
+	if group != nil {
+		group.once.Do(func() {
+			// 整组的读取不随首个任务取消或暂停而中断，否则组内其他任务都要逐个重新读取
+			loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), exifBatchTimeout)
+			defer cancel()
+			group.load(loadCtx, b.reader)
+		})
+		if result, ok := group.take(path); ok && result.Err == nil {
+			return result.Data, nil
+		}
+	}
+	// 单个文件出错时重新单独读取，得到完整的错误信息
+	return b.reader.Read(ctx, path)
+}
+
+func (g *exifGroup) load(ctx context.Context, reader tools.MetadataReader) {
+	results, err := reader.ReadBatch(ctx, g.paths)
+	if err != nil {
+		logger.Warn("批量读取 EXIF 失败，改为逐个读取", zap.Int("count", len(g.paths)), zap.Error(err))
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.results = make(map[string]tools.ExifResult, len(results))
+	for _, result := range results {
+		g.results[result.Path] = result
+	}
+}
+
+// take 取出并释放单个文件的结果
+func (g *exifGroup) take(path string) (tools.ExifResult, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	result, ok := g.results[path]
+	delete(g.results, path)
+	return result, ok
+}
@@ -0,0 +1,54 @@
+package workflow
+
+import (
+	"context"
+	"rear/internal/utils/tools"
+	"sync/atomic"
+	"testing"
+)
+
+// batchReader 批量读取时检查 ctx，记录单个读取的次数
+type batchReader struct {
+	reads atomic.Int32
+}
+
+func (r *batchReader) Name() string    { return "fake" }
+func (r *batchReader) Version() string { return "1" }
+
+func (r *batchReader) Read(ctx context.Context, path string) (tools.ExifData, error) {
+	r.reads.Add(1)
+	return tools.ExifData{"SourceFile": path}, ctx.Err()
+}
+
+func (r *batchReader) ReadBatch(ctx context.Context, paths []string) ([]tools.ExifResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	results := make([]tools.ExifResult, 0, len(paths))
+	for _, path := range paths {
+		results = append(results, tools.ExifResult{Path: path, Data: tools.ExifData{"SourceFile": path}})
+	}
+	return results, nil
+}
+
+// 首个任务已取消时整组仍然读取，其他任务直接取结果
+func TestExifBatchIgnoresFirstCallerCancel(t *testing.T) {
+	reader := &batchReader{}
+	batcher := newExifBatcher(reader)
+	batcher.add([]string{"/a.jpg", "/b.jpg", "/c.jpg"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := batcher.get(ctx, "/a.jpg"); err != nil {
+		t.Fatalf("first caller: %v", err)
+	}
+	for _, path := range []string{"/b.jpg", "/c.jpg"} {
+		data, err := batcher.get(context.Background(), path)
+		if err != nil || data["SourceFile"] != path {
+			t.Fatalf("%s: data = %v, err = %v", path, data, err)
+		}
+	}
+	if n := reader.reads.Load(); n != 0 {
+		t.Fatalf("single reads = %d, want 0", n)
+	}
+}
@@ -1,23 +1,22 @@
 package workflow
 
 import (
-	"bytes"
 	"context"
-	"crypto/sha1"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/h2non/filetype"
 	"go.uber.org/zap"
 	"os"
-	"os/exec"
-	"rear/internal/consts"
+	"path/filepath"
+	"rear/internal/config"
 	"rear/internal/model"
+	"rear/internal/repositories"
 	"rear/internal/utils/tools"
 	"rear/pkg/logger"
 	"rear/pkg/utils"
-	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -30,104 +29,264 @@ const (
 	StatusPaused  TaskStatus = "paused"
 	StatusFailed  TaskStatus = "failed"
 	StatusDone    TaskStatus = "done"
+	// 文件未变化或仅被移动，无需重新处理
+	StatusSkipped  TaskStatus = "skipped"
+	StatusCanceled TaskStatus = "canceled"
 )
 
+var (
+	ErrTaskNotFound      = errors.New("task not found")
+	ErrInvalidTransition = errors.New("invalid state transition")
+)
+
+// taskTransitions 允许的状态转换，未列出的状态为终态
+// 暂停在步骤之间生效，暂停时正在执行的步骤仍会完成，因此暂停状态也可以直接结束
+var taskTransitions = map[TaskStatus][]TaskStatus{
+	StatusPending: {StatusRunning, StatusPaused, StatusCanceled},
+	StatusRunning: {StatusPaused, StatusDone, StatusFailed, StatusSkipped, StatusCanceled},
+	StatusPaused:  {StatusPending, StatusRunning, StatusDone, StatusFailed, StatusSkipped, StatusCanceled},
+}
+
+// canTransition 是否允许从 from 转换到 to
+func canTransition(from, to TaskStatus) bool {
+	for _, status := range taskTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal 是否为终态
+func (s TaskStatus) IsFinal() bool {
+	_, ok := taskTransitions[s]
+	return !ok
+}
+
+// TaskOptions 创建任务时的参数
+type TaskOptions struct {
+	// 所属索引任务，0 表示不属于任何索引任务【如文件监听触发】
+	JobID uint
+	// 所属存储库
+	LibraryID uint
+	// 强制重新处理，不比对文件大小和修改时间
+	Force bool
+}
+
+// TaskInfo 任务状态快照
+type TaskInfo struct {
+	ID       string     `json:"id"`
+	Path     string     `json:"path"`
+	JobID    uint       `json:"job_id"`
+	Status   TaskStatus `json:"status"`
+	Progress float64    `json:"progress"`
+	Error    string     `json:"error,omitempty"`
+	// 错误分类，失败时才有
+	ErrorClass ErrorClass `json:"error_class,omitempty"`
+	// 之前已执行的次数【重试时大于 0】
+	Attempts int `json:"attempts"`
+}
+
 // --- PictureTask ---
 type PictureTask struct {
-	ID       string
-	Path     string
-	Hash     string
-	ImageBuf []byte
-	ExifData map[string]string
-
-	Status   TaskStatus
-	Progress float64
-	Error    error
-
-	ctx      context.Context
-	cancel   context.CancelFunc
-	mu       sync.Mutex
-	pauseCh  chan struct{}
+	ID        string
+	Path      string
+	JobID     uint
+	LibraryID uint
+	Force     bool
+	Hash      string
+	// 保存后的照片记录
+	Photo *model.Photo
+	// 已生成的缩略图
+	Thumbnails []model.Thumbnail
+
+	Status     TaskStatus
+	Progress   float64
+	Error      error
+	ErrorClass ErrorClass
+	// 之前已执行的次数
+	Attempts int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	// 暂停时创建，恢复或取消时关闭
 	resumeCh chan struct{}
+	// 是否已开始执行
+	started bool
+	// 执行时占用的并发名额，为空时不限制
+	slot *workerSlot
+
+	photoRepo *repositories.PhotoRepository
+	thumbRepo *repositories.ThumbnailRepository
+	// 状态变化推送，可为空
+	events *EventBus
+	// 按目录批量读取 EXIF，为空时单独读取
+	exif *exifBatcher
+	// 外部工具
+	tools *tools.Toolkit
+	// 按内容缓存的处理结果，为空时不使用缓存
+	cache *toolCache
 }
 
-func NewPictureTask(path string) *PictureTask {
+func NewPictureTask(path string, opts TaskOptions, photoRepo *repositories.PhotoRepository, thumbRepo *repositories.ThumbnailRepository) *PictureTask {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &PictureTask{
-		ID:       uuid.New().String(),
-		Path:     path,
-		Status:   StatusPending,
-		ctx:      ctx,
-		cancel:   cancel,
-		pauseCh:  make(chan struct{}, 1),
-		resumeCh: make(chan struct{}, 1),
+		ID:        uuid.New().String(),
+		Path:      path,
+		JobID:     opts.JobID,
+		LibraryID: opts.LibraryID,
+		Force:     opts.Force,
+		Status:    StatusPending,
+		ctx:       ctx,
+		cancel:    cancel,
+		photoRepo: photoRepo,
+		thumbRepo: thumbRepo,
 	}
 }
 
-func (pt *PictureTask) waitIfPaused() {
-	select {
-	case <-pt.pauseCh:
-		pt.setStatus(StatusPaused)
-		<-pt.resumeCh
-		pt.setStatus(StatusRunning)
-	default:
+// Pause 暂停任务，执行中的任务在当前步骤结束后暂停
+func (pt *PictureTask) Pause() error {
+	pt.mu.Lock()
+	if !canTransition(pt.Status, StatusPaused) {
+		pt.mu.Unlock()
+		return ErrInvalidTransition
 	}
+	pt.Status = StatusPaused
+	pt.resumeCh = make(chan struct{})
+	pt.mu.Unlock()
+	pt.publish()
+	return nil
 }
 
-func (pt *PictureTask) Pause() {
-	select {
-	case pt.pauseCh <- struct{}{}:
-	default:
+// Resume 恢复暂停的任务，未开始执行的回到排队状态
+func (pt *PictureTask) Resume() error {
+	pt.mu.Lock()
+	if pt.Status != StatusPaused {
+		pt.mu.Unlock()
+		return ErrInvalidTransition
+	}
+	pt.Status = StatusPending
+	if pt.started {
+		pt.Status = StatusRunning
 	}
+	close(pt.resumeCh)
+	pt.resumeCh = nil
+	pt.mu.Unlock()
+	pt.publish()
+	return nil
 }
 
-func (pt *PictureTask) Resume() {
-	select {
-	case pt.resumeCh <- struct{}{}:
-	default:
+// Cancel 取消任务，通过 ctx 终止正在执行的外部命令
+func (pt *PictureTask) Cancel() error {
+	pt.mu.Lock()
+	if !canTransition(pt.Status, StatusCanceled) {
+		pt.mu.Unlock()
+		return ErrInvalidTransition
+	}
+	pt.Status = StatusCanceled
+	if pt.resumeCh != nil {
+		close(pt.resumeCh)
+		pt.resumeCh = nil
+	}
+	pt.mu.Unlock()
+	pt.cancel()
+	pt.publish()
+	return nil
+}
+
+// checkpoint 步骤之间检查暂停和取消，暂停时阻塞到恢复，返回 false 时任务应立即结束
+// 暂停期间归还并发名额，其他任务可以继续执行；恢复后重新等待名额
+func (pt *PictureTask) checkpoint() bool {
+	pt.mu.Lock()
+	resumeCh := pt.resumeCh
+	pt.mu.Unlock()
+
+	if resumeCh != nil {
+		pt.slot.release()
+		select {
+		case <-resumeCh:
+		case <-pt.ctx.Done():
+		}
+		if !pt.slot.acquire(pt.ctx.Done()) {
+			return false
+		}
+	}
+	return pt.ctx.Err() == nil
+}
+
+// start 标记任务开始执行，开始前已被暂停的保持暂停
+func (pt *PictureTask) start() {
+	pt.mu.Lock()
+	pt.started = true
+	pending := pt.Status == StatusPending
+	if pending {
+		pt.Status = StatusRunning
+	}
+	pt.mu.Unlock()
+	if pending {
+		pt.publish()
 	}
 }
 
 func (pt *PictureTask) Run() {
-	pt.setStatus(StatusRunning)
+	pt.start()
+	if !pt.checkpoint() {
+		return
+	}
 
 	// 文件是否存在
-	if !fileExists(pt.Path) {
+	info, err := os.Stat(pt.Path)
+	if err != nil {
 		logger.Error(
 			"指定文件不存在!",
 			zap.String("path", pt.Path),
 		)
+		pt.setError(err)
 		return
 	}
 
-	pt.waitIfPaused()
-	// 读取文件
-	buf, err := os.ReadFile(pt.Path)
+	// 增量模式下，大小和修改时间都未变化的文件直接跳过
+	existing, err := pt.photoRepo.GetPhotoByPath(pt.Path)
 	if err != nil {
 		logger.Error(
-			"文件读取失败!",
+			"照片记录查询失败!",
 			zap.String("path", pt.Path),
+			zap.Error(err),
 		)
+		pt.setError(err)
+		return
+	}
+	if !pt.Force && existing != nil && existing.Unchanged(info.Size(), info.ModTime()) {
+		pt.Photo = existing
+		pt.setStatus(StatusSkipped)
 		return
 	}
 
-	pt.waitIfPaused()
-	// 检测照片格式
-	kind, err := filetype.Match(buf)
+	if !pt.checkpoint() {
+		return
+	}
+	pt.setProgress(0.1)
+	// 检测照片格式【只读取文件头】
+	kind, err := filetype.MatchFile(pt.Path)
 	if err != nil {
 		logger.Error(
 			"文件类型匹配失败!",
 			zap.String("path", pt.Path),
 		)
+		pt.setError(err)
 		return
 	}
 
-	pt.waitIfPaused()
+	if !pt.checkpoint() {
+		return
+	}
 
 	// 不同图像类型不同的处理方式
 	fileType := kind.Extension
 	logger.Info("探测到的文件类型.", zap.String("fileType", fileType))
 
+	pt.setProgress(0.2)
 	// 读取 hash
 	hash, err := utils.HashUtils.HashFile(pt.Path, utils.SHA256)
 	if err != nil {
@@ -135,224 +294,695 @@ func (pt *PictureTask) Run() {
 			"Hash获取失败!",
 			zap.String("path", pt.Path),
 		)
+		pt.setError(err)
 		return
 	}
+	pt.Hash = hash
 	logger.Info("获取到 Hash", zap.String("hash", hash))
 
+	// 路径没有记录但内容相同的照片已不在原位置，说明文件被移动，只更新路径
+	if existing == nil {
+		moved, err := pt.detectMove(hash, info)
+		if err != nil {
+			logger.Error(
+				"移动检测失败!",
+				zap.String("path", pt.Path),
+				zap.Error(err),
+			)
+			pt.setError(err)
+			return
+		}
+		if moved {
+			pt.setStatus(StatusSkipped)
+			return
+		}
+	}
+
+	if !pt.checkpoint() {
+		return
+	}
+	pt.setProgress(0.4)
 	// 获取基本信息，如果图像的很小则不进行压缩
-	ctx := context.Background()
-	exifData, err := tools.GetExifData(ctx, pt.Path)
-	if err != nil {
+	ctx := pt.ctx
+	// 相同内容已处理过时直接使用缓存的结果
+	exifData, cached := pt.cache.getExif(hash, pt.Path, info)
+	if cached {
+		if pt.exif != nil {
+			pt.exif.forget(pt.Path)
+		}
+	} else {
+		exifData, err = pt.readExif(ctx)
+		if err != nil {
+			logger.Error(
+				"EXIF数据获取失败!",
+				zap.String("path", pt.Path),
+				zap.Error(err),
+			)
+			pt.setError(err)
+			return
+		}
+		pt.cache.saveExif(hash, exifData)
+	}
+
+	// 分割 EXIF 数据
+	splitExifData := model.SplitExifData(exifData)
+
+	raw := config.CONFIG.IsRawFile(pt.Path)
+	thumbs, cached := pt.cache.getThumbnails(hash, raw)
+	if !cached {
+		if !pt.checkpoint() {
+			return
+		}
+		pt.setProgress(0.6)
+		thumbs, err = pt.makeThumbnails(ctx, hash, fileType, raw, splitExifData)
+		if err != nil {
+			pt.setError(err)
+			return
+		}
+		pt.cache.saveThumbnails(hash, raw, thumbs)
+	}
+	if err := pt.thumbRepo.SaveThumbnails(thumbs); err != nil {
 		logger.Error(
-			"EXIF数据获取失败!",
+			"缩略图信息保存失败!",
 			zap.String("path", pt.Path),
 			zap.Error(err),
 		)
+		pt.setError(err)
 		return
 	}
+	pt.Thumbnails = thumbs
 
-	// 分割 EXIF 数据
-	splitExifData := model.SplitExifData(exifData)
-
-	// 如果是非常规格式或 raw 则转换为 png ；如果是 PNG 则无损转换为 webp 或 jpg；如果是 webp 或 jpg 则进行压缩和其他处理
-	if fileType == string(consts.FormatJPG) {
-		//options := tools.DefaultOptions()
-		//options.MaxSize = 800
-		//options.Quality = 90
-		//options.Format = "jpeg"
-		//tools.LibVipsUtil.ProcessImage(options)
-	} else if fileType == string(consts.FormatWEBP) {
-	} else if fileType == string(consts.FormatPNG) {
-	} else {
-
+	if !pt.checkpoint() {
+		return
 	}
-
-	// 图像转换后，将转换后照片信息检索判断是否有必要进行压缩
-
-	// 压缩图像 【判断图像的大小是否需要压缩】
-	imgWidth := splitExifData.BaseInfo.ImageWidth
-	imgHeight := splitExifData.BaseInfo.ImageHeight
-	logger.Info("图像宽度",
-		zap.Int("width", imgWidth),
-		zap.Int("height", imgHeight),
-	)
-
-	// 如果图像的宽度或高度小于 800，则不进行压缩
-
-	return
-
-	// 分割 Hash 路径
-	//utils.HashUtils.HashThumbPath(config.CONFIG.AppDir, hash)
-
-	// 获取 exif
+	pt.setProgress(0.9)
 	// 保存到数据库
-
-	pt.waitIfPaused()
-	data, err := os.ReadFile(pt.Path)
-	if err != nil {
-		pt.setError(err)
+	photo := model.NewPhotoFromExif(pt.LibraryID, pt.Path, hash, splitExifData)
+	photo.FileSize = info.Size()
+	photo.FileModTime = info.ModTime()
+	if photo.MIMEType == "" {
+		photo.MIMEType = kind.MIME.Value
+	}
+	if photo.CaptureTime.IsZero() {
+		photo.SetCaptureTime(info.ModTime(), model.CaptureTimeFromFile)
+	}
+	if err := pt.photoRepo.SavePhoto(photo); err != nil {
 		logger.Error(
-			"文件读取失败!",
-			zap.String("filename", pt.Path),
+			"照片信息保存失败!",
+			zap.String("path", pt.Path),
 			zap.Error(err),
 		)
+		pt.setError(err)
 		return
 	}
+	pt.Photo = photo
 
-	pt.ImageBuf = data
-	pt.Hash = calcHash(data)
+	pt.setDone()
+}
 
-	pt.waitIfPaused()
-	compressed, err := compressImage(data)
-	if err != nil {
-		pt.setError(err)
-		return
+// makeThumbnails 生成缩略图【大于原图的尺寸跳过，按 EXIF 方向自动旋转】
+// 非常规格式或 raw 先转换为 png ；常规格式直接作为缩略图源，临时文件在返回时删除
+func (pt *PictureTask) makeThumbnails(ctx context.Context, hash, fileType string, raw bool, exifData *model.ParsedExif) ([]model.Thumbnail, error) {
+	thumbSource := pt.Path
+	var prepare func() (string, func(), error)
+	switch {
+	case raw:
+		prepare = func() (string, func(), error) {
+			return prepareRawSource(ctx, pt.tools, pt.Path, pt.ID, exifData.Exif.Orientation)
+		}
+	case config.CONFIG.IsSpecialFile(pt.Path):
+		prepare = func() (string, func(), error) {
+			return preparePngSource(ctx, pt.tools.Processors, pt.Path, pt.ID)
+		}
+	}
+	if prepare != nil {
+		source, cleanup, err := prepare()
+		if err != nil {
+			logger.Error(
+				"缩略图源文件转换失败!",
+				zap.String("path", pt.Path),
+				zap.String("fileType", fileType),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+		defer cleanup()
+		thumbSource = source
 	}
-	pt.ImageBuf = compressed
 
-	pt.waitIfPaused()
-	exif, err := readExifViaCmd(pt.Path)
+	thumbs, err := generateThumbnails(ctx, pt.tools.Processors, thumbSource, hash,
+		exifData.BaseInfo.ImageWidth, exifData.BaseInfo.ImageHeight, exifData.Exif.Orientation)
 	if err != nil {
-		pt.setError(err)
-		return
+		logger.Error(
+			"缩略图生成失败!",
+			zap.String("path", pt.Path),
+			zap.Error(err),
+		)
+		return nil, err
 	}
-	pt.ExifData = exif
+	return thumbs, nil
+}
 
-	pt.waitIfPaused()
-	err = saveToDB(pt.Hash, exif)
-	if err != nil {
-		pt.setError(err)
-		return
+// readExif 读取 EXIF 数据，同目录的文件已登记批量读取时从批量结果中获取
+func (pt *PictureTask) readExif(ctx context.Context) (tools.ExifData, error) {
+	if pt.exif == nil {
+		return pt.tools.Metadata.Read(ctx, pt.Path)
 	}
+	return pt.exif.get(ctx, pt.Path)
+}
 
-	pt.setDone()
+// safeRun 执行任务，panic 时将任务标记为失败
+func (pt *PictureTask) safeRun() {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("任务执行异常!",
+				zap.String("path", pt.Path),
+				zap.Any("panic", r),
+			)
+			pt.setError(fmt.Errorf("panic: %v", r))
+		}
+	}()
+	pt.Run()
+}
+
+// detectMove 查找内容相同且原文件已不存在的记录，找到则将其路径更新为当前文件
+func (pt *PictureTask) detectMove(hash string, info os.FileInfo) (bool, error) {
+	photos, err := pt.photoRepo.GetPhotosByHash(hash)
+	if err != nil {
+		return false, err
+	}
+	for i := range photos {
+		photo := photos[i]
+		if !photo.Missing && fileExists(photo.Path) {
+			// 原文件还在，是副本而不是移动
+			continue
+		}
+		if err := pt.photoRepo.MovePhoto(photo.ID, pt.LibraryID, pt.Path, info.Size(), info.ModTime()); err != nil {
+			return false, err
+		}
+		logger.Info("检测到文件移动",
+			zap.String("from", photo.Path),
+			zap.String("to", pt.Path),
+		)
+		photo.Path = pt.Path
+		photo.LibraryID = pt.LibraryID
+		pt.Photo = &photo
+		return true, nil
+	}
+	return false, nil
 }
 
+// setError 标记失败，已取消的任务保持取消状态
 func (pt *PictureTask) setError(err error) {
 	pt.mu.Lock()
-	defer pt.mu.Unlock()
+	if !canTransition(pt.Status, StatusFailed) {
+		pt.mu.Unlock()
+		return
+	}
 	pt.Status = StatusFailed
 	pt.Error = err
+	pt.ErrorClass = classifyError(err)
+	pt.mu.Unlock()
+	pt.publish()
 }
 
 func (pt *PictureTask) setDone() {
 	pt.mu.Lock()
-	defer pt.mu.Unlock()
+	if !canTransition(pt.Status, StatusDone) {
+		pt.mu.Unlock()
+		return
+	}
 	pt.Status = StatusDone
 	pt.Progress = 1.0
+	pt.mu.Unlock()
+	pt.publish()
 }
 
+// setStatus 按状态机转换状态，不允许的转换被忽略【如暂停中不会被置为执行中】
 func (pt *PictureTask) setStatus(s TaskStatus) {
 	pt.mu.Lock()
-	defer pt.mu.Unlock()
+	if !canTransition(pt.Status, s) {
+		pt.mu.Unlock()
+		return
+	}
 	pt.Status = s
+	pt.mu.Unlock()
+	pt.publish()
+}
+
+// publish 推送当前状态
+func (pt *PictureTask) publish() {
+	pt.events.Publish(EventTask, pt.Info())
+}
+
+func (pt *PictureTask) setProgress(p float64) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.Progress = p
+}
+
+// Info 获取任务状态快照
+func (pt *PictureTask) Info() TaskInfo {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	info := TaskInfo{
+		ID:       pt.ID,
+		Path:     pt.Path,
+		JobID:    pt.JobID,
+		Status:   pt.Status,
+		Progress: pt.Progress,
+		Attempts: pt.Attempts,
+	}
+	if pt.Error != nil {
+		info.Error = pt.Error.Error()
+		info.ErrorClass = pt.ErrorClass
+	}
+	return info
 }
 
 // --- ImgTaskManager ---
 type ImgTaskManager struct {
-	tasks        map[string]*PictureTask
-	queue        chan *PictureTask
-	workerPool   chan struct{}
-	workerLimit  int
-	mu           sync.RWMutex
-	globalPause  chan struct{}
-	globalResume chan struct{}
-	poolMu       sync.Mutex
-	doneCount    int
-	autoAdjust   bool
-}
-
-func NewImgTaskManager(concurrency int) *ImgTaskManager {
+	tasks     map[string]*PictureTask
+	queue     chan *PictureTask
+	mu        sync.RWMutex
+	doneCount int
+	// 取出队列时处于暂停状态的任务，恢复后重新入队，不占用并发名额
+	parked map[string]*PictureTask
+	// 已暂停、已取消的索引任务，之后加入的文件任务同样暂停或直接取消
+	pausedJobs   map[uint]bool
+	canceledJobs map[uint]bool
+	// 全局暂停，之后加入的任务同样暂停
+	pausedAll bool
+	// 并发控制，autoAdjust 开启时按系统负载调整
+	limiter    *workerLimiter
+	tuner      *concurrencyTuner
+	autoAdjust atomic.Bool
+	// 临时错误的重试策略
+	retry config.RetryConfig
+	// 等待重试的任务 ID -> 定时器，关闭时停止
+	retryTimers map[string]*time.Timer
+	// 任务结束回调
+	finishedHooks []func(task *PictureTask)
+	events        *EventBus
+	// 执行中的任务，关闭时等待其结束
+	running  sync.WaitGroup
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	runDone  chan struct{}
+
+	// 按目录批量读取 EXIF
+	exif *exifBatcher
+	// 外部工具
+	tools *tools.Toolkit
+	// 按内容缓存的处理结果
+	cache *toolCache
+
+	photoRepo *repositories.PhotoRepository
+	thumbRepo *repositories.ThumbnailRepository
+	taskRepo  *repositories.TaskRepository
+}
+
+func NewImgTaskManager(cfg config.ConcurrencyConfig, retry config.RetryConfig, photoRepo *repositories.PhotoRepository,
+	thumbRepo *repositories.ThumbnailRepository, taskRepo *repositories.TaskRepository, cacheRepo *repositories.ToolCacheRepository, events *EventBus, kit *tools.Toolkit) *ImgTaskManager {
 	tm := &ImgTaskManager{
+		retry:        retry,
+		photoRepo:    photoRepo,
+		thumbRepo:    thumbRepo,
+		taskRepo:     taskRepo,
+		events:       events,
+		stopCh:       make(chan struct{}),
+		runDone:      make(chan struct{}),
 		tasks:        make(map[string]*PictureTask),
 		queue:        make(chan *PictureTask, 100),
-		workerPool:   make(chan struct{}, concurrency),
-		workerLimit:  concurrency,
-		globalPause:  make(chan struct{}, 1),
-		globalResume: make(chan struct{}, 1),
-		autoAdjust:   true,
+		parked:       make(map[string]*PictureTask),
+		pausedJobs:   make(map[uint]bool),
+		canceledJobs: make(map[uint]bool),
+		retryTimers:  make(map[string]*time.Timer),
+		exif:         newExifBatcher(kit.Metadata),
+		tools:        kit,
+		cache:        newToolCache(cacheRepo, kit),
 	}
+	tm.tuner = &concurrencyTuner{cfg: cfg}
+	tm.limiter = newWorkerLimiter(tm.tuner.clamp(cfg.Initial))
+	tm.autoAdjust.Store(true)
 	go tm.run()
-	go tm.monitorCPU()
+	go tm.monitorLoad(cfg.Interval)
 	return tm
 }
 
+// SetConcurrency 调整并发数【限制在配置的上下限内】，已在执行的任务不受影响
 func (tm *ImgTaskManager) SetConcurrency(n int) {
-	tm.poolMu.Lock()
-	defer tm.poolMu.Unlock()
+	tm.limiter.setLimit(tm.tuner.clamp(n))
+}
+
+// SetAutoAdjust 开启或关闭按系统负载自动调整并发
+func (tm *ImgTaskManager) SetAutoAdjust(enable bool) {
+	tm.autoAdjust.Store(enable)
+}
 
-	if n <= 0 || n == tm.workerLimit {
+// Concurrency 当前并发上限和正在执行的任务数
+func (tm *ImgTaskManager) Concurrency() (limit, active int) {
+	return tm.limiter.stats()
+}
+
+// AddTask 添加文件任务，所属索引任务已取消时不添加并返回空字符串
+func (tm *ImgTaskManager) AddTask(path string, opts TaskOptions) string {
+	if tm.JobCanceled(opts.JobID) {
+		return ""
+	}
+	task := tm.newTask(path, opts)
+	// 先保存再入队，进程中断后可以从数据库恢复
+	err := tm.taskRepo.CreateTask(&model.PictureTaskRecord{
+		TaskID:    task.ID,
+		Path:      path,
+		JobID:     opts.JobID,
+		LibraryID: opts.LibraryID,
+		Force:     opts.Force,
+		Status:    string(StatusPending),
+	})
+	if err != nil {
+		logger.Error("任务保存失败!", zap.String("path", path), zap.Error(err))
+	}
+	tm.enqueue(task)
+	return task.ID
+}
+
+// SaveTasks 批量保存文件任务记录但不入队，所属索引任务已取消时不保存
+// 扫描阶段先保存全部记录，之后再由 Dispatch 入队；保存失败时仍返回记录，只是中断后无法恢复
+func (tm *ImgTaskManager) SaveTasks(paths []string, opts TaskOptions) ([]model.PictureTaskRecord, error) {
+	if tm.JobCanceled(opts.JobID) || len(paths) == 0 {
+		return nil, nil
+	}
+	records := make([]model.PictureTaskRecord, 0, len(paths))
+	for _, path := range paths {
+		records = append(records, model.PictureTaskRecord{
+			TaskID:    uuid.New().String(),
+			Path:      path,
+			JobID:     opts.JobID,
+			LibraryID: opts.LibraryID,
+			Force:     opts.Force,
+			Status:    string(StatusPending),
+		})
+	}
+	return records, tm.taskRepo.CreateTasks(records)
+}
+
+// Dispatch 将已保存的任务按顺序入队，连续的同一目录文件登记为一批读取 EXIF
+// 队列容量有限，在后台逐个入队，关闭后停止
+func (tm *ImgTaskManager) Dispatch(records []model.PictureTaskRecord) {
+	if len(records) == 0 {
 		return
 	}
+	go func() {
+		for start := 0; start < len(records); {
+			dir := filepath.Dir(records[start].Path)
+			end := start + 1
+			for end < len(records) && filepath.Dir(records[end].Path) == dir {
+				end++
+			}
+			batch := records[start:end]
+			start = end
 
-	newPool := make(chan struct{}, n)
-	tm.mu.Lock()
-	used := len(tm.workerPool)
-	for i := 0; i < used && i < n; i++ {
-		newPool <- struct{}{}
+			select {
+			case <-tm.stopCh:
+				return
+			default:
+			}
+			paths := make([]string, 0, len(batch))
+			for _, record := range batch {
+				paths = append(paths, record.Path)
+			}
+			tm.exif.add(paths)
+			for _, record := range batch {
+				task := tm.newTask(record.Path, TaskOptions{
+					JobID:     record.JobID,
+					LibraryID: record.LibraryID,
+					Force:     record.Force,
+				})
+				task.ID = record.TaskID
+				task.Attempts = record.Attempts
+				tm.enqueue(task)
+			}
+		}
+	}()
+}
+
+// Recover 恢复上次未结束的任务：执行中的重新排队，排队中的按原顺序入队
+// 返回这些任务所属的索引任务
+func (tm *ImgTaskManager) Recover() ([]uint, error) {
+	_, err := tm.taskRepo.ResetStatus([]string{string(StatusRunning), string(StatusPaused)}, string(StatusPending))
+	if err != nil {
+		return nil, err
 	}
-	tm.workerPool = newPool
-	tm.workerLimit = n
-	tm.mu.Unlock()
+	records, err := tm.taskRepo.GetTasksByStatus(string(StatusPending))
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make(map[uint]bool)
+	var jobIDs []uint
+	for _, record := range records {
+		if record.JobID != 0 && !jobs[record.JobID] {
+			jobs[record.JobID] = true
+			jobIDs = append(jobIDs, record.JobID)
+		}
+	}
+	if len(records) == 0 {
+		return jobIDs, nil
+	}
+
+	logger.Info("恢复未完成的任务", zap.Int("count", len(records)))
+	tm.Dispatch(records)
+	return jobIDs, nil
 }
 
-func (tm *ImgTaskManager) AddTask(path string) string {
-	task := NewPictureTask(path)
+// Shutdown 停止执行新任务并等待执行中的任务结束
+// 未开始的任务保留在数据库中；ctx 到期后取消剩余任务，它们在下次启动时重新排队
+func (tm *ImgTaskManager) Shutdown(ctx context.Context) error {
+	tm.stopOnce.Do(func() {
+		close(tm.stopCh)
+	})
+	tm.stopRetryTimers()
+	<-tm.runDone
+
+	done := make(chan struct{})
+	go func() {
+		tm.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		tm.mu.RLock()
+		for _, task := range tm.tasks {
+			task.cancel()
+		}
+		tm.mu.RUnlock()
+		return ctx.Err()
+	}
+}
+
+func (tm *ImgTaskManager) newTask(path string, opts TaskOptions) *PictureTask {
+	task := NewPictureTask(path, opts, tm.photoRepo, tm.thumbRepo)
+	task.events = tm.events
+	task.exif = tm.exif
+	task.tools = tm.tools
+	task.cache = tm.cache
+	return task
+}
+
+// enqueue 加入执行队列，关闭后不再入队
+// 所属索引任务已暂停的任务以暂停状态入队，已取消的直接结束
+func (tm *ImgTaskManager) enqueue(task *PictureTask) {
 	tm.mu.Lock()
 	tm.tasks[task.ID] = task
+	canceled := tm.canceledJobs[task.JobID]
+	if canceled {
+		_ = task.Cancel()
+	} else if tm.pausedAll || tm.pausedJobs[task.JobID] {
+		_ = task.Pause()
+	}
 	tm.mu.Unlock()
-	tm.queue <- task
-	return task.ID
+	task.publish()
+
+	if canceled {
+		tm.finish(task)
+		return
+	}
+	tm.publishCounters()
+	tm.push(task)
 }
 
-func (tm *ImgTaskManager) PauseAll() {
+// push 放入执行队列
+func (tm *ImgTaskManager) push(task *PictureTask) {
 	select {
-	case tm.globalPause <- struct{}{}:
-	default:
+	case tm.queue <- task:
+	case <-tm.stopCh:
 	}
 }
 
-func (tm *ImgTaskManager) ResumeAll() {
+// stopped 是否已开始关闭
+func (tm *ImgTaskManager) stopped() bool {
 	select {
-	case tm.globalResume <- struct{}{}:
+	case <-tm.stopCh:
+		return true
 	default:
+		return false
 	}
 }
 
-func (tm *ImgTaskManager) applyGlobalPauseResume(task *PictureTask) {
-	go func() {
-		for {
-			select {
-			case <-tm.globalPause:
-				task.Pause()
-			case <-tm.globalResume:
-				task.Resume()
-			}
-		}
-	}()
+// OnTaskFinished 注册任务结束回调【成功、失败、跳过、取消都会触发】
+func (tm *ImgTaskManager) OnTaskFinished(fn func(task *PictureTask)) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.finishedHooks = append(tm.finishedHooks, fn)
 }
 
 func (tm *ImgTaskManager) run() {
-	for task := range tm.queue {
-		tm.workerPool <- struct{}{}
-		tm.applyGlobalPauseResume(task)
+	defer close(tm.runDone)
+	for {
+		var task *PictureTask
+		select {
+		case task = <-tm.queue:
+		case <-tm.stopCh:
+			return
+		}
+		if !tm.dispatchable(task) {
+			continue
+		}
+		if !tm.limiter.acquire(tm.stopCh) {
+			return
+		}
+		task.slot = &workerSlot{limiter: tm.limiter, held: true}
+		tm.running.Add(1)
 		go func(t *PictureTask) {
-			defer func() { <-tm.workerPool }()
-			t.Run()
-			tm.mu.Lock()
-			if t.Status == StatusDone {
-				tm.doneCount++
+			defer tm.running.Done()
+			defer t.slot.release()
+			tm.saveStatus(t.ID, StatusRunning, "")
+			t.safeRun()
+			if t.ctx.Err() != nil && t.Info().Status != StatusCanceled {
+				// 关闭时被取消，不计入结果，下次启动重新排队
+				return
 			}
-			tm.mu.Unlock()
+			tm.finish(t)
 		}(task)
 	}
 }
 
-func (tm *ImgTaskManager) GetStatus(id string) TaskStatus {
+// dispatchable 取出队列的任务是否可以执行：暂停的暂存到恢复，已取消的直接结束
+func (tm *ImgTaskManager) dispatchable(task *PictureTask) bool {
+	tm.mu.Lock()
+	status := task.Info().Status
+	if status == StatusPaused {
+		tm.parked[task.ID] = task
+	}
+	tm.mu.Unlock()
+
+	switch status {
+	case StatusPaused:
+		return false
+	case StatusCanceled:
+		tm.finish(task)
+		return false
+	}
+	return true
+}
+
+// finish 任务结束后的计数、回调和持久化，临时错误安排重试而不计入结果
+func (tm *ImgTaskManager) finish(t *PictureTask) {
+	tm.exif.forget(t.Path)
+	if tm.retryLater(t) {
+		return
+	}
+	tm.mu.Lock()
+	if status := t.Info().Status; status == StatusDone || status == StatusSkipped {
+		tm.doneCount++
+	}
+	hooks := tm.finishedHooks
+	tm.mu.Unlock()
+	for _, hook := range hooks {
+		hook(t)
+	}
+	tm.saveResult(t)
+
+	// 结果保存后不再保留在内存中，之后的查询读取数据库
+	tm.mu.Lock()
+	if tm.tasks[t.ID] == t {
+		delete(tm.tasks, t.ID)
+	}
+	tm.mu.Unlock()
+	tm.publishCounters()
+}
+
+// saveResult 保存任务结果：失败的保留，成功、跳过和取消的删除
+func (tm *ImgTaskManager) saveResult(t *PictureTask) {
+	info := t.Info()
+	switch info.Status {
+	case StatusFailed:
+		err := tm.taskRepo.UpdateTask(t.ID, map[string]interface{}{
+			"status":      string(StatusFailed),
+			"error":       info.Error,
+			"error_class": string(info.ErrorClass),
+			"attempts":    info.Attempts + 1,
+		})
+		if err != nil {
+			logger.Error("任务状态保存失败!", zap.String("task", t.ID), zap.Error(err))
+		}
+	case StatusDone, StatusSkipped, StatusCanceled:
+		if err := tm.taskRepo.DeleteTask(t.ID); err != nil {
+			logger.Error("任务删除失败!", zap.String("task", t.ID), zap.Error(err))
+		}
+	}
+}
+
+func (tm *ImgTaskManager) saveStatus(id string, status TaskStatus, message string) {
+	if err := tm.taskRepo.UpdateTaskStatus(id, string(status), message); err != nil {
+		logger.Error("任务状态保存失败!", zap.String("task", id), zap.Error(err))
+	}
+}
+
+// publishCounters 推送全局任务计数
+func (tm *ImgTaskManager) publishCounters() {
+	tm.events.Publish(EventCounters, TaskCounters{
+		Done:      tm.DoneCount(),
+		Remaining: tm.RemainingCount(),
+	})
+}
+
+// GetTask 获取任务状态快照
+// 已结束的任务从数据库读取，成功、跳过和取消的任务记录已删除，返回 false
+func (tm *ImgTaskManager) GetTask(id string) (TaskInfo, bool) {
 	tm.mu.RLock()
-	defer tm.mu.RUnlock()
-	if task, ok := tm.tasks[id]; ok {
-		return task.Status
+	task, ok := tm.tasks[id]
+	tm.mu.RUnlock()
+	if ok {
+		return task.Info(), true
+	}
+
+	record, err := tm.taskRepo.GetTaskByTaskID(id)
+	if err != nil {
+		logger.Error("任务查询失败!", zap.String("task", id), zap.Error(err))
+		return TaskInfo{}, false
+	}
+	if record == nil {
+		return TaskInfo{}, false
+	}
+	return TaskInfo{
+		ID:         record.TaskID,
+		Path:       record.Path,
+		JobID:      record.JobID,
+		Status:     TaskStatus(record.Status),
+		Error:      record.Error,
+		ErrorClass: ErrorClass(record.ErrorClass),
+		Attempts:   record.Attempts,
+	}, true
+}
+
+func (tm *ImgTaskManager) GetStatus(id string) TaskStatus {
+	if info, ok := tm.GetTask(id); ok {
+		return info.Status
 	}
 	return "not_found"
 }
@@ -365,69 +995,46 @@ func (tm *ImgTaskManager) DoneCount() int {
 func (tm *ImgTaskManager) RemainingCount() int {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
-	return len(tm.tasks) - tm.doneCount
+	return len(tm.tasks)
 }
 
-func (tm *ImgTaskManager) monitorCPU() {
-	ticker := time.NewTicker(5 * time.Second)
-	for range ticker.C {
-		if !tm.autoAdjust {
+// monitorLoad 定期采样系统负载并调整并发数
+func (tm *ImgTaskManager) monitorLoad(interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	sampler := newLoadSampler()
+	// 先采样一次作为 CPU 时间基准
+	sampler.sample()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-tm.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		cpu, mem := sampler.sample()
+		if !tm.autoAdjust.Load() {
 			continue
 		}
-		cpuPercent := getCurrentCPUUsage()
-		if cpuPercent > 80 {
-			tm.SetConcurrency(tm.workerLimit / 2)
-		} else if cpuPercent < 40 {
-			tm.SetConcurrency(tm.workerLimit + 1)
+		current, _ := tm.limiter.stats()
+		if target := tm.tuner.next(current, cpu, mem); target != current {
+			tm.limiter.setLimit(target)
+			logger.Info("调整任务并发数",
+				zap.Int("from", current),
+				zap.Int("to", target),
+				zap.Float64("cpu", cpu),
+				zap.Float64("mem", mem),
+			)
 		}
 	}
 }
 
-// 模拟 CPU 使用率函数（替换为真实库，如 gopsutil）
-func getCurrentCPUUsage() int {
-	return runtime.NumGoroutine() * 5 // 简单估算
-}
-
 // --- 工具函数 ---
 
 func fileExists(path string) bool {
 	return utils.FileUtils.Exists(path)
 }
-
-func calcHash(data []byte) string {
-	logger.Info("calcHash")
-	h := sha1.Sum(data)
-	return hex.EncodeToString(h[:])
-}
-
-func compressImage(data []byte) ([]byte, error) {
-	logger.Info("compressImage")
-	time.Sleep(100 * time.Millisecond) // 模拟耗时
-	return data, nil
-}
-
-func readExifViaCmd(filePath string) (map[string]string, error) {
-	logger.Info("readExifViaCmd")
-	cmd := exec.Command("exiftool", filePath)
-	out, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-	result := make(map[string]string)
-	lines := bytes.Split(out, []byte("\n"))
-	for _, line := range lines {
-		kv := bytes.SplitN(line, []byte(":"), 2)
-		if len(kv) == 2 {
-			key := string(bytes.TrimSpace(kv[0]))
-			val := string(bytes.TrimSpace(kv[1]))
-			result[key] = val
-		}
-	}
-	return result, nil
-}
-
-func saveToDB(hash string, exif map[string]string) error {
-	logger.Info("saveToDB")
-	fmt.Printf("Saving %s to DB with %d exif fields\n", hash, len(exif))
-	return nil
-}
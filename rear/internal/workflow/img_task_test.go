@@ -0,0 +1,239 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"rear/internal/config"
+	"rear/internal/db"
+	"rear/internal/model"
+	"rear/internal/repositories"
+	"rear/internal/utils/tools"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestPictureTaskStateMachine(t *testing.T) {
+	task := NewPictureTask("a.jpg", TaskOptions{}, nil, nil)
+
+	// 未开始时暂停，恢复后回到排队状态
+	if err := task.Pause(); err != nil {
+		t.Fatalf("pause pending task: %v", err)
+	}
+	if err := task.Pause(); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("pause twice: got %v, want ErrInvalidTransition", err)
+	}
+	if err := task.Resume(); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if status := task.Info().Status; status != StatusPending {
+		t.Fatalf("status after resume = %s, want pending", status)
+	}
+
+	// 开始后暂停，checkpoint 阻塞到恢复
+	task.start()
+	if err := task.Pause(); err != nil {
+		t.Fatalf("pause running task: %v", err)
+	}
+	passed := make(chan bool, 1)
+	go func() {
+		passed <- task.checkpoint()
+	}()
+	select {
+	case <-passed:
+		t.Fatal("checkpoint passed while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := task.Resume(); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if ok := <-passed; !ok {
+		t.Fatal("checkpoint returned false after resume")
+	}
+	if status := task.Info().Status; status != StatusRunning {
+		t.Fatalf("status after resume = %s, want running", status)
+	}
+
+	// 取消后 ctx 结束，失败不会覆盖取消状态
+	if err := task.Cancel(); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if task.ctx.Err() == nil {
+		t.Fatal("ctx not canceled")
+	}
+	if task.checkpoint() {
+		t.Fatal("checkpoint passed after cancel")
+	}
+	task.setError(errors.New("killed"))
+	if status := task.Info().Status; status != StatusCanceled {
+		t.Fatalf("status after error = %s, want canceled", status)
+	}
+	if err := task.Resume(); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("resume canceled task: got %v, want ErrInvalidTransition", err)
+	}
+}
+
+func TestPausedTaskCancel(t *testing.T) {
+	task := NewPictureTask("a.jpg", TaskOptions{}, nil, nil)
+	task.start()
+	if err := task.Pause(); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+
+	passed := make(chan bool, 1)
+	go func() {
+		passed <- task.checkpoint()
+	}()
+	if err := task.Cancel(); err != nil {
+		t.Fatalf("cancel paused task: %v", err)
+	}
+	select {
+	case ok := <-passed:
+		if ok {
+			t.Fatal("checkpoint passed after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("paused checkpoint not released by cancel")
+	}
+}
+
+func TestPausedTaskReleasesSlot(t *testing.T) {
+	limiter := newWorkerLimiter(1)
+	stop := make(chan struct{})
+	defer close(stop)
+	if !limiter.acquire(stop) {
+		t.Fatal("acquire failed")
+	}
+	task := NewPictureTask("a.jpg", TaskOptions{}, nil, nil)
+	task.slot = &workerSlot{limiter: limiter, held: true}
+	task.start()
+	if err := task.Pause(); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+
+	passed := make(chan bool, 1)
+	go func() {
+		passed <- task.checkpoint()
+	}()
+	// 暂停期间名额归还，其他任务可以执行
+	waitUntil(t, func() bool {
+		_, active := limiter.stats()
+		return active == 0
+	})
+	if !limiter.acquire(stop) {
+		t.Fatal("slot not available while task paused")
+	}
+
+	// 恢复后等到其他任务归还名额才继续
+	if err := task.Resume(); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	select {
+	case <-passed:
+		t.Fatal("checkpoint passed without a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+	limiter.release()
+	if ok := <-passed; !ok {
+		t.Fatal("checkpoint returned false after resume")
+	}
+	if _, active := limiter.stats(); active != 1 || !task.slot.held {
+		t.Fatalf("active = %d, held = %v, want 1 and true", active, task.slot.held)
+	}
+}
+
+// openTestDB 使用临时 SQLite 数据库
+func openTestDB(t *testing.T) {
+	t.Helper()
+	repositories.InitBaseService()
+	conn, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = previous })
+	// 未以 sqlite_fts5 构建标签运行测试时使用 LIKE 检索
+	config.CONFIG.SearchLikeFallback = true
+	if err := db.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSaveTasksBeforeDispatch(t *testing.T) {
+	openTestDB(t)
+	dir := t.TempDir()
+	unchanged := filepath.Join(dir, "a.jpg")
+	if err := os.WriteFile(unchanged, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(unchanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	photoRepo, taskRepo := repositories.NewPhotoRepository(), repositories.NewTaskRepository()
+	if err := db.DB.Create(&model.LibraryTable{ImgPath: dir}).Error; err != nil {
+		t.Fatal(err)
+	}
+	err = photoRepo.SavePhoto(&model.Photo{LibraryID: 1, Path: unchanged, FileSize: info.Size(), FileModTime: info.ModTime()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tm := NewImgTaskManager(config.ConcurrencyConfig{Initial: 2, Min: 1, Max: 2}, config.RetryConfig{MaxAttempts: 1},
+		photoRepo, repositories.NewThumbnailRepository(), taskRepo, repositories.NewToolCacheRepository(),
+		NewEventBus(), tools.NewToolkit(tools.NewFakeRunner()))
+	defer tm.Shutdown(context.Background())
+
+	// 保存后尚未入队，进程中断时记录仍可恢复
+	records, err := tm.SaveTasks([]string{unchanged, filepath.Join(dir, "missing.jpg")}, TaskOptions{JobID: 1, LibraryID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := taskRepo.GetTasksByStatus(string(StatusPending))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || tm.RemainingCount() != 0 {
+		t.Fatalf("pending records = %d, remaining = %d, want 2 and 0", len(pending), tm.RemainingCount())
+	}
+
+	finished := make(chan struct{}, len(records))
+	tm.OnTaskFinished(func(*PictureTask) { finished <- struct{}{} })
+	tm.Dispatch(records)
+	for range records {
+		select {
+		case <-finished:
+		case <-time.After(5 * time.Second):
+			t.Fatal("tasks not finished")
+		}
+	}
+
+	// 结束的任务移出内存：跳过的记录已删除，失败的从数据库读取
+	waitUntil(t, func() bool { return tm.RemainingCount() == 0 })
+	if _, ok := tm.GetTask(records[0].TaskID); ok {
+		t.Error("skipped task still found")
+	}
+	if info, ok := tm.GetTask(records[1].TaskID); !ok || info.Status != StatusFailed {
+		t.Errorf("failed task = %+v, %v, want failed", info, ok)
+	}
+}
+
+// waitUntil 等待条件成立
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
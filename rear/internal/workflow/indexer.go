@@ -0,0 +1,169 @@
+package workflow
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"path/filepath"
+	"rear/internal/config"
+	"rear/internal/model"
+	"rear/internal/repositories"
+	"rear/pkg/logger"
+	"rear/pkg/utils"
+)
+
+// IndexSummary 一次存储库索引的统计
+type IndexSummary struct {
+	LibraryID uint   `json:"library_id"`
+	Path      string `json:"path"`
+	// 扫描到的支持文件数
+	Total int `json:"total"`
+	// 加入处理队列的文件数
+	Queued int `json:"queued"`
+	// 未变化而跳过的文件数
+	Skipped int `json:"skipped"`
+	// 新标记为缺失的记录数
+	Missing int `json:"missing"`
+	// 已保存、待分发的文件任务
+	tasks []model.PictureTaskRecord
+}
+
+// Indexer 存储库索引器，负责扫描文件并与已有记录比对
+type Indexer struct {
+	taskManager *ImgTaskManager
+	jobManager  *JobManager
+	photoRepo   *repositories.PhotoRepository
+}
+
+func NewIndexer(taskManager *ImgTaskManager, jobManager *JobManager, photoRepo *repositories.PhotoRepository) *Indexer {
+	return &Indexer{
+		taskManager: taskManager,
+		jobManager:  jobManager,
+		photoRepo:   photoRepo,
+	}
+}
+
+// StartIndex 创建索引任务并在后台扫描存储库，返回创建的任务
+// 扫描时只保存文件任务记录，全部保存并进入处理阶段后才入队，进程中断后可以由 Recover 继续
+func (ix *Indexer) StartIndex(libraries []model.LibraryTable, full bool) (*model.IndexJob, error) {
+	mode := IndexModeIncremental
+	if full {
+		mode = IndexModeFull
+	}
+	job, err := ix.jobManager.CreateJob(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		var summaries []*IndexSummary
+		for _, library := range libraries {
+			if ix.taskManager.JobCanceled(job.ID) {
+				break
+			}
+			summary, err := ix.IndexLibrary(library, full, job.ID)
+			if err != nil {
+				logger.Error("文件获取失败！", zap.String("path", library.ImgPath), zap.Error(err))
+				ix.jobManager.RecordError(job.ID, library.ImgPath, err.Error())
+			}
+			summaries = append(summaries, summary)
+		}
+		ix.jobManager.FinishScan(job.ID, summaries)
+		// 扫描期间被取消的任务入队后直接结束，记录随之删除
+		var tasks []model.PictureTaskRecord
+		for _, summary := range summaries {
+			tasks = append(tasks, summary.tasks...)
+		}
+		ix.taskManager.Dispatch(tasks)
+	}()
+
+	return job, nil
+}
+
+// IndexLibrary 索引指定存储库
+// full 为 false 时为增量模式：大小和修改时间都未变化的文件不会进入队列
+func (ix *Indexer) IndexLibrary(library model.LibraryTable, full bool, jobID uint) (*IndexSummary, error) {
+	summary := &IndexSummary{LibraryID: library.ID, Path: library.ImgPath}
+
+	files, err := utils.FileUtils.GetFilteredFiles(library.ImgPath, true, config.CONFIG.ScanFileTypes())
+	if err != nil {
+		return summary, fmt.Errorf("指定路径 %v 文件获取失败: %w", library.ImgPath, err)
+	}
+	summary.Total = len(files.SupportedFiles)
+
+	// 已有记录，按路径索引
+	states, err := ix.photoRepo.GetFileStatesByLibrary(library.ID)
+	if err != nil {
+		return summary, err
+	}
+	known := make(map[string]*model.Photo, len(states))
+	for i := range states {
+		known[states[i].Path] = &states[i]
+	}
+
+	seen := make(map[string]bool, len(files.SupportedFiles))
+	// 需要处理的文件按目录分组，同一目录的 EXIF 批量读取
+	var dirs []string
+	queued := make(map[string][]string)
+	for _, file := range files.SupportedFiles {
+		seen[file.Path] = true
+		if photo, ok := known[file.Path]; ok && !full && photo.Unchanged(file.Size, file.ModTime) {
+			summary.Skipped++
+			continue
+		}
+		dir := filepath.Dir(file.Path)
+		if _, ok := queued[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		queued[dir] = append(queued[dir], file.Path)
+	}
+
+	opts := TaskOptions{JobID: jobID, LibraryID: library.ID, Force: full}
+	for _, dir := range dirs {
+		if ix.taskManager.JobCanceled(jobID) {
+			// 比对已完成，缺失判断不受影响
+			break
+		}
+		records, err := ix.taskManager.SaveTasks(queued[dir], opts)
+		if err != nil {
+			logger.Error("任务保存失败!", zap.String("dir", dir), zap.Error(err))
+		}
+		summary.tasks = append(summary.tasks, records...)
+		summary.Queued += len(records)
+	}
+
+	// 记录存在但文件已不存在
+	var missing []uint
+	for path, photo := range known {
+		if !seen[path] && !photo.Missing {
+			missing = append(missing, photo.ID)
+		}
+	}
+	if err := ix.photoRepo.MarkMissing(missing); err != nil {
+		return summary, err
+	}
+	summary.Missing = len(missing)
+
+	logger.Info("存储库索引完成",
+		zap.String("path", library.ImgPath),
+		zap.Int("total", summary.Total),
+		zap.Int("queued", summary.Queued),
+		zap.Int("skipped", summary.Skipped),
+		zap.Int("missing", summary.Missing),
+	)
+	return summary, nil
+}
+
+// FileChanged 监听到文件创建或修改，加入处理队列
+func (ix *Indexer) FileChanged(libraryID uint, path string) {
+	logger.Info("监听到文件变化", zap.String("path", path))
+	ix.taskManager.AddTask(path, TaskOptions{LibraryID: libraryID})
+}
+
+// FileRemoved 监听到文件或目录被删除、移走，对应记录标记为缺失
+// 移动到其他位置的文件会在新路径的任务中通过 Hash 恢复
+func (ix *Indexer) FileRemoved(libraryID uint, path string) {
+	logger.Info("监听到文件删除", zap.String("path", path))
+	if err := ix.photoRepo.MarkMissingByPath(path); err != nil {
+		logger.Error("缺失标记失败!", zap.String("path", path), zap.Error(err))
+	}
+}
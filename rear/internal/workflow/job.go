@@ -0,0 +1,304 @@
+package workflow
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"rear/internal/model"
+	"rear/internal/repositories"
+	"rear/pkg/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 索引模式
+const (
+	IndexModeIncremental = "incremental"
+	IndexModeFull        = "full"
+)
+
+// JobProgress 索引任务进度
+type JobProgress struct {
+	*model.IndexJob
+	// 剩余文件数
+	Remaining int `json:"remaining"`
+	// 完成百分比 0-100
+	Percent float64 `json:"percent"`
+	// 预计剩余秒数，无法估算时为 -1
+	ETASeconds int64 `json:"eta_seconds"`
+	// 文件错误【分页】
+	Errors     []model.IndexJobError `json:"errors"`
+	ErrorTotal int64                 `json:"error_total"`
+	Page       int                   `json:"page"`
+	PageSize   int                   `json:"page_size"`
+}
+
+// JobManager 索引任务管理，负责任务计数和持久化
+type JobManager struct {
+	jobRepo     *repositories.JobRepository
+	taskManager *ImgTaskManager
+	events      *EventBus
+}
+
+var ErrJobNotFound = errors.New("job not found")
+
+func NewJobManager(jobRepo *repositories.JobRepository, taskManager *ImgTaskManager, events *EventBus) *JobManager {
+	return &JobManager{jobRepo: jobRepo, taskManager: taskManager, events: events}
+}
+
+// CreateJob 创建索引任务，创建后处于扫描状态
+func (jm *JobManager) CreateJob(mode string) (*model.IndexJob, error) {
+	job := &model.IndexJob{
+		Status:    model.JobStatusScanning,
+		Mode:      mode,
+		StartedAt: time.Now(),
+	}
+	if err := jm.jobRepo.CreateJob(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// FinishScan 扫描结束，写入文件总数并进入处理阶段
+// 扫描期间已完成的文件通过累加计数保留
+func (jm *JobManager) FinishScan(jobID uint, summaries []*IndexSummary) {
+	var total, queued, skipped, missing int
+	for _, summary := range summaries {
+		total += summary.Total
+		queued += summary.Queued
+		skipped += summary.Skipped
+		missing += summary.Missing
+	}
+
+	err := jm.jobRepo.UpdateJob(jobID, map[string]interface{}{
+		"total":   total,
+		"queued":  queued,
+		"skipped": gorm.Expr("skipped + ?", skipped),
+		"missing": missing,
+	})
+	if err == nil {
+		// 扫描期间被取消的任务保持取消状态
+		_, err = jm.jobRepo.UpdateJobIf(jobID, []string{model.JobStatusScanning}, map[string]interface{}{
+			"status": model.JobStatusRunning,
+		})
+	}
+	if err != nil {
+		logger.Error("索引任务更新失败!", zap.Uint("job", jobID), zap.Error(err))
+		return
+	}
+	jm.finishIfComplete(jobID)
+}
+
+// FailJob 任务整体失败
+func (jm *JobManager) FailJob(jobID uint, message string) {
+	err := jm.jobRepo.UpdateJob(jobID, map[string]interface{}{
+		"status":      model.JobStatusFailed,
+		"message":     message,
+		"finished_at": time.Now(),
+	})
+	if err != nil {
+		logger.Error("索引任务更新失败!", zap.Uint("job", jobID), zap.Error(err))
+	}
+	jm.publish(jobID)
+}
+
+// RecordError 记录文件错误
+func (jm *JobManager) RecordError(jobID uint, path string, message string) {
+	err := jm.jobRepo.AddError(&model.IndexJobError{
+		JobID: jobID,
+		Path:  path,
+		Error: message,
+	})
+	if err != nil {
+		logger.Error("索引错误记录失败!", zap.Uint("job", jobID), zap.Error(err))
+	}
+}
+
+// TaskFinished 单个文件处理结束后更新任务计数
+func (jm *JobManager) TaskFinished(task *PictureTask) {
+	if task.JobID == 0 {
+		return
+	}
+
+	info := task.Info()
+	var column string
+	switch info.Status {
+	case StatusDone:
+		column = "done"
+	case StatusSkipped:
+		column = "skipped"
+	case StatusFailed:
+		column = "failed"
+		jm.RecordError(task.JobID, task.Path, info.Error)
+	case StatusCanceled:
+		column = "canceled"
+	default:
+		return
+	}
+
+	if err := jm.jobRepo.IncrementCounter(task.JobID, column); err != nil {
+		logger.Error("索引任务计数失败!", zap.Uint("job", task.JobID), zap.Error(err))
+		return
+	}
+	jm.finishIfComplete(task.JobID)
+}
+
+// PauseJob 暂停索引任务，扫描会继续但新加入的文件任务处于暂停状态
+func (jm *JobManager) PauseJob(jobID uint) error {
+	if err := jm.setPaused(jobID, true); err != nil {
+		return err
+	}
+	jm.taskManager.PauseJob(jobID)
+	jm.publish(jobID)
+	return nil
+}
+
+// ResumeJob 恢复暂停的索引任务
+func (jm *JobManager) ResumeJob(jobID uint) error {
+	if err := jm.setPaused(jobID, false); err != nil {
+		return err
+	}
+	jm.taskManager.ResumeJob(jobID)
+	jm.publish(jobID)
+	return nil
+}
+
+// CancelJob 取消索引任务，未开始的文件任务丢弃，执行中的立即终止
+func (jm *JobManager) CancelJob(jobID uint) error {
+	ok, err := jm.jobRepo.UpdateJobIf(jobID, []string{model.JobStatusScanning, model.JobStatusRunning}, map[string]interface{}{
+		"status":      model.JobStatusCanceled,
+		"paused":      false,
+		"finished_at": time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return jm.transitionError(jobID)
+	}
+	jm.taskManager.CancelJob(jobID)
+	jm.publish(jobID)
+	return nil
+}
+
+// RestorePaused 启动时恢复索引任务的暂停状态，需在恢复文件任务之前调用
+func (jm *JobManager) RestorePaused() error {
+	jobs, err := jm.jobRepo.GetPausedJobs()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		jm.taskManager.PauseJob(job.ID)
+	}
+	return nil
+}
+
+func (jm *JobManager) setPaused(jobID uint, paused bool) error {
+	ok, err := jm.jobRepo.UpdateJobIf(jobID, []string{model.JobStatusScanning, model.JobStatusRunning}, map[string]interface{}{
+		"paused": paused,
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return jm.transitionError(jobID)
+	}
+	return nil
+}
+
+// transitionError 更新未生效时区分任务不存在和任务已结束
+func (jm *JobManager) transitionError(jobID uint) error {
+	job, err := jm.jobRepo.GetJobByID(jobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return ErrJobNotFound
+	}
+	return ErrInvalidTransition
+}
+
+// RecoverInterrupted 启动时将上次未结束的任务标记为中断
+// resumed 为仍有文件任务待恢复的索引任务，保持处理中状态继续计数；扫描未完成的任务总数未知，始终标记为中断
+func (jm *JobManager) RecoverInterrupted(resumed []uint) error {
+	return jm.jobRepo.MarkInterrupted(resumed)
+}
+
+// GetProgress 获取任务进度及分页的文件错误
+func (jm *JobManager) GetProgress(jobID uint, page, pageSize int) (*JobProgress, error) {
+	job, err := jm.jobRepo.GetJobByID(jobID)
+	if err != nil || job == nil {
+		return nil, err
+	}
+
+	jobErrors, errorTotal, err := jm.jobRepo.GetErrorsPaginated(jobID, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := newJobProgress(job, time.Now())
+	progress.Errors = jobErrors
+	progress.ErrorTotal = errorTotal
+	progress.Page = page
+	progress.PageSize = pageSize
+	return progress, nil
+}
+
+// ListJobs 分页获取任务历史
+func (jm *JobManager) ListJobs(page, pageSize int) ([]*JobProgress, int64, error) {
+	jobs, total, err := jm.jobRepo.GetJobsPaginated((page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	result := make([]*JobProgress, 0, len(jobs))
+	for i := range jobs {
+		result = append(result, newJobProgress(&jobs[i], now))
+	}
+	return result, total, nil
+}
+
+func (jm *JobManager) finishIfComplete(jobID uint) {
+	if err := jm.jobRepo.FinishIfComplete(jobID); err != nil {
+		logger.Error("索引任务更新失败!", zap.Uint("job", jobID), zap.Error(err))
+	}
+	jm.publish(jobID)
+}
+
+// publish 推送任务最新计数【没有订阅方时不查询】
+func (jm *JobManager) publish(jobID uint) {
+	if !jm.events.HasSubscribers() {
+		return
+	}
+	job, err := jm.jobRepo.GetJobByID(jobID)
+	if err != nil || job == nil {
+		return
+	}
+	jm.events.Publish(EventJob, newJobProgress(job, time.Now()))
+}
+
+// newJobProgress 计算进度和预计剩余时间
+func newJobProgress(job *model.IndexJob, now time.Time) *JobProgress {
+	progress := &JobProgress{IndexJob: job, ETASeconds: -1}
+
+	processed := job.Processed()
+	progress.Remaining = max(job.Total-processed, 0)
+	if job.Total > 0 {
+		progress.Percent = float64(processed) / float64(job.Total) * 100
+	}
+
+	switch job.Status {
+	case model.JobStatusDone:
+		progress.ETASeconds = 0
+	case model.JobStatusRunning:
+		// 扫描阶段跳过的文件不耗时，只按队列中已处理的文件估算速度
+		queuedProcessed := processed - (job.Total - job.Queued)
+		elapsed := now.Sub(job.StartedAt)
+		if queuedProcessed > 0 && elapsed > 0 {
+			perFile := elapsed / time.Duration(queuedProcessed)
+			progress.ETASeconds = int64((perFile * time.Duration(progress.Remaining)).Seconds())
+		}
+	}
+	return progress
+}
@@ -0,0 +1,33 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"rear/internal/config"
+	"rear/internal/utils/tools"
+)
+
+// pngTempDir 中间 PNG 文件的临时目录
+func pngTempDir() string {
+	return filepath.Join(config.CONFIG.AppDir, config.CONFIG.PathConfig.TempPath, config.CONFIG.PathConfig.PngTempPath)
+}
+
+// preparePngSource 将 HEIC、AVIF、JPEG XL 等格式解码为临时 PNG 作为缩略图源
+// 只解码一次，各尺寸的缩略图都从 PNG 生成；按 EXIF 方向旋转后去除元数据，PNG 即为显示方向
+// name 为临时文件名前缀，返回的 cleanup 用于删除临时文件，出错时为 nil
+func preparePngSource(ctx context.Context, processors *tools.ImageProcessors, path, name string) (string, func(), error) {
+	output := filepath.Join(pngTempDir(), name+".png")
+	err := processors.Process(ctx, path, output, &tools.ProcessOptions{
+		Format:     "png",
+		Strip:      true,
+		AutoRotate: true,
+	})
+	if err != nil {
+		// 取消或失败时后端可能已写入部分内容
+		_ = os.Remove(output)
+		return "", nil, fmt.Errorf("decode %s to png failed: %w", filepath.Ext(path), err)
+	}
+	return output, func() { _ = os.Remove(output) }, nil
+}
@@ -0,0 +1,61 @@
+package workflow
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"rear/internal/config"
+	"rear/internal/utils/tools"
+	"testing"
+)
+
+func TestPreparePngSource(t *testing.T) {
+	dir := t.TempDir()
+	config.CONFIG.AppDir = dir
+	config.CONFIG.PathConfig.TempPath = "app-tmp"
+	config.CONFIG.PathConfig.PngTempPath = "png-tmp"
+
+	// 没有外部工具，使用内置后端
+	processors := tools.NewImageProcessors(tools.NewFakeRunner())
+
+	input := filepath.Join(dir, "a.gif")
+	file, err := os.Create(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gif.Encode(file, image.NewPaletted(image.Rect(0, 0, 8, 6), []color.Color{color.White}), nil)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, cleanup, err := preparePngSource(context.Background(), processors, input, "task")
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if filepath.Dir(source) != pngTempDir() || filepath.Ext(source) != ".png" {
+		t.Fatalf("source = %s", source)
+	}
+	if _, err := os.Stat(source); err != nil {
+		t.Fatalf("png not written: %v", err)
+	}
+	cleanup()
+	if _, err := os.Stat(source); !os.IsNotExist(err) {
+		t.Fatalf("png not removed: %v", err)
+	}
+
+	// 解码失败时不留下临时文件
+	broken := filepath.Join(dir, "b.gif")
+	if err := os.WriteFile(broken, []byte("GIF89a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := preparePngSource(context.Background(), processors, broken, "broken"); err == nil {
+		t.Fatal("broken gif: want error")
+	}
+	if _, err := os.Stat(filepath.Join(pngTempDir(), "broken.png")); !os.IsNotExist(err) {
+		t.Fatalf("partial png left: %v", err)
+	}
+}
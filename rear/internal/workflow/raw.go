@@ -0,0 +1,63 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"rear/internal/config"
+	toolutils "rear/internal/utils"
+	"rear/internal/utils/tools"
+	"rear/pkg/logger"
+	"strconv"
+)
+
+// rawTempDir RAW 预览和解码结果的临时目录
+func rawTempDir() string {
+	return filepath.Join(config.CONFIG.AppDir, config.CONFIG.PathConfig.TempPath, config.CONFIG.PathConfig.RawTempPath)
+}
+
+// prepareRawSource 为 RAW 文件准备缩略图源：优先使用内嵌预览，预览过小、不存在或提取失败时使用配置的解码命令
+// name 为临时文件名前缀，返回的 cleanup 用于删除临时文件，出错时为 nil
+func prepareRawSource(ctx context.Context, kit *tools.Toolkit, path, name string, orientation int) (string, func(), error) {
+	_, err := kit.Runner.Path(toolutils.ToolRawConverter)
+	hasConverter := err == nil
+	base := filepath.Join(rawTempDir(), name)
+
+	preview := base + ".preview.jpg"
+	info, err := tools.ExtractRawPreview(ctx, kit.Runner, path, preview)
+	if err == nil {
+		if max(info.Width, info.Height) >= config.CONFIG.RawConfig.MinPreviewSize || !hasConverter {
+			// 内嵌预览没有方向信息，使用 RAW 文件的方向以便缩略图自动旋转
+			if orientation > 1 {
+				err := tools.SetExifField(ctx, kit.Runner, preview, map[string]string{"Orientation#": strconv.Itoa(orientation)})
+				if err != nil {
+					_ = os.Remove(preview)
+					return "", nil, err
+				}
+			}
+			return preview, func() { _ = os.Remove(preview) }, nil
+		}
+		logger.Info("RAW 内嵌预览过小，使用解码命令",
+			zap.String("path", path),
+			zap.String("tag", info.Tag),
+			zap.Int("width", info.Width),
+			zap.Int("height", info.Height),
+		)
+		_ = os.Remove(preview)
+	} else if !hasConverter {
+		return "", nil, fmt.Errorf("extract raw preview failed: %w", err)
+	} else if !errors.Is(err, tools.ErrNoRawPreview) {
+		// exiftool 不可用、崩溃或超时时同样改用解码命令
+		logger.Warn("RAW 内嵌预览提取失败，使用解码命令", zap.String("path", path), zap.Error(err))
+	}
+
+	// 解码命令输出的图像已按方向旋转
+	output, err := tools.ConvertRaw(ctx, kit.Runner, path, base+".converted")
+	if err != nil {
+		return "", nil, err
+	}
+	return output, func() { _ = os.Remove(output) }, nil
+}
@@ -0,0 +1,141 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"rear/internal/config"
+	toolutils "rear/internal/utils"
+	"rear/internal/utils/tools"
+	"strings"
+	"testing"
+	"time"
+)
+
+const fakePreviewTags = `[{"SourceFile": "a.nef",
+	"JpgFromRaw": "(Binary data 99999 bytes, use -b option to extract)",
+	"ThumbnailImage": "(Binary data 1234 bytes, use -b option to extract)"}]`
+
+func setupRawTest(t *testing.T, minPreviewSize int) {
+	t.Helper()
+	config.CONFIG.AppDir = t.TempDir()
+	config.CONFIG.PathConfig.TempPath = "app-tmp"
+	config.CONFIG.PathConfig.RawTempPath = "raw-tmp"
+	config.CONFIG.RawConfig.MinPreviewSize = minPreviewSize
+}
+
+// fakeRawExifTool 模拟 exiftool：RAW 中有 100x80 的 JpgFromRaw 预览
+func fakeRawExifTool(t *testing.T) *tools.FakeRunner {
+	t.Helper()
+	var preview bytes.Buffer
+	if err := jpeg.Encode(&preview, image.NewRGBA(image.Rect(0, 0, 100, 80)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return tools.NewFakeRunner().
+		On(toolutils.ToolExifTool, "-j", tools.FakeResponse{Stdout: []byte(fakePreviewTags)}).
+		On(toolutils.ToolExifTool, "-b -JpgFromRaw", tools.FakeResponse{Stdout: preview.Bytes()}).
+		On(toolutils.ToolExifTool, "-Orientation#=", tools.FakeResponse{})
+}
+
+func rawTempFiles(t *testing.T) []string {
+	t.Helper()
+	entries, err := os.ReadDir(rawTempDir())
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestPrepareRawSourcePreview(t *testing.T) {
+	setupRawTest(t, 64)
+	runner := fakeRawExifTool(t)
+	kit := tools.NewToolkit(runner)
+
+	source, cleanup, err := prepareRawSource(context.Background(), kit, "a.nef", "task", 6)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if !strings.HasSuffix(source, ".preview.jpg") {
+		t.Fatalf("source = %s, want preview", source)
+	}
+	// 预览写入 RAW 的方向
+	calls := runner.Calls(toolutils.ToolExifTool)
+	if last := calls[len(calls)-1]; !strings.Contains(strings.Join(last.Args, " "), "-Orientation#=6") {
+		t.Fatalf("last exiftool call = %v, want orientation", last.Args)
+	}
+	cleanup()
+	if files := rawTempFiles(t); len(files) != 0 {
+		t.Fatalf("temp files left: %v", files)
+	}
+}
+
+func TestPrepareRawSourceConverter(t *testing.T) {
+	// 预览小于 1000 时使用解码命令
+	setupRawTest(t, 1000)
+	runner := fakeRawExifTool(t).
+		SetPath(toolutils.ToolRawConverter, "/usr/bin/dcraw").
+		On(toolutils.ToolRawConverter, "", tools.FakeResponse{Stdout: []byte("II*\x00")})
+	kit := tools.NewToolkit(runner)
+
+	source, cleanup, err := prepareRawSource(context.Background(), kit, "a.nef", "task", 6)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	defer cleanup()
+	if filepath.Ext(source) != ".tiff" {
+		t.Fatalf("source = %s, want dcraw tiff", source)
+	}
+	if calls := runner.Calls(toolutils.ToolRawConverter); len(calls) != 1 || calls[0].Args[len(calls[0].Args)-1] != "a.nef" {
+		t.Fatalf("converter calls = %v", calls)
+	}
+	if files := rawTempFiles(t); len(files) != 1 {
+		t.Fatalf("temp files = %v, want only the converted file", files)
+	}
+}
+
+func TestPrepareRawSourceFailure(t *testing.T) {
+	setupRawTest(t, 1000)
+
+	// 解码命令超时，不留下临时文件
+	runner := fakeRawExifTool(t).
+		SetPath(toolutils.ToolRawConverter, "/usr/bin/dcraw").
+		On(toolutils.ToolRawConverter, "", tools.FakeResponse{Delay: 10 * time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := prepareRawSource(ctx, tools.NewToolkit(runner), "a.nef", "task", 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("slow converter: got %v, want DeadlineExceeded", err)
+	}
+	if files := rawTempFiles(t); len(files) != 0 {
+		t.Fatalf("temp files left: %v", files)
+	}
+
+	// exiftool 执行失败且没有解码命令
+	runner = tools.NewFakeRunner().
+		On(toolutils.ToolExifTool, "", tools.FakeResponse{ExitCode: 1, Stderr: []byte("Error: File format error")})
+	_, _, err = prepareRawSource(context.Background(), tools.NewToolkit(runner), "a.nef", "task", 1)
+	var cmdErr *toolutils.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.ExitCode != 1 {
+		t.Fatalf("exiftool failure: got %v, want CommandError", err)
+	}
+
+	// exiftool 执行失败时改用解码命令
+	runner.SetPath(toolutils.ToolRawConverter, "/usr/bin/dcraw").
+		On(toolutils.ToolRawConverter, "", tools.FakeResponse{Stdout: []byte("II*\x00")})
+	source, cleanup, err := prepareRawSource(context.Background(), tools.NewToolkit(runner), "a.nef", "task", 1)
+	if err != nil {
+		t.Fatalf("exiftool failure with converter: %v", err)
+	}
+	defer cleanup()
+	if filepath.Ext(source) != ".tiff" {
+		t.Fatalf("source = %s, want dcraw tiff", source)
+	}
+}
@@ -0,0 +1,151 @@
+package workflow
+
+import (
+	"go.uber.org/zap"
+	"math/rand/v2"
+	"rear/internal/model"
+	"rear/pkg/logger"
+	"time"
+)
+
+// retryLater 临时错误按指数退避重新执行，返回是否已安排重试
+// 重试的任务沿用原任务 ID，等待期间记录为排队状态，进程重启后立即恢复执行
+func (tm *ImgTaskManager) retryLater(t *PictureTask) bool {
+	info := t.Info()
+	if info.Status != StatusFailed || info.ErrorClass != ErrorClassTransient {
+		return false
+	}
+	attempts := info.Attempts + 1
+	if attempts >= tm.retry.MaxAttempts {
+		return false
+	}
+
+	err := tm.taskRepo.UpdateTask(t.ID, map[string]interface{}{
+		"status":      string(StatusPending),
+		"error":       info.Error,
+		"error_class": string(info.ErrorClass),
+		"attempts":    attempts,
+	})
+	if err != nil {
+		logger.Error("任务状态保存失败!", zap.String("task", t.ID), zap.Error(err))
+	}
+
+	delay := retryDelay(tm.retry.BaseDelay, tm.retry.MaxDelay, attempts)
+	logger.Warn("任务临时失败，稍后重试",
+		zap.String("path", t.Path),
+		zap.Int("attempts", attempts),
+		zap.Duration("delay", delay),
+		zap.String("error", info.Error),
+	)
+
+	retry := tm.newTask(t.Path, TaskOptions{JobID: t.JobID, LibraryID: t.LibraryID, Force: t.Force})
+	retry.ID = t.ID
+	retry.Attempts = attempts
+	// 持有锁时创建定时器，回调在登记之后才能执行
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.stopped() {
+		// 已关闭，记录保持排队状态，下次启动时恢复
+		return true
+	}
+	tm.retryTimers[retry.ID] = time.AfterFunc(delay, func() {
+		tm.mu.Lock()
+		delete(tm.retryTimers, retry.ID)
+		stopped := tm.stopped()
+		tm.mu.Unlock()
+		if stopped {
+			return
+		}
+		tm.enqueue(retry)
+	})
+	return true
+}
+
+// stopRetryTimers 关闭时停止等待中的重试，记录保持排队状态，下次启动时恢复
+func (tm *ImgTaskManager) stopRetryTimers() {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for id, timer := range tm.retryTimers {
+		timer.Stop()
+		delete(tm.retryTimers, id)
+	}
+}
+
+// retryDelay 第 attempts 次重试前的等待时间：base * 2^(attempts-1)，加上最多 20% 的随机抖动，不超过 maxDelay
+func retryDelay(base, maxDelay time.Duration, attempts int) time.Duration {
+	if base <= 0 {
+		base = time.Second
+	}
+	delay := base
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+}
+
+// DeadTasks 死信列表：永久失败或重试次数用尽的任务
+type DeadTasks struct {
+	Items    []model.PictureTaskRecord `json:"items"`
+	Total    int64                     `json:"total"`
+	Page     int                       `json:"page"`
+	PageSize int                       `json:"page_size"`
+}
+
+// ListDeadTasks 分页获取死信列表，errorClass 为空时不过滤
+func (tm *ImgTaskManager) ListDeadTasks(errorClass string, page, pageSize int) (*DeadTasks, error) {
+	items, total, err := tm.taskRepo.GetFailedTasksPaginated(errorClass, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return &DeadTasks{Items: items, Total: total, Page: page, PageSize: pageSize}, nil
+}
+
+// RetryDeadTask 重新执行死信列表中的任务
+func (tm *ImgTaskManager) RetryDeadTask(taskID string) error {
+	record, err := tm.taskRepo.GetTaskByTaskID(taskID)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return ErrTaskNotFound
+	}
+	if record.Status != string(StatusFailed) {
+		return ErrInvalidTransition
+	}
+	return tm.retryRecord(record)
+}
+
+// RetryDeadTasks 重新执行死信列表中的所有任务，errorClass 为空时不过滤，返回重试的任务数
+func (tm *ImgTaskManager) RetryDeadTasks(errorClass string) (int, error) {
+	records, err := tm.taskRepo.GetFailedTasks(errorClass)
+	if err != nil {
+		return 0, err
+	}
+	for i := range records {
+		if err := tm.retryRecord(&records[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(records), nil
+}
+
+// retryRecord 重置执行次数后重新入队
+// 原索引任务已计入失败，重试的任务不再属于该索引任务，避免重复计数
+func (tm *ImgTaskManager) retryRecord(record *model.PictureTaskRecord) error {
+	err := tm.taskRepo.UpdateTask(record.TaskID, map[string]interface{}{
+		"status":   string(StatusPending),
+		"job_id":   0,
+		"attempts": 0,
+	})
+	if err != nil {
+		return err
+	}
+
+	task := tm.newTask(record.Path, TaskOptions{LibraryID: record.LibraryID, Force: true})
+	task.ID = record.TaskID
+	go tm.enqueue(task)
+	return nil
+}
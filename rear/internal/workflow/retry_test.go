@@ -0,0 +1,69 @@
+package workflow
+
+import (
+	"context"
+	"rear/internal/config"
+	"rear/internal/model"
+	"rear/internal/repositories"
+	"rear/internal/utils/tools"
+	"testing"
+	"time"
+)
+
+// 关闭后等待中的重试不再入队，记录保持排队状态供下次启动恢复
+func TestRetryLaterStopsOnShutdown(t *testing.T) {
+	openTestDB(t)
+	taskRepo := repositories.NewTaskRepository()
+	tm := NewImgTaskManager(config.ConcurrencyConfig{Initial: 1, Min: 1, Max: 1},
+		config.RetryConfig{MaxAttempts: 3, BaseDelay: 20 * time.Millisecond, MaxDelay: 20 * time.Millisecond},
+		repositories.NewPhotoRepository(), repositories.NewThumbnailRepository(), taskRepo, repositories.NewToolCacheRepository(),
+		NewEventBus(), tools.NewToolkit(tools.NewFakeRunner()))
+
+	opts := TaskOptions{JobID: 1, LibraryID: 1}
+	records, err := tm.SaveTasks([]string{"/photos/a.jpg", "/photos/b.jpg"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := func(record model.PictureTaskRecord) *PictureTask {
+		task := tm.newTask(record.Path, opts)
+		task.ID = record.TaskID
+		task.setStatus(StatusRunning)
+		task.setError(context.DeadlineExceeded)
+		return task
+	}
+	first, second := failed(records[0]), failed(records[1])
+
+	if !tm.retryLater(first) {
+		t.Fatal("transient failure not retried")
+	}
+	if err := tm.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 关闭后安排的重试同样只更新记录
+	if !tm.retryLater(second) {
+		t.Fatal("transient failure not retried after shutdown")
+	}
+	tm.mu.RLock()
+	timers := len(tm.retryTimers)
+	tm.mu.RUnlock()
+	if timers != 0 {
+		t.Fatalf("retry timers = %d after shutdown, want 0", timers)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	for _, record := range records {
+		tm.mu.RLock()
+		_, queued := tm.tasks[record.TaskID]
+		tm.mu.RUnlock()
+		if queued {
+			t.Errorf("task %s enqueued after shutdown", record.Path)
+		}
+		saved, err := taskRepo.GetTaskByTaskID(record.TaskID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.Status != string(StatusPending) || saved.Attempts != 1 {
+			t.Errorf("task %s status = %s, attempts = %d, want pending and 1", record.Path, saved.Status, saved.Attempts)
+		}
+	}
+}
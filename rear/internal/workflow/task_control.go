@@ -0,0 +1,170 @@
+package workflow
+
+// PauseTask 暂停单个任务
+// 执行中的任务在当前步骤结束后暂停并归还并发名额，恢复后重新等待名额
+func (tm *ImgTaskManager) PauseTask(id string) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	task, ok := tm.tasks[id]
+	if !ok {
+		return ErrTaskNotFound
+	}
+	return task.Pause()
+}
+
+// ResumeTask 恢复单个任务
+func (tm *ImgTaskManager) ResumeTask(id string) error {
+	tm.mu.Lock()
+	task, ok := tm.tasks[id]
+	if !ok {
+		tm.mu.Unlock()
+		return ErrTaskNotFound
+	}
+	if err := task.Resume(); err != nil {
+		tm.mu.Unlock()
+		return err
+	}
+	resumed := tm.unparkLocked(task)
+	tm.mu.Unlock()
+
+	tm.requeue(resumed)
+	return nil
+}
+
+// CancelTask 取消单个任务
+func (tm *ImgTaskManager) CancelTask(id string) error {
+	tm.mu.Lock()
+	task, ok := tm.tasks[id]
+	if !ok {
+		tm.mu.Unlock()
+		return ErrTaskNotFound
+	}
+	if err := task.Cancel(); err != nil {
+		tm.mu.Unlock()
+		return err
+	}
+	canceled := tm.unparkLocked(task)
+	tm.mu.Unlock()
+
+	// 暂存中的任务不会再经过执行队列，在这里结束；其余的由执行队列结束
+	for _, t := range canceled {
+		tm.finish(t)
+	}
+	return nil
+}
+
+// PauseJob 暂停索引任务下的所有文件任务，之后加入的同样暂停，返回暂停的任务数
+func (tm *ImgTaskManager) PauseJob(jobID uint) int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.pausedJobs[jobID] = true
+	return tm.pauseLocked(func(t *PictureTask) bool { return t.JobID == jobID })
+}
+
+// ResumeJob 恢复索引任务下暂停的文件任务，返回恢复的任务数
+func (tm *ImgTaskManager) ResumeJob(jobID uint) int {
+	tm.mu.Lock()
+	delete(tm.pausedJobs, jobID)
+	resumed, count := tm.resumeLocked(func(t *PictureTask) bool { return t.JobID == jobID })
+	tm.mu.Unlock()
+
+	tm.requeue(resumed)
+	return count
+}
+
+// CancelJob 取消索引任务下的所有文件任务，之后加入的直接丢弃，返回取消的任务数
+func (tm *ImgTaskManager) CancelJob(jobID uint) int {
+	tm.mu.Lock()
+	tm.canceledJobs[jobID] = true
+	delete(tm.pausedJobs, jobID)
+	var parked []*PictureTask
+	count := 0
+	for _, task := range tm.tasks {
+		if task.JobID != jobID || task.Cancel() != nil {
+			continue
+		}
+		count++
+		parked = append(parked, tm.unparkLocked(task)...)
+	}
+	tm.mu.Unlock()
+
+	for _, t := range parked {
+		tm.finish(t)
+	}
+	return count
+}
+
+// JobCanceled 索引任务是否已取消
+func (tm *ImgTaskManager) JobCanceled(jobID uint) bool {
+	if jobID == 0 {
+		return false
+	}
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.canceledJobs[jobID]
+}
+
+// PauseAll 暂停所有任务，之后加入的任务同样暂停
+func (tm *ImgTaskManager) PauseAll() int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.pausedAll = true
+	return tm.pauseLocked(func(*PictureTask) bool { return true })
+}
+
+// ResumeAll 恢复所有暂停的任务【包括单独暂停的任务和暂停的索引任务】
+func (tm *ImgTaskManager) ResumeAll() int {
+	tm.mu.Lock()
+	tm.pausedAll = false
+	clear(tm.pausedJobs)
+	resumed, count := tm.resumeLocked(func(*PictureTask) bool { return true })
+	tm.mu.Unlock()
+
+	tm.requeue(resumed)
+	return count
+}
+
+func (tm *ImgTaskManager) pauseLocked(match func(*PictureTask) bool) int {
+	count := 0
+	for _, task := range tm.tasks {
+		if match(task) && task.Pause() == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// resumeLocked 恢复匹配的任务，返回需要重新入队的暂存任务和恢复的任务数
+func (tm *ImgTaskManager) resumeLocked(match func(*PictureTask) bool) ([]*PictureTask, int) {
+	var resumed []*PictureTask
+	count := 0
+	for _, task := range tm.tasks {
+		if !match(task) || task.Resume() != nil {
+			continue
+		}
+		count++
+		resumed = append(resumed, tm.unparkLocked(task)...)
+	}
+	return resumed, count
+}
+
+// unparkLocked 将任务移出暂存，返回被移出的任务
+func (tm *ImgTaskManager) unparkLocked(task *PictureTask) []*PictureTask {
+	if _, ok := tm.parked[task.ID]; !ok {
+		return nil
+	}
+	delete(tm.parked, task.ID)
+	return []*PictureTask{task}
+}
+
+// requeue 暂存的任务恢复后重新入队【队列可能已满，在后台进行】
+func (tm *ImgTaskManager) requeue(tasks []*PictureTask) {
+	if len(tasks) == 0 {
+		return
+	}
+	go func() {
+		for _, task := range tasks {
+			tm.push(task)
+		}
+	}()
+}
@@ -0,0 +1,118 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"os/exec"
+	"rear/internal/utils"
+	"strings"
+	"syscall"
+)
+
+// ErrorClass 任务错误分类，决定是否重试
+type ErrorClass string
+
+const (
+	// ErrorClassTransient 临时错误【网络存储 I/O、超时、数据库繁忙】，按指数退避重试
+	ErrorClassTransient ErrorClass = "transient"
+	// ErrorClassCorrupt 文件损坏、格式不支持或文件已不存在，重试无意义
+	ErrorClassCorrupt ErrorClass = "corrupt"
+	// ErrorClassToolMissing 外部工具缺失，安装工具后可从死信列表重试
+	ErrorClassToolMissing ErrorClass = "tool_missing"
+	// ErrorClassUnknown 无法判断的错误，按永久错误处理
+	ErrorClassUnknown ErrorClass = "unknown"
+)
+
+// 临时性的系统错误
+var transientErrnos = []syscall.Errno{
+	syscall.EIO,
+	syscall.EAGAIN,
+	syscall.EBUSY,
+	syscall.EINTR,
+	syscall.ETIMEDOUT,
+	syscall.ECONNRESET,
+	syscall.ECONNABORTED,
+	syscall.ECONNREFUSED,
+	syscall.EHOSTUNREACH,
+	syscall.ENETUNREACH,
+	syscall.ENETDOWN,
+	syscall.ESTALE,
+	syscall.EMFILE,
+	syscall.ENFILE,
+}
+
+// 工具输出中表示文件本身有问题的关键字
+var corruptHints = []string{
+	"corrupt",
+	"truncated",
+	"premature end",
+	"not a known",
+	"unknown file type",
+	"unsupported",
+	"not a valid",
+	"file format error",
+	"no decode delegate",
+}
+
+// 工具输出或数据库错误中表示临时问题的关键字
+var transientHints = []string{
+	"database is locked",
+	"resource temporarily unavailable",
+	"i/o timeout",
+	"input/output error",
+	"stale file handle",
+}
+
+// classifyError 判断错误类型
+func classifyError(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+
+	if errors.Is(err, utils.ErrToolNotFound) || errors.Is(err, exec.ErrNotFound) {
+		return ErrorClassToolMissing
+	}
+	// 工具路径失效时启动进程失败
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) && strings.HasPrefix(pathErr.Op, "fork/exec") {
+		return ErrorClassToolMissing
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTransient
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTransient
+	}
+	for _, errno := range transientErrnos {
+		if errors.Is(err, errno) {
+			return ErrorClassTransient
+		}
+	}
+
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		return ErrorClassCorrupt
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, hint := range transientHints {
+		if strings.Contains(message, hint) {
+			return ErrorClassTransient
+		}
+	}
+	for _, hint := range corruptHints {
+		if strings.Contains(message, hint) {
+			return ErrorClassCorrupt
+		}
+	}
+
+	// 工具正常运行但处理失败，多数是文件本身的问题
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return ErrorClassCorrupt
+	}
+	return ErrorClassUnknown
+}
@@ -0,0 +1,49 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"rear/internal/utils"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	exitErr := &exec.ExitError{}
+
+	cases := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"tool not found", fmt.Errorf("ExifTool %w", utils.ErrToolNotFound), ErrorClassToolMissing},
+		{"tool path gone", &fs.PathError{Op: "fork/exec", Path: "/opt/vips", Err: syscall.ENOENT}, ErrorClassToolMissing},
+		{"timeout", fmt.Errorf("exiftool: %w", context.DeadlineExceeded), ErrorClassTransient},
+		{"network share io", &fs.PathError{Op: "read", Path: "/mnt/nas/a.jpg", Err: syscall.EIO}, ErrorClassTransient},
+		{"sqlite busy", errors.New("database is locked"), ErrorClassTransient},
+		{"file removed", &fs.PathError{Op: "stat", Path: "a.jpg", Err: os.ErrNotExist}, ErrorClassCorrupt},
+		{"truncated jpeg", fmt.Errorf("vips failed: %w, stderr: VipsJpeg: Premature end of JPEG file", exitErr), ErrorClassCorrupt},
+		{"tool rejected file", fmt.Errorf("exiftool failed: %w", exitErr), ErrorClassCorrupt},
+		{"other", errors.New("something odd"), ErrorClassUnknown},
+	}
+	for _, c := range cases {
+		if got := classifyError(c.err); got != c.want {
+			t.Errorf("%s: classifyError(%v) = %s, want %s", c.name, c.err, got, c.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	base, maxDelay := time.Second, 10*time.Second
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: maxDelay} {
+		got := retryDelay(base, maxDelay, attempts)
+		if got < want || got > want+want/5 {
+			t.Errorf("retryDelay(attempts=%d) = %v, want %v plus up to 20%% jitter", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"rear/internal/config"
+	"rear/internal/consts"
+	"rear/internal/model"
+	"rear/internal/utils/tools"
+	"rear/pkg/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ThumbnailDir 缩略图缓存根目录
+func ThumbnailDir() string {
+	return filepath.Join(config.CONFIG.AppDir, config.CONFIG.PathConfig.CachePath, config.CONFIG.PathConfig.ThumbnailPath)
+}
+
+// thumbnailFormat 缩略图格式，没有可用的后端能写入配置的格式时使用 JPEG
+func thumbnailFormat(processors *tools.ImageProcessors) string {
+	format := string(config.CONFIG.ImageCompressionOption.ThumbnailFormat)
+	if !processors.CanWrite(format) {
+		return string(consts.FormatJPG)
+	}
+	return format
+}
+
+// ThumbnailPath 指定内容、尺寸和格式的缩略图路径
+func ThumbnailPath(hash string, size int, format string) string {
+	return utils.HashUtils.HashThumbPath(ThumbnailDir(), hash, strconv.Itoa(size), format)
+}
+
+// ThumbnailSizes 需要生成的缩略图尺寸
+// 大于原图最长边的尺寸会被跳过，原图比所有尺寸都小时只保留最小的一档（不放大）
+func ThumbnailSizes(width, height int) []int {
+	sizes := append([]int(nil), config.CONFIG.ImageCompressionOption.ThumbnailSize...)
+	sort.Ints(sizes)
+
+	longest := max(width, height)
+	var result []int
+	for i, size := range sizes {
+		if size <= longest || i == 0 || longest == 0 {
+			result = append(result, size)
+		}
+	}
+	return result
+}
+
+// scaledSize 计算缩放到指定最长边后的尺寸
+func scaledSize(width, height, size int) (int, int) {
+	longest := max(width, height)
+	if longest == 0 || longest <= size {
+		return width, height
+	}
+	scale := float64(size) / float64(longest)
+	return max(1, int(float64(width)*scale+0.5)), max(1, int(float64(height)*scale+0.5))
+}
+
+// generateThumbnails 生成所有配置尺寸的缩略图
+// source 为可被任一图像处理后端读取的源文件，width/height/orientation 为原图信息
+func generateThumbnails(ctx context.Context, processors *tools.ImageProcessors, source string, hash string, width, height, orientation int) ([]model.Thumbnail, error) {
+	format := thumbnailFormat(processors)
+
+	// 按方向修正后的显示尺寸
+	displayWidth, displayHeight := model.DisplaySize(width, height, orientation)
+
+	var thumbs []model.Thumbnail
+	for _, size := range ThumbnailSizes(displayWidth, displayHeight) {
+		output := ThumbnailPath(hash, size, format)
+
+		// 相同内容已经生成过则直接复用
+		if !utils.FileUtils.Exists(output) {
+			if err := renderThumbnail(ctx, processors, source, output, size, format); err != nil {
+				return thumbs, err
+			}
+		}
+
+		thumbWidth, thumbHeight := scaledSize(displayWidth, displayHeight, size)
+		thumbs = append(thumbs, model.Thumbnail{
+			Hash:   hash,
+			Size:   size,
+			Format: format,
+			Path:   output,
+			Width:  thumbWidth,
+			Height: thumbHeight,
+		})
+	}
+	return thumbs, nil
+}
+
+// partialSeq 临时文件序号，索引任务和按需生成可能同时生成同一缩略图
+var partialSeq atomic.Uint64
+
+// renderThumbnail 生成单个尺寸的缩略图
+// 先写入临时文件再重命名，避免中断后留下不完整的缩略图
+func renderThumbnail(ctx context.Context, processors *tools.ImageProcessors, source, output string, size int, format string) error {
+	ext := filepath.Ext(output)
+	partial := fmt.Sprintf("%s.part%d%s", strings.TrimSuffix(output, ext), partialSeq.Add(1), ext)
+	err := processors.Process(ctx, source, partial, &tools.ProcessOptions{
+		MaxSize:    size,
+		Quality:    config.CONFIG.ImageCompressionOption.ThumbnailQuality,
+		Format:     format,
+		Strip:      true,
+		Optimize:   true,
+		Background: "white",
+		AutoRotate: true,
+		NoEnlarge:  true,
+	})
+	if err == nil {
+		err = os.Rename(partial, output)
+	}
+	if err != nil {
+		_ = os.Remove(partial)
+		return fmt.Errorf("generate %d thumbnail failed: %w", size, err)
+	}
+	return nil
+}
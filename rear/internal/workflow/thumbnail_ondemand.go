@@ -0,0 +1,145 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"rear/internal/config"
+	"rear/internal/model"
+	"rear/internal/repositories"
+	"rear/internal/utils/tools"
+	"rear/pkg/logger"
+	"rear/pkg/utils"
+	"sync"
+	"time"
+)
+
+// ErrSourceMissing 原图已不存在，无法生成缩略图
+var ErrSourceMissing = errors.New("source file missing")
+
+// onDemandTimeout 单个按需缩略图的生成时限
+const onDemandTimeout = 2 * time.Minute
+
+// OnDemandThumbnails 浏览时同步生成尚未生成的缩略图，避免索引未完成时出现空白
+// 并发数受信号量限制，同一缩略图的并发请求只生成一次
+type OnDemandThumbnails struct {
+	tools     *tools.Toolkit
+	thumbRepo *repositories.ThumbnailRepository
+	sem       chan struct{}
+
+	mu sync.Mutex
+	// 缩略图路径 -> 正在进行的生成
+	pending map[string]*pendingThumbnail
+}
+
+type pendingThumbnail struct {
+	done chan struct{}
+	err  error
+}
+
+func NewOnDemandThumbnails(limit int, thumbRepo *repositories.ThumbnailRepository, kit *tools.Toolkit) *OnDemandThumbnails {
+	return &OnDemandThumbnails{
+		tools:     kit,
+		thumbRepo: thumbRepo,
+		sem:       make(chan struct{}, max(limit, 1)),
+		pending:   make(map[string]*pendingThumbnail),
+	}
+}
+
+// Format 当前的缩略图格式
+func (g *OnDemandThumbnails) Format() string {
+	return thumbnailFormat(g.tools.Processors)
+}
+
+// Get 返回照片指定尺寸的缩略图路径，不存在时生成
+func (g *OnDemandThumbnails) Get(ctx context.Context, photo *model.Photo, size int) (string, error) {
+	output := ThumbnailPath(photo.Hash, size, g.Format())
+	if utils.FileUtils.Exists(output) {
+		return output, nil
+	}
+
+	g.mu.Lock()
+	pending, ok := g.pending[output]
+	if !ok {
+		pending = &pendingThumbnail{done: make(chan struct{})}
+		g.pending[output] = pending
+		// 生成不随发起请求取消，否则该请求断开时所有等待者都会失败；由超时限制运行时间
+		genCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), onDemandTimeout)
+		go func() {
+			defer cancel()
+			pending.err = g.generate(genCtx, photo, size, output)
+			g.mu.Lock()
+			delete(g.pending, output)
+			g.mu.Unlock()
+			close(pending.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	// 每个请求按自己的 ctx 停止等待，生成继续进行
+	select {
+	case <-pending.done:
+		return output, pending.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (g *OnDemandThumbnails) generate(ctx context.Context, photo *model.Photo, size int, output string) error {
+	select {
+	case g.sem <- struct{}{}:
+		defer func() { <-g.sem }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	// 等待期间可能已由其他请求或索引任务生成
+	if utils.FileUtils.Exists(output) {
+		return nil
+	}
+	if !utils.FileUtils.Exists(photo.Path) {
+		return ErrSourceMissing
+	}
+
+	// 非常规格式或 raw 先转换，临时文件在返回时删除
+	source := photo.Path
+	name := fmt.Sprintf("thumb-%d-%d", photo.ID, size)
+	var prepared string
+	var cleanup func()
+	var err error
+	switch {
+	case config.CONFIG.IsRawFile(photo.Path):
+		prepared, cleanup, err = prepareRawSource(ctx, g.tools, photo.Path, name, photo.Exif.Orientation)
+	case config.CONFIG.IsSpecialFile(photo.Path):
+		prepared, cleanup, err = preparePngSource(ctx, g.tools.Processors, photo.Path, name)
+	}
+	if err != nil {
+		return fmt.Errorf("prepare thumbnail source failed: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+		source = prepared
+	}
+
+	format := g.Format()
+	if err := renderThumbnail(ctx, g.tools.Processors, source, output, size, format); err != nil {
+		return err
+	}
+
+	displayWidth, displayHeight := model.DisplaySize(photo.Width, photo.Height, photo.Exif.Orientation)
+	thumbWidth, thumbHeight := scaledSize(displayWidth, displayHeight, size)
+	err = g.thumbRepo.SaveThumbnails([]model.Thumbnail{{
+		Hash:   photo.Hash,
+		Size:   size,
+		Format: format,
+		Path:   output,
+		Width:  thumbWidth,
+		Height: thumbHeight,
+	}})
+	if err != nil {
+		// 文件已生成，记录保存失败不影响本次返回
+		logger.Warn("缩略图信息保存失败", zap.String("path", output), zap.Error(err))
+	}
+	logger.Info("已按需生成缩略图", zap.Uint("photo", photo.ID), zap.Int("size", size))
+	return nil
+}
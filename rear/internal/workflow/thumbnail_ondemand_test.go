@@ -0,0 +1,89 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"rear/internal/config"
+	"rear/internal/model"
+	"rear/internal/repositories"
+	"rear/internal/utils/tools"
+	"testing"
+	"time"
+)
+
+// 首个请求取消后生成继续进行，后来的请求仍能拿到缩略图
+func TestOnDemandThumbnailOutlivesCaller(t *testing.T) {
+	openTestDB(t)
+	dir := t.TempDir()
+	config.CONFIG.AppDir = dir
+	config.CONFIG.ImageCompressionOption.ThumbnailFormat = "jpg"
+
+	source := filepath.Join(dir, "a.jpg")
+	file, err := os.Create(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = jpeg.Encode(file, image.NewRGBA(image.Rect(0, 0, 64, 48)), nil)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	photo := &model.Photo{Path: source, Hash: "0123456789abcdef", Width: 64, Height: 48}
+	photo.ID = 1
+
+	// 占满信号量，使生成停在获取信号量处
+	g := NewOnDemandThumbnails(1, repositories.NewThumbnailRepository(), tools.NewToolkit(tools.NewFakeRunner()))
+	g.sem <- struct{}{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := g.Get(ctx, photo, 32)
+		first <- err
+	}()
+	waitUntil(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return len(g.pending) == 1
+	})
+	second := make(chan error, 1)
+	var output string
+	go func() {
+		var err error
+		output, err = g.Get(context.Background(), photo, 32)
+		second <- err
+	}()
+
+	cancel()
+	select {
+	case err := <-first:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("first caller err = %v, want canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("first caller not returned after cancel")
+	}
+	g.mu.Lock()
+	inFlight := len(g.pending)
+	g.mu.Unlock()
+	if inFlight != 1 {
+		t.Fatalf("pending = %d after first caller canceled, want generation to continue", inFlight)
+	}
+
+	<-g.sem
+	select {
+	case err := <-second:
+		if err != nil {
+			t.Fatalf("second caller: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second caller not returned")
+	}
+	if _, err := os.Stat(output); err != nil {
+		t.Fatalf("thumbnail not written: %v", err)
+	}
+}
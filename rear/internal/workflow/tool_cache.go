@@ -0,0 +1,206 @@
+package workflow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"rear/internal/config"
+	"rear/internal/model"
+	"rear/internal/repositories"
+	toolutils "rear/internal/utils"
+	"rear/internal/utils/tools"
+	"rear/pkg/logger"
+	"rear/pkg/utils"
+	"sort"
+	"sync"
+	"time"
+)
+
+// toolCache 按文件内容 Hash 缓存外部工具的处理结果【EXIF 和缩略图】
+// 内容已处理过的文件【复制、重新导入、移动后的再次索引】只需计算 Hash；
+// 缓存以 (Hash, 类型, 参数版本) 为键，工具版本变化时失效，为空时不使用缓存
+type toolCache struct {
+	repo *repositories.ToolCacheRepository
+	kit  *tools.Toolkit
+	// 已清理过期缓存的 (类型, 参数版本, 工具版本)，工具路径切换后按新键再次清理
+	mu     sync.Mutex
+	purged map[string]bool
+	// 首次使用时清理没有照片引用的缓存
+	pruneOnce sync.Once
+}
+
+// cachedThumbnail 缓存的缩略图信息，路径由 Hash、尺寸和格式确定
+type cachedThumbnail struct {
+	Size   int    `json:"size"`
+	Format string `json:"format"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+func newToolCache(repo *repositories.ToolCacheRepository, kit *tools.Toolkit) *toolCache {
+	if repo == nil {
+		return nil
+	}
+	return &toolCache{repo: repo, kit: kit, purged: make(map[string]bool)}
+}
+
+// exifKey EXIF 缓存的参数版本和工具版本
+func (c *toolCache) exifKey() (string, string) {
+	return "-j -n " + c.kit.Metadata.Name(), c.kit.Metadata.Version()
+}
+
+// thumbnailKey 缩略图缓存的参数版本和工具版本
+// 参数版本包含尺寸、质量、格式，RAW 文件还包含预览尺寸下限和解码命令
+func (c *toolCache) thumbnailKey(raw bool) (string, string) {
+	option := config.CONFIG.ImageCompressionOption
+	sizes := append([]int(nil), option.ThumbnailSize...)
+	sort.Ints(sizes)
+	args := fmt.Sprintf("sizes=%v quality=%d format=%s", sizes, option.ThumbnailQuality, thumbnailFormat(c.kit.Processors))
+	if raw {
+		converter, _ := c.kit.Runner.Path(toolutils.ToolRawConverter)
+		args += fmt.Sprintf(" raw=%d,%s", config.CONFIG.RawConfig.MinPreviewSize, converter)
+	}
+	sum := sha256.Sum256([]byte(args))
+	return hex.EncodeToString(sum[:8]), c.kit.Processors.Version()
+}
+
+// orphanCacheAge 没有照片引用的缓存保留时长，避免清理处理中文件刚保存的缓存
+const orphanCacheAge = 24 * time.Hour
+
+// purge 清理同一类型、同一参数版本下工具版本与当前不一致的缓存，每个键只清理一次
+// 工具版本为空【版本检测失败】时无法判断哪些缓存过期，不清理
+func (c *toolCache) purge(tool, argsVersion, toolVersion string) {
+	c.pruneOnce.Do(c.pruneOrphans)
+	if toolVersion == "" {
+		return
+	}
+	key := tool + "\x00" + argsVersion + "\x00" + toolVersion
+	c.mu.Lock()
+	if c.purged[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.purged[key] = true
+	c.mu.Unlock()
+
+	count, err := c.repo.DeleteStale(tool, argsVersion, toolVersion)
+	if err != nil {
+		logger.Warn("清理过期工具缓存失败", zap.String("tool", tool), zap.Error(err))
+		return
+	}
+	if count > 0 {
+		logger.Info("已清理过期工具缓存", zap.String("tool", tool), zap.String("args", argsVersion), zap.Int64("count", count))
+	}
+}
+
+// pruneOrphans 清理没有照片引用的缓存【照片已删除】
+func (c *toolCache) pruneOrphans() {
+	count, err := c.repo.DeleteOrphans(time.Now().Add(-orphanCacheAge))
+	if err != nil {
+		logger.Warn("清理无引用工具缓存失败", zap.Error(err))
+		return
+	}
+	if count > 0 {
+		logger.Info("已清理无引用工具缓存", zap.Int64("count", count))
+	}
+}
+
+// get 读取缓存并解析到 v，未命中或出错时返回 false【缓存出错不影响处理】
+func (c *toolCache) get(hash, tool, argsVersion, toolVersion string, v interface{}) bool {
+	c.purge(tool, argsVersion, toolVersion)
+	cache, err := c.repo.GetCache(hash, tool, argsVersion, toolVersion)
+	if err != nil {
+		logger.Warn("读取工具缓存失败", zap.String("hash", hash), zap.String("tool", tool), zap.Error(err))
+		return false
+	}
+	if cache == nil {
+		return false
+	}
+	if err := json.Unmarshal([]byte(cache.Data), v); err != nil {
+		logger.Warn("工具缓存解析失败", zap.String("hash", hash), zap.String("tool", tool), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+func (c *toolCache) save(hash, tool, argsVersion, toolVersion string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = c.repo.SaveCache(&model.ToolCache{
+			Hash:        hash,
+			Tool:        tool,
+			ArgsVersion: argsVersion,
+			ToolVersion: toolVersion,
+			Data:        string(data),
+		})
+	}
+	if err != nil {
+		logger.Warn("保存工具缓存失败", zap.String("hash", hash), zap.String("tool", tool), zap.Error(err))
+	}
+}
+
+// getExif 获取缓存的 EXIF 数据，并按当前文件补全路径相关字段
+func (c *toolCache) getExif(hash, path string, info os.FileInfo) (tools.ExifData, bool) {
+	if c == nil {
+		return nil, false
+	}
+	args, version := c.exifKey()
+	var data tools.ExifData
+	if !c.get(hash, model.ToolCacheExif, args, version, &data) {
+		return nil, false
+	}
+	return data.WithFileInfo(path, info), true
+}
+
+// saveExif 缓存 EXIF 数据【去掉路径相关字段】
+func (c *toolCache) saveExif(hash string, data tools.ExifData) {
+	if c == nil {
+		return
+	}
+	args, version := c.exifKey()
+	c.save(hash, model.ToolCacheExif, args, version, data.WithoutFileInfo())
+}
+
+// getThumbnails 获取缓存的缩略图，任一缩略图文件已不存在时视为未命中
+func (c *toolCache) getThumbnails(hash string, raw bool) ([]model.Thumbnail, bool) {
+	if c == nil {
+		return nil, false
+	}
+	args, version := c.thumbnailKey(raw)
+	var cached []cachedThumbnail
+	if !c.get(hash, model.ToolCacheThumbnail, args, version, &cached) || len(cached) == 0 {
+		return nil, false
+	}
+	thumbs := make([]model.Thumbnail, 0, len(cached))
+	for _, item := range cached {
+		path := ThumbnailPath(hash, item.Size, item.Format)
+		if !utils.FileUtils.Exists(path) {
+			return nil, false
+		}
+		thumbs = append(thumbs, model.Thumbnail{
+			Hash:   hash,
+			Size:   item.Size,
+			Format: item.Format,
+			Path:   path,
+			Width:  item.Width,
+			Height: item.Height,
+		})
+	}
+	return thumbs, true
+}
+
+// saveThumbnails 缓存生成的缩略图信息
+func (c *toolCache) saveThumbnails(hash string, raw bool, thumbs []model.Thumbnail) {
+	if c == nil || len(thumbs) == 0 {
+		return
+	}
+	args, version := c.thumbnailKey(raw)
+	cached := make([]cachedThumbnail, 0, len(thumbs))
+	for _, thumb := range thumbs {
+		cached = append(cached, cachedThumbnail{Size: thumb.Size, Format: thumb.Format, Width: thumb.Width, Height: thumb.Height})
+	}
+	c.save(hash, model.ToolCacheThumbnail, args, version, cached)
+}
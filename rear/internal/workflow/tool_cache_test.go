@@ -0,0 +1,64 @@
+package workflow
+
+import (
+	"rear/internal/db"
+	"rear/internal/model"
+	"rear/internal/repositories"
+	"rear/internal/utils/tools"
+	"testing"
+	"time"
+)
+
+func TestToolCachePurge(t *testing.T) {
+	openTestDB(t)
+	if err := db.DB.Create(&model.LibraryTable{ImgPath: t.TempDir()}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DB.Create(&model.Photo{LibraryID: 1, Path: "/a.jpg", Hash: "used"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	repo := repositories.NewToolCacheRepository()
+	old := time.Now().Add(-2 * orphanCacheAge)
+	for _, row := range []model.ToolCache{
+		{Hash: "used", Tool: model.ToolCacheExif, ArgsVersion: "exiftool", ToolVersion: "12"},
+		{Hash: "used", Tool: model.ToolCacheExif, ArgsVersion: "go", ToolVersion: "1"},
+		{Hash: "used", Tool: model.ToolCacheThumbnail, ArgsVersion: "raw", ToolVersion: "6"},
+		{Hash: "used", Tool: model.ToolCacheThumbnail, ArgsVersion: "plain", ToolVersion: "6"},
+		{Hash: "deleted", Tool: model.ToolCacheExif, ArgsVersion: "exiftool", ToolVersion: "13", BaseModel: model.BaseModel{UpdatedAt: old}},
+		{Hash: "recent", Tool: model.ToolCacheExif, ArgsVersion: "exiftool", ToolVersion: "13"},
+	} {
+		if err := db.DB.Create(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache := newToolCache(repo, tools.NewToolkit(tools.NewFakeRunner()))
+	// 版本为空时不清理，但首次使用时清理较早的无引用缓存
+	cache.purge(model.ToolCacheExif, "exiftool", "")
+	assertCacheKeys(t, "used/exif/exiftool", "used/exif/go", "used/thumbnail/raw", "used/thumbnail/plain", "recent/exif/exiftool")
+
+	// 只清理同一参数版本下的旧工具版本
+	cache.purge(model.ToolCacheExif, "exiftool", "13")
+	cache.purge(model.ToolCacheThumbnail, "raw", "7")
+	assertCacheKeys(t, "used/exif/go", "used/thumbnail/plain", "recent/exif/exiftool")
+}
+
+func assertCacheKeys(t *testing.T, want ...string) {
+	t.Helper()
+	var rows []model.ToolCache
+	if err := db.DB.Unscoped().Order("id").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		got[row.Hash+"/"+row.Tool+"/"+row.ArgsVersion] = true
+	}
+	if len(got) != len(want) {
+		t.Fatalf("cache keys = %v, want %v", got, want)
+	}
+	for _, key := range want {
+		if !got[key] {
+			t.Fatalf("cache keys = %v, want %v", got, want)
+		}
+	}
+}
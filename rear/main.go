@@ -56,13 +56,7 @@ func main() {
 	// 初始化基础服务（启动写操作处理协程）
 	repositories.InitBaseService()
 
-	// 初始化照片管理任务
-	newTaskContainer := container.NewTaskContainer(newContainer)
-
-	// 创建软件所需的缓存目录等内容
-	createCachePath(config.CONFIG.AppDir)
-
-	// 将 CLI 放到到运行目录
+	// 将 CLI 放到到运行目录【需要在创建工具执行器查找工具之前】
 	join := filepath.Join(config.CONFIG.AppDir, "tools", "exiftool")
 	srcDir := `.\tools\windows_amd64\exiftool\exiftool` // 源目录
 
@@ -79,6 +73,22 @@ func main() {
 		return
 	}
 
+	// 创建软件所需的缓存目录等内容【需要在任务恢复之前清理临时文件】
+	createCachePath(config.CONFIG.AppDir)
+
+	// 初始化照片管理任务
+	newTaskContainer := container.NewTaskContainer(newContainer)
+
+	// 恢复上次运行中未结束的任务
+	if err := newTaskContainer.Recover(); err != nil {
+		logger.Error("任务恢复失败！", zap.Error(err))
+	}
+
+	// 监听已开启的存储库
+	if err := newTaskContainer.SyncWatcher(); err != nil {
+		logger.Error("存储库监听启动失败！", zap.Error(err))
+	}
+
 	//if err := initDatabase(); err != nil {
 	//	logger.Fatalf("Failed to initialize database: %v", err)
 	//}
@@ -98,11 +108,16 @@ func createCachePath(dir string) {
 		return
 	}
 	// 临时文件路径
-	tempPath := filepath.Join(dir, config.CONFIG.PathConfig.TempPath, config.CONFIG.PathConfig.PngTempPath)
-	err = utils.FileUtils.CreateDir(tempPath)
-	if err != nil {
-		logger.Error("临时文件夹创建失败！", zap.String("path", dir), zap.Error(err))
-		return
+	for _, name := range []string{config.CONFIG.PathConfig.PngTempPath, config.CONFIG.PathConfig.RawTempPath} {
+		tempPath := filepath.Join(dir, config.CONFIG.PathConfig.TempPath, name)
+		// 清理上次异常退出时残留的临时文件
+		if err := os.RemoveAll(tempPath); err != nil {
+			logger.Warn("临时文件清理失败！", zap.String("path", tempPath), zap.Error(err))
+		}
+		if err := utils.FileUtils.CreateDir(tempPath); err != nil {
+			logger.Error("临时文件夹创建失败！", zap.String("path", dir), zap.Error(err))
+			return
+		}
 	}
 }
 
@@ -142,6 +157,8 @@ func startHttp(con *container.DbContainer, imgContain *container.TaskContainer)
 		WriteTimeout: config.CONFIG.WriteTimeout,
 		IdleTimeout:  config.CONFIG.IdleTimeout,
 	}
+	// Shutdown 会等待所有连接结束，先关闭进度推送的长连接
+	srv.RegisterOnShutdown(imgContain.Events.Close)
 
 	// 优雅关闭
 	quit := make(chan os.Signal, 1)
@@ -161,12 +178,27 @@ func startHttp(con *container.DbContainer, imgContain *container.TaskContainer)
 	<-quit
 	logger.Info("Shutting down server...")
 
+	// HTTP 和照片任务在同一个 30 秒期限内同时关闭，未完成的任务下次启动时恢复
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	tasksDone := make(chan struct{})
+	go func() {
+		defer close(tasksDone)
+		// 先停止存储库监听，不再产生新任务
+		imgContain.Watcher.Close()
+		if err := imgContain.ImgTaskManager.Shutdown(ctx); err != nil {
+			logger.Errorf("Tasks interrupted on shutdown: %v", err)
+		}
+	}()
+
 	if err := srv.Shutdown(ctx); err != nil {
-		logger.Fatalf("Server forced to shutdown: %v", err)
+		logger.Errorf("Server forced to shutdown: %v", err)
 	}
+	<-tasksDone
+
+	// 任务结束后关闭常驻的 exiftool 进程
+	imgContain.ToolRunner.Close()
 
 	logger.Info("Server exited")
 }
@@ -58,6 +58,15 @@ func (fileUtilsStruct) IsFile(path string) bool {
 	return !info.IsDir()
 }
 
+// IsWithin 检查 path 是否为 dir 或位于 dir 下【只按路径比较，不解析符号链接】
+func (fileUtilsStruct) IsWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
 // 4. 获取指定目录下的所有文件夹
 func (fileUtilsStruct) GetDirectories(dirPath string) ([]string, error) {
 	var dirs []string
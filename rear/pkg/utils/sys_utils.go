@@ -29,6 +29,25 @@ type CPUInfo struct {
 	Usage     float64 `json:"usage"`
 }
 
+// CPUTimes 系统累计 CPU 时间，两次采样的差值用于计算使用率
+type CPUTimes struct {
+	Idle  uint64 `json:"idle"`
+	Total uint64 `json:"total"`
+}
+
+// UsageSince 自 prev 采样以来的 CPU 使用率 0-100，无法计算时返回 -1
+func (t CPUTimes) UsageSince(prev CPUTimes) float64 {
+	if t.Total <= prev.Total || t.Idle < prev.Idle {
+		return -1
+	}
+	total := t.Total - prev.Total
+	idle := t.Idle - prev.Idle
+	if idle > total {
+		return -1
+	}
+	return float64(total-idle) / float64(total) * 100
+}
+
 // MemoryInfo 内存信息结构体
 type MemoryInfo struct {
 	Total     uint64  `json:"total"`
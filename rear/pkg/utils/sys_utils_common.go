@@ -68,6 +68,11 @@ func (s *SysUtils) GetCPUInfo() CPUInfo {
 	return cpuInfo
 }
 
+// GetCPUTimes 获取系统累计 CPU 时间
+func (s *SysUtils) GetCPUTimes() (CPUTimes, error) {
+	return s.getCPUTimesPlatform()
+}
+
 // GetMemoryInfo 获取内存信息
 func (s *SysUtils) GetMemoryInfo() MemoryInfo {
 	var memInfo MemoryInfo
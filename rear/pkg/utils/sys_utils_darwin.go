@@ -24,6 +24,11 @@ func (s *SysUtils) getCPUInfoPlatform(info *CPUInfo) {
 	info.ModelName = strings.TrimSpace(string(output))
 }
 
+// getCPUTimesPlatform macOS 暂不支持累计CPU时间
+func (s *SysUtils) getCPUTimesPlatform() (CPUTimes, error) {
+	return CPUTimes{}, fmt.Errorf("cpu times not supported on %s", runtime.GOOS)
+}
+
 // getMemoryInfoPlatform 获取macOS下的内存信息
 func (s *SysUtils) getMemoryInfoPlatform(info *MemoryInfo) {
 	cmd := exec.Command("sysctl", "-n", "hw.memsize")
@@ -35,6 +35,44 @@ func (s *SysUtils) getCPUInfoPlatform(info *CPUInfo) {
 	}
 }
 
+// getCPUTimesPlatform 读取 /proc/stat 中的 CPU 汇总行
+func (s *SysUtils) getCPUTimesPlatform() (CPUTimes, error) {
+	file, err := os.Open("/proc/stat")
+	if err != nil {
+		return CPUTimes{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		// user nice system idle iowait irq softirq steal【guest 已计入 user】
+		var times CPUTimes
+		for i, field := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return CPUTimes{}, fmt.Errorf("invalid /proc/stat value %q: %w", field, err)
+			}
+			times.Total += value
+			// idle 和 iowait 都视为空闲
+			if i == 3 || i == 4 {
+				times.Idle += value
+			}
+		}
+		return times, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return CPUTimes{}, err
+	}
+	return CPUTimes{}, fmt.Errorf("cpu line not found in /proc/stat")
+}
+
 // getMemoryInfoPlatform 获取Linux下的内存信息
 func (s *SysUtils) getMemoryInfoPlatform(info *MemoryInfo) {
 	file, err := os.Open("/proc/meminfo")
@@ -6,3 +6,14 @@ func TestSysUtils(t *testing.T) {
 	sys_u := NewSysUtils()
 	sys_u.PrintSystemInfo()
 }
+
+func TestCPUTimesUsageSince(t *testing.T) {
+	prev := CPUTimes{Idle: 100, Total: 200}
+	cur := CPUTimes{Idle: 150, Total: 400}
+	if usage := cur.UsageSince(prev); usage != 75 {
+		t.Fatalf("usage = %v, want 75", usage)
+	}
+	if usage := prev.UsageSince(cur); usage != -1 {
+		t.Fatalf("usage = %v, want -1 for reversed samples", usage)
+	}
+}
@@ -29,6 +29,30 @@ func (s *SysUtils) getCPUInfoPlatform(info *CPUInfo) {
 	}
 }
 
+// getCPUTimesPlatform 通过 GetSystemTimes 获取CPU时间【内核时间包含空闲时间】
+func (s *SysUtils) getCPUTimesPlatform() (CPUTimes, error) {
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+	getSystemTimes := kernel32.NewProc("GetSystemTimes")
+
+	var idle, kernel, user syscall.Filetime
+	ret, _, err := getSystemTimes.Call(
+		uintptr(unsafe.Pointer(&idle)),
+		uintptr(unsafe.Pointer(&kernel)),
+		uintptr(unsafe.Pointer(&user)),
+	)
+	if ret == 0 {
+		return CPUTimes{}, fmt.Errorf("GetSystemTimes failed: %w", err)
+	}
+
+	toUint := func(ft syscall.Filetime) uint64 {
+		return uint64(ft.HighDateTime)<<32 | uint64(ft.LowDateTime)
+	}
+	return CPUTimes{
+		Idle:  toUint(idle),
+		Total: toUint(kernel) + toUint(user),
+	}, nil
+}
+
 // getMemoryInfoPlatform 获取Windows下的内存信息
 func (s *SysUtils) getMemoryInfoPlatform(info *MemoryInfo) {
 	kernel32 := syscall.NewLazyDLL("kernel32.dll")
@@ -7,6 +7,8 @@ PROJECT_NAME="image-processor"
 VERSION="1.0.0"
 BUILD_DIR="build"
 TOOLS_DIR="tools"
+# sqlite_fts5: 编译 SQLite FTS5 模块，用于照片全文检索【未启用时启动失败，除非设置 SEARCH_LIKE_FALLBACK=true 回退到 LIKE 查询】
+BUILD_TAGS="sqlite_fts5"
 
 # 清理构建目录
 rm -rf $BUILD_DIR
@@ -32,7 +34,7 @@ do
     echo "Building for $GOOS/$GOARCH..."
 
     # 构建 Go 程序
-    env GOOS=$GOOS GOARCH=$GOARCH go build -o $output_dir/$output_name .
+    env GOOS=$GOOS GOARCH=$GOARCH go build -tags "$BUILD_TAGS" -o $output_dir/$output_name .
 
     # 复制工具文件
     bin_dir="$output_dir/bin"